@@ -6,7 +6,10 @@ import (
 	"github.com/froggy-12/purpurbase/api/middlewares"
 	"github.com/froggy-12/purpurbase/config"
 	"github.com/froggy-12/purpurbase/routes"
+	"github.com/froggy-12/purpurbase/services/authentication"
 	"github.com/froggy-12/purpurbase/services/mediaserver"
+	"github.com/froggy-12/purpurbase/services/upload"
+	"github.com/froggy-12/purpurbase/store"
 	"github.com/froggy-12/purpurbase/types"
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/logger"
@@ -15,9 +18,15 @@ import (
 )
 
 type Server struct {
-	mongoClient *mongo.Client
-	redisClient *redis.Client
-	sqlClient   *sql.DB
+	mongoClient  *mongo.Client
+	redisClient  *redis.Client
+	sqlClient    *sql.DB
+	userStore    store.UserStore
+	sessionStore store.SessionStore
+	apiKeyStore  store.APIKeyStore
+	attempts     store.LoginAttemptStore
+	auditStore   store.AuditStore
+	fileStore    store.FileStore
 }
 
 var (
@@ -32,9 +41,15 @@ var (
 
 func NewServer(mongoClient *mongo.Client, redisClient *redis.Client, sqlClient *sql.DB) *Server {
 	return &Server{
-		mongoClient: mongoClient,
-		redisClient: redisClient,
-		sqlClient:   sqlClient,
+		mongoClient:  mongoClient,
+		redisClient:  redisClient,
+		sqlClient:    sqlClient,
+		userStore:    store.NewUserStore(mongoClient, sqlClient),
+		sessionStore: store.NewSessionStore(mongoClient, sqlClient, redisClient),
+		apiKeyStore:  store.NewAPIKeyStore(mongoClient, sqlClient),
+		attempts:     store.NewLoginAttemptStore(redisClient),
+		auditStore:   store.NewAuditStore(mongoClient, sqlClient),
+		fileStore:    store.NewFileStore(mongoClient, sqlClient),
 	}
 }
 
@@ -51,49 +66,66 @@ func (s *Server) StartServer() error {
 		app.Use(logger.New())
 	}
 
+	middlewares.UserStore = s.userStore
+	middlewares.SessionStore = s.sessionStore
+	middlewares.APIKeyStore = s.apiKeyStore
+
 	for _, handler := range GetHandlers {
-		app.Get(handler.Route, handler.HandlerFunc)
+		app.Get(handler.Route, middlewares.Protect(handler.Roles, handler.Permissions, handler.HandlerFunc)...)
 	}
 	for _, handler := range PostHandlers {
-		app.Post(handler.Route, handler.HandlerFunc)
+		app.Post(handler.Route, middlewares.Protect(handler.Roles, handler.Permissions, handler.HandlerFunc)...)
 	}
 	for _, handler := range PutHandlers {
-		app.Put(handler.Route, handler.HandlerFunc)
+		app.Put(handler.Route, middlewares.Protect(handler.Roles, handler.Permissions, handler.HandlerFunc)...)
 	}
 	for _, handler := range PatchHandlers {
-		app.Patch(handler.Route, handler.HandlerFunc)
+		app.Patch(handler.Route, middlewares.Protect(handler.Roles, handler.Permissions, handler.HandlerFunc)...)
 	}
 	for _, handler := range OptionHandlers {
-		app.Options(handler.Route, handler.HandlerFunc)
+		app.Options(handler.Route, middlewares.Protect(handler.Roles, handler.Permissions, handler.HandlerFunc)...)
+	}
+	for _, handler := range HeadHandlers {
+		app.Head(handler.Route, middlewares.Protect(handler.Roles, handler.Permissions, handler.HandlerFunc)...)
 	}
 	for _, handler := range DeleteHandlers {
-		app.Delete(handler.Route, handler.HandlerFunc)
+		app.Delete(handler.Route, middlewares.Protect(handler.Roles, handler.Permissions, handler.HandlerFunc)...)
 	}
 
 	freeRouter := app.Group("/api", middlewares.CorsMiddleWare)
 	routes.FreeRoutes(freeRouter)
 
 	if config.Configs.Features.FileUploads {
-		routes.FileUploadingRoutes(freeRouter)
+		routes.FileUploadingRoutes(freeRouter, s.fileStore, config.Configs.Features.ProtectFileRoutes)
 	}
 
 	if config.Configs.Features.MediaServer {
-		freeRouter.Get("/get_file", mediaserver.ServeFiles)
-		freeRouter.Get("/download_file", mediaserver.DownloadFile)
+		freeRouter.Get("/get_file", func(c *fiber.Ctx) error {
+			return mediaserver.ServeFiles(c, s.fileStore, s.sessionStore, s.apiKeyStore)
+		})
+		freeRouter.Get("/download_file", func(c *fiber.Ctx) error {
+			return mediaserver.DownloadFile(c, s.fileStore, s.sessionStore, s.apiKeyStore)
+		})
 	}
 
 	if config.Configs.AuthenticationConfigurations.Auth {
-		if config.Configs.DatabaseConfigurations.DatabaseName == "mongodb" {
-			authRouter := app.Group("/api/auth")
-			userRouter := app.Group("/api/data", middlewares.CheckAndRefreshJWTTokenMiddleware)
-			routes.MongoAuthRoutes(authRouter, s.mongoClient)
-			routes.MongoUserRoutes(userRouter, s.mongoClient)
-		}
+		routes.WellKnownRoutes(app.Group("/.well-known"))
 
-		if config.Configs.DatabaseConfigurations.DatabaseName == "mysql" {
-			authRouter := app.Group("/api/auth")
+		authentication.AuditLog = s.auditStore
+
+		authRouter := app.Group("/api/auth")
+		userRouter := app.Group("/api/data", middlewares.CheckJWTTokenMiddleware, middlewares.RequireUser)
+		adminRouter := app.Group("/api/admin", middlewares.CheckJWTTokenMiddleware, middlewares.RequireAdmin)
+		routes.AuthRoutes(authRouter, s.userStore, s.sessionStore, s.attempts)
+		// deleted accounts take their uploads with them
+		removeFiles := func(userID string) error {
+			return upload.RemoveUserFiles(s.fileStore, userID)
+		}
+		routes.UserRoutes(userRouter, s.userStore, s.sessionStore, s.attempts, removeFiles)
+		routes.AdminRoutes(adminRouter, s.userStore, s.sessionStore, s.apiKeyStore, s.attempts, s.auditStore, removeFiles)
 
-			routes.SQLDBAuth(authRouter, s.sqlClient)
+		if config.Configs.AuthenticationConfigurations.AnonymousAccounts.Enabled {
+			authentication.StartGuestPurger(s.userStore, s.sessionStore, removeFiles)
 		}
 	}
 
@@ -1,12 +1,14 @@
 package middlewares
 
 import (
+	"slices"
 	"strings"
 	"time"
 
 	"github.com/froggy-12/purpurbase/config"
+	"github.com/froggy-12/purpurbase/services/authentication"
+	"github.com/froggy-12/purpurbase/store"
 	"github.com/froggy-12/purpurbase/types"
-	"github.com/froggy-12/purpurbase/utils"
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/cors"
 )
@@ -18,25 +20,133 @@ var CorsMiddleWare = cors.New(cors.Config{
 	MaxAge:       time.Now().Hour() * 24 * config.Configs.PurpurbaseConfigurations.PurpurbaseCookieAndCoresAge,
 })
 
-func CheckAndRefreshJWTTokenMiddleware(c *fiber.Ctx) error {
-	userId, expired, err := utils.ReadJWTToken(c.Cookies("jwtToken"), config.Configs.PurpurbaseConfigurations.PurpurbaseJWTTokenSecret)
+// set by the api server before any route is registered
+var (
+	UserStore    store.UserStore
+	SessionStore store.SessionStore
+	APIKeyStore  store.APIKeyStore
+)
+
+// CheckJWTTokenMiddleware accepts a server api key or a valid access token, from "Authorization: Bearer" or the
+// jwtToken cookie, whose session still exists. Expired access tokens have to be renewed through /api/auth/refresh
+func CheckJWTTokenMiddleware(c *fiber.Ctx) error {
+	principal, err := authentication.ReadPrincipal(c, SessionStore, APIKeyStore)
 	if err != nil {
-		return c.Status(fiber.StatusUnauthorized).JSON(types.ErrorResponse{Error: "User is not authorised please log in"})
+		return c.Status(fiber.StatusUnauthorized).JSON(types.ErrorResponse{Error: err.Error()})
 	}
-	if expired {
-		return c.Status(fiber.StatusBadRequest).JSON(types.ErrorResponse{Error: "Please Log in"})
+
+	authentication.SetPrincipal(c, principal)
+
+	return c.Next()
+}
+
+// OptionalJWTTokenMiddleware sets the principal like CheckJWTTokenMiddleware when the request carries an api key or
+// access token and lets requests without one through. Expired tokens, revoked sessions and bad api keys are still refused
+func OptionalJWTTokenMiddleware(c *fiber.Ctx) error {
+	principal, err := authentication.ReadPrincipal(c, SessionStore, APIKeyStore)
+	if err == authentication.ErrNotAuthenticated {
+		return c.Next()
 	}
-	// Pass the token instead of the user ID
-	newToken, err := utils.RefreshJWTToken(c.Cookies("jwtToken"), config.Configs.PurpurbaseConfigurations.PurpurbaseJWTTokenSecret, config.Configs.PurpurbaseConfigurations.PurpurbaseCookieAndCoresAge)
 	if err != nil {
-		return c.Status(fiber.StatusUnauthorized).JSON(types.ErrorResponse{
-			Error: "Failed to refresh JWT token",
-		})
+		return c.Status(fiber.StatusUnauthorized).JSON(types.ErrorResponse{Error: err.Error()})
 	}
 
-	utils.SetJwtHttpCookies(c, newToken, config.Configs.PurpurbaseConfigurations.PurpurbaseCookieAndCoresAge)
+	authentication.SetPrincipal(c, principal)
+
+	return c.Next()
+}
 
-	c.Locals("userId", userId)
+// Deprecated: tokens are not refreshed by the middleware anymore, use CheckJWTTokenMiddleware
+var CheckAndRefreshJWTTokenMiddleware = CheckJWTTokenMiddleware
+
+// HasPermission reports if the granted permissions cover the permission,
+// "*" covers everything and "files:*" everything that starts with "files:"
+func HasPermission(granted []string, permission string) bool {
+	for _, grant := range granted {
+		if grant == "*" || grant == permission {
+			return true
+		}
+		if prefix, ok := strings.CutSuffix(grant, "*"); ok && strings.HasPrefix(permission, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// RequireRole must run after CheckJWTTokenMiddleware, the user needs any of the roles
+func RequireRole(roles ...string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		granted, _ := c.Locals("roles").([]string)
+		for _, role := range roles {
+			if slices.Contains(granted, role) {
+				return c.Next()
+			}
+		}
+		return c.Status(fiber.StatusForbidden).JSON(types.ErrorResponse{Error: "Missing role: " + strings.Join(roles, " or ")})
+	}
+}
+
+// RequirePermission must run after CheckJWTTokenMiddleware, the user needs every one of the permissions
+func RequirePermission(permissions ...string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		granted, _ := c.Locals("permissions").([]string)
+		for _, permission := range permissions {
+			if !HasPermission(granted, permission) {
+				return c.Status(fiber.StatusForbidden).JSON(types.ErrorResponse{Error: "Missing permission: " + permission})
+			}
+		}
+		return c.Next()
+	}
+}
+
+// Protect returns the handlers for a route that needs a logged in user with any of the roles and all of the permissions,
+// without roles and permissions the route stays public
+func Protect(roles, permissions []string, handler fiber.Handler) []fiber.Handler {
+	if len(roles) == 0 && len(permissions) == 0 {
+		return []fiber.Handler{handler}
+	}
+
+	handlers := []fiber.Handler{CheckJWTTokenMiddleware}
+	if len(roles) > 0 {
+		handlers = append(handlers, RequireRole(roles...))
+	}
+	if len(permissions) > 0 {
+		handlers = append(handlers, RequirePermission(permissions...))
+	}
+	return append(handlers, handler)
+}
+
+// RequireUser must run after CheckJWTTokenMiddleware, it turns away server api keys
+func RequireUser(c *fiber.Ctx) error {
+	if userId, _ := c.Locals("userId").(string); userId == "" {
+		return c.Status(fiber.StatusForbidden).JSON(types.ErrorResponse{Error: "This route needs a logged in user"})
+	}
+	return c.Next()
+}
+
+// RequireAdmin must run after CheckJWTTokenMiddleware, admins can only use the admin routes with two factor authentication enabled,
+// server api keys need the "admin" scope
+func RequireAdmin(c *fiber.Ctx) error {
+	if principal, _ := authentication.CurrentPrincipal(c); principal.IsService() {
+		if !HasPermission(principal.Permissions, "admin") {
+			return c.Status(fiber.StatusForbidden).JSON(types.ErrorResponse{Error: "API key is missing the admin scope"})
+		}
+		return c.Next()
+	}
+
+	roles, _ := c.Locals("roles").([]string)
+	if !slices.Contains(roles, "admin") {
+		return c.Status(fiber.StatusForbidden).JSON(types.ErrorResponse{Error: "Admin access required"})
+	}
+
+	userId, _ := c.Locals("userId").(string)
+	user, err := UserStore.FindUserByID(userId)
+	if err != nil {
+		return c.Status(fiber.StatusForbidden).JSON(types.ErrorResponse{Error: "Admin access required"})
+	}
+	if !user.TOTPEnabled {
+		return c.Status(fiber.StatusForbidden).JSON(types.ErrorResponse{Error: "Admins have to enable two factor authentication first"})
+	}
 
 	return c.Next()
 }
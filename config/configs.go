@@ -26,29 +26,159 @@ type PurpurbaseConfigurations struct {
 	PurpurbaseAllowedCorsOrigins     []string `json:"purpurbaseAllowedCorsOrigins"`
 	PurpurbaseAPIServerBodySizeLimit int      `json:"purpurbaseAPIServerBodySizeLimit"`
 	PurpurbaseCookieAndCoresAge      int      `json:"purpurbaseCookieAndCoreAge"`
-	PurpurbaseJWTTokenSecret         string   `json:"purpurbaseJWTTokenSecret"`
+	PurpurbaseJWTTokenSecret         string   `json:"purpurbaseJWTTokenSecret"` // random per install, keys the mfa and oauth state tokens even with jwtKeys
 	PurpurbasePasswordEncryptionRate int      `json:"purpurbasePasswordEncryptionRate"`
 }
 
 type AuthenticationConfigurations struct {
-	Auth                          bool   `json:"auth"`
-	OAuth                         bool   `json:"oauth"`
-	GoogleOAuth                   bool   `json:"googleOAuth"`
-	GoogleOAuthAppID              string `json:"googleOAuthAppID"`
-	GoogleOAuthAppSecret          string `json:"googleOAuthAppSecret"`
-	GithubOAuth                   bool   `json:"githubOAuth"`
-	GithubOAuthAppID              string `json:"githubOAuthAppID"`
-	GithubOAuthAppSecret          string `json:"githubOAuthAppSecret"`
-	EmailVerification             bool   `json:"emailVerification"`
-	SetJWTAfterSignUp             bool   `json:"setJWTAfterSignUp"`
-	RealTimeUserData              bool   `json:"realTimeUserData"`
-	SendEmailAfterSignUpWithToken bool   `json:"sendEmailAfterSignUpWithToken"`
+	Auth                          bool                `json:"auth"`
+	OAuth                         bool                `json:"oauth"`
+	GoogleOAuth                   bool                `json:"googleOAuth"`
+	GoogleOAuthAppID              string              `json:"googleOAuthAppID"`
+	GoogleOAuthAppSecret          string              `json:"googleOAuthAppSecret"`
+	GithubOAuth                   bool                `json:"githubOAuth"`
+	GithubOAuthAppID              string              `json:"githubOAuthAppID"`
+	GithubOAuthAppSecret          string              `json:"githubOAuthAppSecret"`
+	EmailVerification             bool                `json:"emailVerification"`
+	SetJWTAfterSignUp             bool                `json:"setJWTAfterSignUp"`
+	RealTimeUserData              bool                `json:"realTimeUserData"`
+	SendEmailAfterSignUpWithToken bool                `json:"sendEmailAfterSignUpWithToken"`
+	GoogleOAuthEndpoints          OAuthEndpoints      `json:"googleOAuthEndpoints"`
+	GithubOAuthEndpoints          OAuthEndpoints      `json:"githubOAuthEndpoints"`
+	OAuthCallbackBaseURL          string              `json:"oauthCallbackBaseURL"`    // public url of this server, callbacks are built as <base>/api/auth/oauth/<provider>/callback
+	OAuthSuccessRedirectURL       string              `json:"oauthSuccessRedirectURL"` // where the browser is sent after a successful oauth log in, json response if empty
+	OAuthAccountMerge             string              `json:"oauthAccountMerge"`       // oauth log in with the email of an account that hasn't linked it: "verified" (default) links it when the account's email is verified, "link" always links it but drops the credentials of unverified accounts, "never" refuses
+	PasswordResetTokenAge         int                 `json:"passwordResetTokenAge"`   // minutes, by default 30
+	AccessTokenAge                int                 `json:"accessTokenAge"`          // minutes, by default 15, the refresh token lives purpurbaseCookieAndCoreAge days
+	SessionStore                  string              `json:"sessionStore"`            // "database" (default) or "redis"
+	AdminEmails                   []string            `json:"adminEmails"`             // verified users with these emails always get the admin role
+	Roles                         map[string][]string `json:"roles"`                   // permissions of every role, "*" grants everything and "files:*" everything under files
+	DefaultRoles                  []string            `json:"defaultRoles"`            // roles of new users and of users without any role, by default user
+	TOTPIssuer                    string              `json:"totpIssuer"`              // name shown in authenticator apps, by default Purpurbase
+	MagicLink                     bool                `json:"magicLink"`               // passwordless log in with an emailed link or code
+	MagicLinkSignUp               bool                `json:"magicLinkSignUp"`         // create accounts for unknown emails asking for a magic link
+	MagicLinkURL                  string              `json:"magicLinkURL"`            // page the emailed link opens with ?email=&token=, by default /api/auth/magic-link/verify of this server
+	MagicLinkTokenAge             int                 `json:"magicLinkTokenAge"`       // minutes, by default 15
+	TokensInBody                  bool                `json:"tokensInBody"`            // log in responses carry the tokens when asked with ?tokens=body, for apps that can't keep cookies
+	JWTKeys                       []JWTKey            `json:"jwtKeys"`                 // the first key signs access tokens and every key verifies them, HS256 with purpurbaseJWTTokenSecret when empty
+	JWTIssuer                     string              `json:"jwtIssuer"`               // iss of the access tokens, by default purpurbase
+	JWTAudience                   string              `json:"jwtAudience"`             // aud of the access tokens, by default purpurbase
+	LoginProtection               LoginProtection     `json:"loginProtection"`
+	PasswordHashing               PasswordHashing     `json:"passwordHashing"`
+	PasswordPolicy                PasswordPolicy      `json:"passwordPolicy"`
+	EmailChange                   EmailChange         `json:"emailChange"`
+	AnonymousAccounts             AnonymousAccounts   `json:"anonymousAccounts"`
+}
+
+// AnonymousAccounts lets /api/auth/anonymous create guest users that can try the app before signing up,
+// a guest becomes a full account by adding an email and password or by logging in with oauth
+type AnonymousAccounts struct {
+	Enabled       bool `json:"enabled"`
+	TTL           int  `json:"ttl"`           // days a guest is kept after its last log in or refresh, by default 30
+	PurgeInterval int  `json:"purgeInterval"` // minutes between two purges of stale guests, by default 60
+}
+
+// EmailChange stages email changes: the new address gets a link to confirm the change and the old address
+// a link to revert it, the email only changes once it has been confirmed
+type EmailChange struct {
+	TokenAge   int    `json:"tokenAge"`   // minutes the confirmation link is valid, by default 60
+	RevertAge  int    `json:"revertAge"`  // hours the old address can revert the change, by default 168
+	ConfirmURL string `json:"confirmURL"` // page the confirmation link opens with ?id=&token=, by default /api/auth/email-change/confirm of this server
+	RevertURL  string `json:"revertURL"`  // page the revert link opens with ?id=&token=, by default /api/auth/email-change/revert of this server
+}
+
+// PasswordPolicy is checked whenever a password is set: on sign up, password change and password reset
+type PasswordPolicy struct {
+	MinLength             int    `json:"minLength"` // by default 8
+	MaxLength             int    `json:"maxLength"` // by default 72 characters, with bcrypt passwords are also limited to 72 bytes
+	RequireUppercase      bool   `json:"requireUppercase"`
+	RequireLowercase      bool   `json:"requireLowercase"`
+	RequireDigit          bool   `json:"requireDigit"`
+	RequireSymbol         bool   `json:"requireSymbol"`
+	DisallowPersonalInfo  bool   `json:"disallowPersonalInfo"`  // passwords can't contain the username or email
+	History               int    `json:"history"`               // the current and this many previous passwords can't be reused, 0 turns it off
+	BreachedPasswordsFile string `json:"breachedPasswordsFile"` // file of "SHA1:count" lines or directory of pwned passwords range files, no check when empty
+}
+
+// PasswordHashing picks the algorithm for new password hashes, older hashes keep working and are upgraded
+// on the next successful log in when the algorithm or its parameters changed
+type PasswordHashing struct {
+	Algorithm   string `json:"algorithm"`   // "argon2id" or "bcrypt", bcrypt with purpurbasePasswordEncryptionRate when empty
+	Memory      uint32 `json:"memory"`      // argon2id memory in KiB, by default 65536
+	Iterations  uint32 `json:"iterations"`  // argon2id passes over the memory, by default 3
+	Parallelism uint8  `json:"parallelism"` // argon2id threads, by default 2
+}
+
+// LoginProtection locks out accounts and ips after too many failed log ins, the counters live in redis when
+// redisConnectionURI is set and in memory otherwise
+type LoginProtection struct {
+	Disabled           bool   `json:"disabled"`
+	MaxAccountAttempts int    `json:"maxAccountAttempts"` // failed attempts for one email before it is locked, by default 5
+	MaxIPAttempts      int    `json:"maxIPAttempts"`      // failed attempts from one ip before it is locked, by default 20
+	AttemptWindow      int    `json:"attemptWindow"`      // minutes the failed attempts are counted for, by default 15
+	LockoutDuration    int    `json:"lockoutDuration"`    // minutes of the first lockout, every further lockout within a day doubles it, by default 15
+	MaxLockoutDuration int    `json:"maxLockoutDuration"` // minutes, by default 1440
+	UnlockURL          string `json:"unlockURL"`          // page the emailed unlock link opens with ?token=, by default /api/auth/unlock of this server
+}
+
+// JWTKey is a PEM key pair, keep the old key after the new one until the tokens it signed have expired to rotate keys
+type JWTKey struct {
+	KID            string `json:"kid"`
+	Algorithm      string `json:"algorithm"`      // RS256, ES256 or EdDSA
+	PrivateKeyFile string `json:"privateKeyFile"` // only needed for the first key
+	PublicKeyFile  string `json:"publicKeyFile"`  // taken from the private key when empty
+}
+
+type OAuthEndpoints struct {
+	AuthURL     string `json:"authURL"`
+	TokenURL    string `json:"tokenURL"`
+	UserInfoURL string `json:"userInfoURL"`
+	EmailsURL   string `json:"emailsURL"` // only used by github
 }
 
 type Features struct {
 	ChatFunctionality bool `json:"chatFunctionality"`
 	MediaServer       bool `json:"mediaServer"`
 	FileUploads       bool `json:"fileUploading"`
+	ProtectFileRoutes bool `json:"protectFileRoutes"` // uploads need the files:upload permission and deletes files:delete
+}
+
+// the access rules of a bucket, admins can read the files of every bucket
+const (
+	PublicRead        = "public-read"        // everyone, the default
+	AuthenticatedRead = "authenticated-read" // logged in users and api keys
+	OwnerOnly         = "owner-only"         // the uploader of the file
+)
+
+// Bucket is a named folder of the storage, the upload routes and the media server apply its rules to every file in
+// it. Uploads into buckets that aren't public-read need a logged in user or an api key
+type Bucket struct {
+	Folder string `json:"folder"` // folder below the storage root, by default the name of the bucket
+	Access string `json:"access"` // public-read, authenticated-read or owner-only
+	UploadRule
+}
+
+// UploadRule limits what a bucket accepts, the type of a file is detected from its content so renamed files are caught
+type UploadRule struct {
+	AllowedTypes []string       `json:"allowedTypes"` // mime types like image/png, "image/*" allows a whole type and "*" everything, every type when empty
+	MaxSizes     map[string]int `json:"maxSizes"`     // megabytes per mime type, "image/*" covers a whole type and "*" the rest, no limit when nothing matches
+}
+
+// StorageConfigurations picks where uploaded files are kept
+type StorageConfigurations struct {
+	Driver    string    `json:"driver"`    // "local" (default) or "s3"
+	LocalPath string    `json:"localPath"` // folder of the local driver, by default ./uploads
+	S3        S3Storage `json:"s3"`
+}
+
+// S3Storage works with aws s3 and anything speaking its api like minio or cloudflare r2
+type S3Storage struct {
+	Endpoint        string `json:"endpoint"` // like https://s3.us-east-1.amazonaws.com or http://localhost:9000
+	Region          string `json:"region"`   // by default us-east-1
+	Bucket          string `json:"bucket"`
+	AccessKeyID     string `json:"accessKeyId"`
+	SecretAccessKey string `json:"secretAccessKey"`
+	PathStyle       bool   `json:"pathStyle"` // <endpoint>/<bucket>/<key> instead of <bucket>.<endpoint host>/<key>, minio needs it
 }
 
 type SMTPConfigurations struct {
@@ -67,6 +197,8 @@ type Configurations struct {
 	DatabaseConfigurations       DatabaseConfigurations       `json:"databaseConfigs"`
 	Features                     Features                     `json:"features"`
 	SMTPConfigurations           SMTPConfigurations           `json:"SMTPConfigurations"`
+	StorageConfigurations        StorageConfigurations        `json:"storageConfigs"`
+	Buckets                      map[string]Bucket            `json:"buckets"` // images, musics, videos and files when left out
 }
 
 var Configs Configurations
@@ -1,12 +1,67 @@
 package config
 
 import (
+	"crypto/rand"
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"log"
 	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
 )
 
+// DefaultBuckets are the folders purpurbase always had, they are used when the configuration has no buckets.
+// svg is left out of the images on purpose as it can carry scripts
+func DefaultBuckets() map[string]Bucket {
+	return map[string]Bucket{
+		"images": {
+			Access: PublicRead,
+			UploadRule: UploadRule{
+				AllowedTypes: []string{"image/jpeg", "image/png", "image/gif", "image/bmp", "image/tiff", "image/webp", "image/avif"},
+				MaxSizes:     map[string]int{"*": 10},
+			},
+		},
+		"musics": {
+			Access: PublicRead,
+			UploadRule: UploadRule{
+				AllowedTypes: []string{"audio/mpeg", "audio/wav", "audio/ogg", "audio/flac", "audio/x-m4a", "audio/mp4", "audio/aac"},
+				MaxSizes:     map[string]int{"*": 50},
+			},
+		},
+		"videos": {
+			Access: PublicRead,
+			UploadRule: UploadRule{
+				AllowedTypes: []string{"video/mp4", "video/x-msvideo", "video/quicktime", "video/x-ms-asf", "video/webm"},
+				MaxSizes:     map[string]int{"*": 200},
+			},
+		},
+		"files": {
+			Access: PublicRead,
+			UploadRule: UploadRule{
+				AllowedTypes: []string{"*"},
+				MaxSizes:     map[string]int{},
+			},
+		},
+	}
+}
+
+// oldDefaultJWTSecret was written into every configs.json before the secret was generated per install
+const oldDefaultJWTSecret = "SuperSecretPurpurBase"
+
+// minJWTSecretLength keeps the secret out of reach of brute force, it signs tokens with HS256
+const minJWTSecretLength = 32
+
+// generateJWTSecret gives every new install its own secret
+func generateJWTSecret() string {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		log.Fatal("Error generating the jwt secret, error: " + err.Error())
+	}
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
 func InitConfigs() Configurations {
 	var configs Configurations
 
@@ -34,7 +89,7 @@ func CreateDefaultConfigurations(configs *Configurations) {
 			PurpurbaseAllowedCorsOrigins:     []string{"*"},      // default set to all origin
 			PurpurbaseAPIServerBodySizeLimit: 200 * 1024 * 1024,
 			PurpurbaseCookieAndCoresAge:      7,
-			PurpurbaseJWTTokenSecret:         "SuperSecretPurpurBase",
+			PurpurbaseJWTTokenSecret:         generateJWTSecret(),
 			PurpurbasePasswordEncryptionRate: 10,
 		}, // all default value
 		AuthenticationConfigurations: AuthenticationConfigurations{
@@ -49,6 +104,67 @@ func CreateDefaultConfigurations(configs *Configurations) {
 			EmailVerification:             true,
 			SendEmailAfterSignUpWithToken: false,
 			SetJWTAfterSignUp:             false,
+			GoogleOAuthEndpoints: OAuthEndpoints{
+				AuthURL:     "https://accounts.google.com/o/oauth2/v2/auth",
+				TokenURL:    "https://oauth2.googleapis.com/token",
+				UserInfoURL: "https://openidconnect.googleapis.com/v1/userinfo",
+			},
+			GithubOAuthEndpoints: OAuthEndpoints{
+				AuthURL:     "https://github.com/login/oauth/authorize",
+				TokenURL:    "https://github.com/login/oauth/access_token",
+				UserInfoURL: "https://api.github.com/user",
+				EmailsURL:   "https://api.github.com/user/emails",
+			},
+			OAuthCallbackBaseURL:    "http://localhost:6644",
+			OAuthSuccessRedirectURL: "",
+			OAuthAccountMerge:       "verified",
+			PasswordResetTokenAge:   30,
+			AccessTokenAge:          15,
+			SessionStore:            "database",
+			AdminEmails:             []string{},
+			Roles: map[string][]string{
+				"admin": {"*"},
+				"user":  {"files:upload", "files:delete"},
+			},
+			DefaultRoles:      []string{"user"},
+			TOTPIssuer:        "Purpurbase",
+			MagicLink:         false,
+			MagicLinkSignUp:   false,
+			MagicLinkURL:      "",
+			MagicLinkTokenAge: 15,
+			TokensInBody:      false,
+			JWTKeys:           []JWTKey{},
+			JWTIssuer:         "purpurbase",
+			JWTAudience:       "purpurbase",
+			LoginProtection: LoginProtection{
+				MaxAccountAttempts: 5,
+				MaxIPAttempts:      20,
+				AttemptWindow:      15,
+				LockoutDuration:    15,
+				MaxLockoutDuration: 1440,
+			},
+			PasswordHashing: PasswordHashing{
+				Algorithm:   "argon2id",
+				Memory:      64 * 1024,
+				Iterations:  3,
+				Parallelism: 2,
+			},
+			PasswordPolicy: PasswordPolicy{
+				MinLength:             8,
+				MaxLength:             72,
+				DisallowPersonalInfo:  true,
+				History:               5,
+				BreachedPasswordsFile: "",
+			},
+			EmailChange: EmailChange{
+				TokenAge:  60,
+				RevertAge: 168,
+			},
+			AnonymousAccounts: AnonymousAccounts{
+				Enabled:       false,
+				TTL:           30,
+				PurgeInterval: 60,
+			},
 		},
 		ExtraConfigurations: ExtraConfigurations{
 			ShowCreditsOnStartup: true,
@@ -70,6 +186,7 @@ func CreateDefaultConfigurations(configs *Configurations) {
 			ChatFunctionality: true,
 			MediaServer:       true,
 			FileUploads:       true,
+			ProtectFileRoutes: false,
 		},
 		SMTPConfigurations: SMTPConfigurations{
 			SMTPEnabled:            false,
@@ -79,6 +196,16 @@ func CreateDefaultConfigurations(configs *Configurations) {
 			SMTPEmailPassword:      "",
 			SMTPAllowedForEveryone: false,
 		},
+		StorageConfigurations: StorageConfigurations{
+			Driver:    "local",
+			LocalPath: "./uploads",
+			S3: S3Storage{
+				Endpoint:  "https://s3.us-east-1.amazonaws.com",
+				Region:    "us-east-1",
+				PathStyle: false,
+			},
+		},
+		Buckets: DefaultBuckets(),
 	}
 
 	data, err := json.MarshalIndent(*configs, "", " ")
@@ -96,6 +223,12 @@ func CreateDefaultConfigurations(configs *Configurations) {
 }
 
 func CheckConfigurations() {
+	// the secret signs the mfa challenges and the oauth state even when access tokens use jwt keys, with a known
+	// secret anybody could skip the password step of a two factor log in
+	if secret := Configs.PurpurbaseConfigurations.PurpurbaseJWTTokenSecret; secret == oldDefaultJWTSecret || len(secret) < minJWTSecretLength {
+		log.Fatal("purpurbaseJWTTokenSecret must be a random secret of at least " + strconv.Itoa(minJWTSecretLength) + " characters, for example the output of openssl rand -base64 32")
+	}
+
 	if Configs.DatabaseConfigurations.DatabaseName != "mongodb" &&
 		Configs.DatabaseConfigurations.DatabaseName != "mysql" &&
 		Configs.DatabaseConfigurations.DatabaseName != "postgresql" {
@@ -103,13 +236,57 @@ func CheckConfigurations() {
 	}
 	if Configs.DatabaseConfigurations.DatabaseName == "mongodb" && Configs.DatabaseConfigurations.MongoDBConnectionURI == "" {
 		log.Fatal("no uri provided for mongodb")
-	} else if Configs.DatabaseConfigurations.DatabaseName == "mysql" && Configs.DatabaseConfigurations.SQLClientConfigurations.Host == "" || Configs.DatabaseConfigurations.SQLClientConfigurations.PASSWORD == "" || Configs.DatabaseConfigurations.SQLClientConfigurations.PORT == "" || Configs.DatabaseConfigurations.SQLClientConfigurations.USER == "" {
+	} else if Configs.DatabaseConfigurations.DatabaseName == "mysql" && (Configs.DatabaseConfigurations.SQLClientConfigurations.Host == "" || Configs.DatabaseConfigurations.SQLClientConfigurations.PASSWORD == "" || Configs.DatabaseConfigurations.SQLClientConfigurations.PORT == "" || Configs.DatabaseConfigurations.SQLClientConfigurations.USER == "") {
 		log.Fatal("no uri provided for sql client")
 	} else if Configs.DatabaseConfigurations.DatabaseName == "postgresql" && Configs.DatabaseConfigurations.PostgreSQLConnectionURI == "" {
 		log.Fatal("no uri provided for postgresql")
 	}
 
+	if Configs.AuthenticationConfigurations.OAuth {
+		if Configs.AuthenticationConfigurations.GoogleOAuth && (Configs.AuthenticationConfigurations.GoogleOAuthAppID == "" || Configs.AuthenticationConfigurations.GoogleOAuthAppSecret == "") {
+			log.Fatal("google oauth is turned on but no app id or app secret provided")
+		}
+		if Configs.AuthenticationConfigurations.GithubOAuth && (Configs.AuthenticationConfigurations.GithubOAuthAppID == "" || Configs.AuthenticationConfigurations.GithubOAuthAppSecret == "") {
+			log.Fatal("github oauth is turned on but no app id or app secret provided")
+		}
+		if Configs.AuthenticationConfigurations.OAuthCallbackBaseURL == "" {
+			log.Fatal("no callback base url provided for oauth")
+		}
+	}
+
+	if Configs.AuthenticationConfigurations.MagicLink && !Configs.SMTPConfigurations.SMTPEnabled {
+		log.Fatal("magic link log in is turned on but smtp is not enabled")
+	}
+
 	if Configs.Features.ChatFunctionality && Configs.DatabaseConfigurations.RedisConnectionURI == "" {
 		log.Fatal("no uri provided for connecting with redis")
 	}
+
+	if Configs.AuthenticationConfigurations.SessionStore != "" && Configs.AuthenticationConfigurations.SessionStore != "database" && Configs.AuthenticationConfigurations.SessionStore != "redis" {
+		log.Fatal("sessionStore must be either database or redis")
+	}
+
+	if Configs.AuthenticationConfigurations.SessionStore == "redis" && Configs.DatabaseConfigurations.RedisConnectionURI == "" {
+		log.Fatal("sessions are stored in redis but no uri provided for connecting with redis")
+	}
+
+	if Configs.StorageConfigurations.Driver != "" && Configs.StorageConfigurations.Driver != "local" && Configs.StorageConfigurations.Driver != "s3" {
+		log.Fatal("storage driver must be either local or s3")
+	}
+
+	if Configs.StorageConfigurations.Driver == "s3" && (Configs.StorageConfigurations.S3.Endpoint == "" || Configs.StorageConfigurations.S3.Bucket == "" || Configs.StorageConfigurations.S3.AccessKeyID == "" || Configs.StorageConfigurations.S3.SecretAccessKey == "") {
+		log.Fatal("files are stored in s3 but no endpoint, bucket or access keys provided")
+	}
+
+	for name, bucket := range Configs.Buckets {
+		if name == "" || strings.HasPrefix(name, ".") || strings.ContainsAny(name, `/\:`) {
+			log.Fatal("invalid bucket name " + name + ", bucket names can't start with a dot or contain slashes")
+		}
+		if bucket.Folder != "" && !filepath.IsLocal(filepath.FromSlash(bucket.Folder)) {
+			log.Fatal("the folder of bucket " + name + " has to stay below the storage root")
+		}
+		if bucket.Access != "" && bucket.Access != PublicRead && bucket.Access != AuthenticatedRead && bucket.Access != OwnerOnly {
+			log.Fatal("access of bucket " + name + " must be either public-read, authenticated-read or owner-only")
+		}
+	}
 }
@@ -50,6 +50,7 @@ func ConnectToSQLClient() *sql.DB {
 		Addr:                 config.Configs.DatabaseConfigurations.SQLClientConfigurations.Host + ":" + config.Configs.DatabaseConfigurations.SQLClientConfigurations.PORT,
 		AllowNativePasswords: true,
 		ParseTime:            true,
+		ClientFoundRows:      true, // stores treat 0 affected rows as not found, so count matched rows instead of changed ones
 	}
 	db, err := sql.Open("mysql", cfg.FormatDSN())
 	if err != nil {
@@ -35,6 +35,55 @@ func Init(mongoClient *mongo.Client, SQLDB *sql.DB) {
 		if err != nil {
 			log.Fatal(err)
 		}
+
+		_, err = usersCollection.Indexes().CreateOne(context.Background(), mongo.IndexModel{
+			Keys: bson.D{{Key: "identities.provider", Value: 1}, {Key: "identities.subject", Value: 1}},
+		})
+
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		sessionsCollection := database.Collection("sessions")
+
+		_, err = sessionsCollection.Indexes().CreateMany(context.Background(), []mongo.IndexModel{
+			{Keys: bson.M{"id": 1}, Options: options.Index().SetUnique(true)},
+			{Keys: bson.M{"userId": 1}},
+			{Keys: bson.M{"expiresAt": 1}, Options: options.Index().SetExpireAfterSeconds(0)},
+		})
+
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		_, err = database.Collection("api_keys").Indexes().CreateOne(context.Background(), mongo.IndexModel{
+			Keys:    bson.M{"id": 1},
+			Options: options.Index().SetUnique(true),
+		})
+
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		_, err = database.Collection("audit_log").Indexes().CreateMany(context.Background(), []mongo.IndexModel{
+			{Keys: bson.D{{Key: "createdAt", Value: -1}, {Key: "_id", Value: -1}}},
+			{Keys: bson.M{"userId": 1}},
+			{Keys: bson.M{"type": 1}},
+		})
+
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		_, err = database.Collection("files").Indexes().CreateMany(context.Background(), []mongo.IndexModel{
+			{Keys: bson.M{"id": 1}, Options: options.Index().SetUnique(true)},
+			{Keys: bson.D{{Key: "folder", Value: 1}, {Key: "fileName", Value: 1}}, Options: options.Index().SetUnique(true)},
+			{Keys: bson.D{{Key: "uploaderId", Value: 1}, {Key: "createdAt", Value: -1}}},
+		})
+
+		if err != nil {
+			log.Fatal(err)
+		}
 	}
 
 	if config.Configs.DatabaseConfigurations.DatabaseName == "mysql" {
@@ -70,6 +119,283 @@ func Init(mongoClient *mongo.Client, SQLDB *sql.DB) {
 			log.Fatal(err)
 		}
 
+		_, err = SQLDB.Exec(`
+			CREATE TABLE IF NOT EXISTS purpurbase.sessions (
+				ID VARCHAR(255) NOT NULL,
+				UserID VARCHAR(255) NOT NULL,
+				RefreshTokenHash VARCHAR(255) NOT NULL,
+				PreviousRefreshTokenHash VARCHAR(255),
+				UserAgent VARCHAR(512),
+				IP VARCHAR(255),
+				CreatedAt TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+				LastUsedAt TIMESTAMP NULL,
+				ExpiresAt TIMESTAMP NULL,
+				PRIMARY KEY (ID),
+				INDEX (UserID)
+			);
+		`)
+
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		_, err = SQLDB.Exec(`
+			CREATE TABLE IF NOT EXISTS purpurbase.api_keys (
+				ID VARCHAR(255) NOT NULL,
+				Name VARCHAR(255) NOT NULL,
+				KeyHash VARCHAR(255) NOT NULL,
+				Scopes TEXT,
+				CreatedBy VARCHAR(255),
+				CreatedAt TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+				RotatedAt TIMESTAMP NULL,
+				LastUsedAt TIMESTAMP NULL,
+				ExpiresAt TIMESTAMP NULL,
+				PRIMARY KEY (ID)
+			);
+		`)
+
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		_, err = SQLDB.Exec(`
+			CREATE TABLE IF NOT EXISTS purpurbase.audit_log (
+				Seq BIGINT NOT NULL AUTO_INCREMENT,
+				ID VARCHAR(255) NOT NULL UNIQUE,
+				Type VARCHAR(255) NOT NULL,
+				UserID VARCHAR(255),
+				ActorID VARCHAR(255),
+				Email VARCHAR(255),
+				Success BOOLEAN NOT NULL DEFAULT FALSE,
+				IP VARCHAR(255),
+				UserAgent VARCHAR(512),
+				Details TEXT,
+				CreatedAt TIMESTAMP(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+				PRIMARY KEY (Seq),
+				INDEX (UserID),
+				INDEX (Type),
+				INDEX (CreatedAt)
+			);
+		`)
+
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		_, err = SQLDB.Exec(`
+			CREATE TABLE IF NOT EXISTS purpurbase.files (
+				ID VARCHAR(255) NOT NULL,
+				Folder VARCHAR(255) NOT NULL,
+				FileName VARCHAR(255) NOT NULL,
+				OriginalName VARCHAR(512),
+				Size BIGINT NOT NULL DEFAULT 0,
+				ContentType VARCHAR(255),
+				Checksum VARCHAR(64),
+				UploaderID VARCHAR(255),
+				CreatedAt TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+				PRIMARY KEY (ID),
+				UNIQUE (Folder, FileName),
+				INDEX (UploaderID)
+			);
+		`)
+
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		migrateUserColumns(SQLDB, false)
 	}
 
+	if config.Configs.DatabaseConfigurations.DatabaseName == "postgresql" {
+		utils.DebugLogger("db", "detected postgresql as primary database running some configurations")
+
+		_, err := SQLDB.Exec(`CREATE SCHEMA IF NOT EXISTS purpurbase;`)
+
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		_, err = SQLDB.Exec(`
+			CREATE TABLE IF NOT EXISTS purpurbase.users (
+				ID VARCHAR(255) NOT NULL,
+				UserName VARCHAR(255) NOT NULL UNIQUE,
+				FirstName VARCHAR(255) NOT NULL,
+				LastName VARCHAR(255) NOT NULL,
+				Email VARCHAR(255) NOT NULL UNIQUE,
+				Password VARCHAR(255) NOT NULL,
+				BirthDay DATE,
+				ProfilePicture VARCHAR(255),
+				CreatedAt TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+				UpdatedAt TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+				Verified BOOLEAN NOT NULL DEFAULT FALSE,
+				VerificationToken VARCHAR(255),
+				LastLoggedIn TIMESTAMP,
+				RawData JSONB,
+				PRIMARY KEY (ID)
+			);
+		`)
+
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		_, err = SQLDB.Exec(`
+			CREATE TABLE IF NOT EXISTS purpurbase.sessions (
+				ID VARCHAR(255) NOT NULL,
+				UserID VARCHAR(255) NOT NULL,
+				RefreshTokenHash VARCHAR(255) NOT NULL,
+				PreviousRefreshTokenHash VARCHAR(255),
+				UserAgent VARCHAR(512),
+				IP VARCHAR(255),
+				CreatedAt TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+				LastUsedAt TIMESTAMP,
+				ExpiresAt TIMESTAMP,
+				PRIMARY KEY (ID)
+			);
+		`)
+
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		_, err = SQLDB.Exec(`CREATE INDEX IF NOT EXISTS sessions_userid_idx ON purpurbase.sessions (UserID);`)
+
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		_, err = SQLDB.Exec(`
+			CREATE TABLE IF NOT EXISTS purpurbase.api_keys (
+				ID VARCHAR(255) NOT NULL,
+				Name VARCHAR(255) NOT NULL,
+				KeyHash VARCHAR(255) NOT NULL,
+				Scopes TEXT,
+				CreatedBy VARCHAR(255),
+				CreatedAt TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+				RotatedAt TIMESTAMP,
+				LastUsedAt TIMESTAMP,
+				ExpiresAt TIMESTAMP,
+				PRIMARY KEY (ID)
+			);
+		`)
+
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		_, err = SQLDB.Exec(`
+			CREATE TABLE IF NOT EXISTS purpurbase.audit_log (
+				Seq BIGSERIAL,
+				ID VARCHAR(255) NOT NULL UNIQUE,
+				Type VARCHAR(255) NOT NULL,
+				UserID VARCHAR(255),
+				ActorID VARCHAR(255),
+				Email VARCHAR(255),
+				Success BOOLEAN NOT NULL DEFAULT FALSE,
+				IP VARCHAR(255),
+				UserAgent VARCHAR(512),
+				Details TEXT,
+				CreatedAt TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+				PRIMARY KEY (Seq)
+			);
+		`)
+
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		for _, index := range []string{
+			`CREATE INDEX IF NOT EXISTS audit_log_userid_idx ON purpurbase.audit_log (UserID);`,
+			`CREATE INDEX IF NOT EXISTS audit_log_type_idx ON purpurbase.audit_log (Type);`,
+			`CREATE INDEX IF NOT EXISTS audit_log_createdat_idx ON purpurbase.audit_log (CreatedAt);`,
+		} {
+			if _, err = SQLDB.Exec(index); err != nil {
+				log.Fatal(err)
+			}
+		}
+
+		_, err = SQLDB.Exec(`
+			CREATE TABLE IF NOT EXISTS purpurbase.files (
+				ID VARCHAR(255) NOT NULL,
+				Folder VARCHAR(255) NOT NULL,
+				FileName VARCHAR(255) NOT NULL,
+				OriginalName VARCHAR(512),
+				Size BIGINT NOT NULL DEFAULT 0,
+				ContentType VARCHAR(255),
+				Checksum VARCHAR(64),
+				UploaderID VARCHAR(255),
+				CreatedAt TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+				PRIMARY KEY (ID),
+				UNIQUE (Folder, FileName)
+			);
+		`)
+
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		_, err = SQLDB.Exec(`CREATE INDEX IF NOT EXISTS files_uploaderid_idx ON purpurbase.files (UploaderID);`)
+
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		migrateUserColumns(SQLDB, true)
+	}
+}
+
+// columns added to purpurbase.users after the first release, older databases get them on startup
+var userColumnMigrations = []struct {
+	name       string
+	definition string
+}{
+	{"Roles", "TEXT"},
+	{"Permissions", "TEXT"},
+	{"Disabled", "BOOLEAN NOT NULL DEFAULT FALSE"},
+	{"Anonymous", "BOOLEAN NOT NULL DEFAULT FALSE"},
+	{"Identities", "TEXT"},
+	{"PasswordResetToken", "VARCHAR(255)"},
+	{"PasswordResetExpiresAt", "TIMESTAMP NULL"},
+	{"PasswordHistory", "TEXT"},
+	{"PendingEmail", "VARCHAR(255)"},
+	{"EmailChangeToken", "VARCHAR(255)"},
+	{"EmailChangeExpiresAt", "TIMESTAMP NULL"},
+	{"EmailRevertToken", "VARCHAR(255)"},
+	{"EmailRevertAddress", "VARCHAR(255)"},
+	{"EmailRevertExpiresAt", "TIMESTAMP NULL"},
+	{"TOTPEnabled", "BOOLEAN NOT NULL DEFAULT FALSE"},
+	{"TOTPSecret", "VARCHAR(255)"},
+	{"TOTPRecoveryCodes", "TEXT"},
+	{"TOTPLastStep", "BIGINT NOT NULL DEFAULT 0"},
+	{"MagicLinkToken", "VARCHAR(255)"},
+	{"MagicLinkCode", "VARCHAR(255)"},
+	{"MagicLinkExpiresAt", "TIMESTAMP NULL"},
+	{"MagicLinkAttempts", "INT NOT NULL DEFAULT 0"},
+}
+
+func migrateUserColumns(SQLDB *sql.DB, postgres bool) {
+	for _, column := range userColumnMigrations {
+		addColumnIfMissing(SQLDB, postgres, "users", column.name, column.definition)
+	}
+	addColumnIfMissing(SQLDB, postgres, "sessions", "PreviousRefreshTokenHash", "VARCHAR(255)")
+}
+
+func addColumnIfMissing(SQLDB *sql.DB, postgres bool, table, column, definition string) {
+	query := "SELECT COUNT(*) FROM information_schema.columns WHERE table_schema = 'purpurbase' AND table_name = ? AND LOWER(column_name) = LOWER(?)"
+	if postgres {
+		query = "SELECT COUNT(*) FROM information_schema.columns WHERE table_schema = 'purpurbase' AND table_name = $1 AND LOWER(column_name) = LOWER($2)"
+	}
+
+	var count int
+	if err := SQLDB.QueryRow(query, table, column).Scan(&count); err != nil {
+		log.Fatal(err)
+	}
+	if count > 0 {
+		return
+	}
+
+	utils.DebugLogger("db", "adding missing column "+column+" to purpurbase."+table)
+	if _, err := SQLDB.Exec("ALTER TABLE purpurbase." + table + " ADD COLUMN " + column + " " + definition); err != nil {
+		log.Fatal(err)
+	}
 }
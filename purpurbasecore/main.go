@@ -9,6 +9,9 @@ import (
 	"github.com/froggy-12/purpurbase/config"
 	"github.com/froggy-12/purpurbase/database"
 	"github.com/froggy-12/purpurbase/internal"
+	"github.com/froggy-12/purpurbase/services/authentication"
+	"github.com/froggy-12/purpurbase/services/authentication/password"
+	"github.com/froggy-12/purpurbase/services/storage"
 	"github.com/froggy-12/purpurbase/utils"
 	_ "github.com/lib/pq"
 	"github.com/redis/go-redis/v9"
@@ -42,6 +45,26 @@ func (s *Purpurbase) Start() {
 
 	config.CheckConfigurations()
 
+	jwtKeys, err := utils.LoadJWTKeys(config.Configs.AuthenticationConfigurations.JWTKeys, config.Configs.AuthenticationConfigurations.JWTIssuer, config.Configs.AuthenticationConfigurations.JWTAudience)
+	if err != nil {
+		log.Fatal("failed to load jwt keys: " + err.Error())
+	}
+	utils.JWTKeys = jwtKeys
+
+	if path := config.Configs.AuthenticationConfigurations.PasswordPolicy.BreachedPasswordsFile; path != "" {
+		breached, err := password.LoadBreachedList(path)
+		if err != nil {
+			log.Fatal("failed to load breached passwords: " + err.Error())
+		}
+		authentication.BreachedPasswords = breached
+	}
+
+	files, err := storage.New(config.Configs.StorageConfigurations)
+	if err != nil {
+		log.Fatal("failed to set up file storage: " + err.Error())
+	}
+	storage.Files = files
+
 	utils.DebugLogger("main", "configurations are good to go...")
 
 	utils.DebugLogger("main", "Connecting with databases")
@@ -60,8 +83,9 @@ func (s *Purpurbase) Start() {
 		log.Fatal("Unsupported database")
 	}
 
-	if config.Configs.Features.ChatFunctionality {
-		utils.DebugLogger("main", "found chate functionality is true connecting to redis")
+	// chat and redis sessions can't start without the uri, log in protection uses redis whenever it is set
+	if config.Configs.DatabaseConfigurations.RedisConnectionURI != "" {
+		utils.DebugLogger("main", "found a redis connection uri connecting to redis")
 		RedisClient = database.ConnectToRedis(config.Configs.DatabaseConfigurations.RedisConnectionURI)
 		utils.DebugLogger("main", "connected with redis")
 	}
@@ -71,7 +95,7 @@ func (s *Purpurbase) Start() {
 
 	utils.DebugLogger("main", "Starting the API Server")
 	Server := api.NewServer(MongoClient, RedisClient, SQLClient)
-	err := Server.StartServer()
+	err = Server.StartServer()
 	if err != nil {
 		log.Fatal("failed to start api server: " + err.Error())
 	}
@@ -0,0 +1,75 @@
+package routes
+
+import (
+	"github.com/froggy-12/purpurbase/api/middlewares"
+	"github.com/froggy-12/purpurbase/services/authentication"
+	"github.com/froggy-12/purpurbase/store"
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+)
+
+func AdminRoutes(router fiber.Router, userStore store.UserStore, sessionStore store.SessionStore, apiKeyStore store.APIKeyStore, attempts store.LoginAttemptStore, auditStore store.AuditStore, removeFiles func(userID string) error) {
+	validator := validator.New()
+
+	router.Get("/users", func(c *fiber.Ctx) error {
+		return authentication.AdminListUsers(c, userStore)
+	})
+	router.Get("/users/:id", func(c *fiber.Ctx) error {
+		return authentication.AdminGetUser(c, userStore)
+	})
+	router.Post("/users/:id/disable", func(c *fiber.Ctx) error {
+		return authentication.AdminSetDisabled(c, userStore, sessionStore, true)
+	})
+	router.Post("/users/:id/enable", func(c *fiber.Ctx) error {
+		return authentication.AdminSetDisabled(c, userStore, sessionStore, false)
+	})
+	router.Post("/users/:id/unlock", func(c *fiber.Ctx) error {
+		return authentication.AdminUnlockUser(c, userStore, attempts)
+	})
+	router.Post("/users/:id/verify", func(c *fiber.Ctx) error {
+		return authentication.AdminVerifyUser(c, userStore)
+	})
+	router.Post("/users/:id/reset-password", func(c *fiber.Ctx) error {
+		return authentication.AdminResetPassword(c, userStore, sessionStore)
+	})
+	router.Put("/users/:id/raw-data", func(c *fiber.Ctx) error {
+		return authentication.AdminUpdateRawData(c, userStore)
+	})
+	router.Put("/users/:id/roles", func(c *fiber.Ctx) error {
+		return authentication.AdminSetRoles(c, userStore, *validator)
+	})
+	router.Delete("/users/:id", func(c *fiber.Ctx) error {
+		return authentication.AdminDeleteUser(c, userStore, sessionStore, removeFiles)
+	})
+
+	router.Get("/users/:id/sessions", func(c *fiber.Ctx) error {
+		return authentication.AdminListUserSessions(c, sessionStore)
+	})
+	router.Delete("/users/:id/sessions", func(c *fiber.Ctx) error {
+		return authentication.AdminRevokeUserSessions(c, sessionStore)
+	})
+	router.Delete("/sessions/:id", func(c *fiber.Ctx) error {
+		return authentication.AdminRevokeSession(c, sessionStore)
+	})
+
+	router.Get("/audit-log", func(c *fiber.Ctx) error {
+		return authentication.AdminListAuditEvents(c, auditStore)
+	})
+	router.Get("/audit-log/export", func(c *fiber.Ctx) error {
+		return authentication.AdminExportAuditEvents(c, auditStore)
+	})
+
+	// api keys can't manage api keys, otherwise a leaked key could mint new ones
+	router.Get("/api-keys", middlewares.RequireUser, func(c *fiber.Ctx) error {
+		return authentication.ListAPIKeys(c, apiKeyStore)
+	})
+	router.Post("/api-keys", middlewares.RequireUser, func(c *fiber.Ctx) error {
+		return authentication.CreateAPIKey(c, apiKeyStore, *validator)
+	})
+	router.Post("/api-keys/:id/rotate", middlewares.RequireUser, func(c *fiber.Ctx) error {
+		return authentication.RotateAPIKey(c, apiKeyStore)
+	})
+	router.Delete("/api-keys/:id", middlewares.RequireUser, func(c *fiber.Ctx) error {
+		return authentication.RevokeAPIKey(c, apiKeyStore)
+	})
+}
@@ -0,0 +1,91 @@
+package routes
+
+import (
+	"github.com/froggy-12/purpurbase/services/authentication"
+	"github.com/froggy-12/purpurbase/services/authentication/oauth"
+	"github.com/froggy-12/purpurbase/store"
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+)
+
+func AuthRoutes(router fiber.Router, userStore store.UserStore, sessionStore store.SessionStore, attempts store.LoginAttemptStore) {
+
+	validator := validator.New()
+
+	router.Post("/create-user", func(c *fiber.Ctx) error {
+		return authentication.CreateUserWithEmailAndPassword(c, userStore, sessionStore, *validator)
+	})
+
+	router.Post("/anonymous", func(c *fiber.Ctx) error {
+		return authentication.CreateAnonymousUser(c, userStore, sessionStore)
+	})
+
+	router.Post("/log-in", func(c *fiber.Ctx) error {
+		return authentication.LogInWithEmailAndPassword(c, userStore, sessionStore, attempts, *validator)
+	})
+
+	router.Post("/log-in/totp", func(c *fiber.Ctx) error {
+		return authentication.LogInWithTOTP(c, userStore, sessionStore, attempts, *validator)
+	})
+
+	router.Post("/refresh", func(c *fiber.Ctx) error {
+		return authentication.RefreshSession(c, userStore, sessionStore)
+	})
+
+	router.Post("/send-verification-email", func(c *fiber.Ctx) error {
+		return authentication.SendVerificationEmail(c, userStore)
+	})
+
+	router.Get("/verified", func(c *fiber.Ctx) error {
+		return authentication.VerifyEmail(c, userStore, *validator)
+	})
+
+	router.Post("/request-password-reset", func(c *fiber.Ctx) error {
+		return authentication.RequestPasswordReset(c, userStore, *validator)
+	})
+	router.Post("/reset-password", func(c *fiber.Ctx) error {
+		return authentication.ResetPassword(c, userStore, sessionStore, *validator)
+	})
+
+	router.Get("/email-change/confirm", func(c *fiber.Ctx) error {
+		return authentication.ConfirmEmailChange(c, userStore, *validator)
+	})
+	router.Post("/email-change/confirm", func(c *fiber.Ctx) error {
+		return authentication.ConfirmEmailChange(c, userStore, *validator)
+	})
+	router.Get("/email-change/revert", func(c *fiber.Ctx) error {
+		return authentication.RevertEmailChange(c, userStore, sessionStore, *validator)
+	})
+	router.Post("/email-change/revert", func(c *fiber.Ctx) error {
+		return authentication.RevertEmailChange(c, userStore, sessionStore, *validator)
+	})
+
+	router.Get("/unlock", func(c *fiber.Ctx) error {
+		return authentication.UnlockAccount(c, attempts, *validator)
+	})
+	router.Post("/unlock", func(c *fiber.Ctx) error {
+		return authentication.UnlockAccount(c, attempts, *validator)
+	})
+
+	router.Post("/magic-link", func(c *fiber.Ctx) error {
+		return authentication.RequestMagicLink(c, userStore, attempts, *validator)
+	})
+	router.Get("/magic-link/verify", func(c *fiber.Ctx) error {
+		return authentication.VerifyMagicLink(c, userStore, sessionStore, attempts, *validator)
+	})
+	router.Post("/magic-link/verify", func(c *fiber.Ctx) error {
+		return authentication.VerifyMagicLink(c, userStore, sessionStore, attempts, *validator)
+	})
+
+	router.Get("/check-email-availability", func(c *fiber.Ctx) error {
+		return authentication.CheckIsEmailAvailable(c, userStore, *validator)
+	})
+	router.Get("/check-username-availability", func(c *fiber.Ctx) error {
+		return authentication.CheckIsUsernameAvailable(c, userStore, *validator)
+	})
+
+	router.Get("/oauth/:provider/start", oauth.StartLogin)
+	router.Get("/oauth/:provider/callback", func(c *fiber.Ctx) error {
+		return authentication.OAuthCallback(c, userStore, sessionStore)
+	})
+}
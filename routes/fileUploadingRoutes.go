@@ -1,19 +1,65 @@
 package routes
 
 import (
+	"github.com/froggy-12/purpurbase/api/middlewares"
 	"github.com/froggy-12/purpurbase/services/upload"
+	"github.com/froggy-12/purpurbase/store"
 	"github.com/gofiber/fiber/v2"
 )
 
-func FileUploadingRoutes(router fiber.Router) {
-	router.Post("/upload/image/single", upload.HandleUploadImageFile)
-	router.Post("/upload/image/multi", upload.HandleUploadMultipleImageFile)
-	router.Post("/upload/music/single", upload.HandleUploadSingleMusicFile)
-	router.Post("/upload/music/multi", upload.HandleUploadMultipleMusicFile)
-	router.Post("/upload/video/single", upload.HandleUploadSingleVideoFile)
-	router.Post("/upload/video/multi", upload.HandleUploadMultiVideoFile)
-	router.Post("/upload/video/multi", upload.HandleUploadMultiVideoFile)
-	router.Post("/upload/any/single", upload.HandleAnyFormatSingleFile)
-	router.Post("/upload/any/multi", upload.HandleAnyFormatMultiFile)
-	router.Delete("/deletefile", upload.HandleDeleteFile)
+// fileHandlers reads the principal on unprotected routes too so uploads record who made them
+func fileHandlers(permissions []string, handler fiber.Handler) []fiber.Handler {
+	if len(permissions) == 0 {
+		return []fiber.Handler{middlewares.OptionalJWTTokenMiddleware, handler}
+	}
+	return middlewares.Protect(nil, permissions, handler)
+}
+
+func FileUploadingRoutes(router fiber.Router, fileStore store.FileStore, protected bool) {
+	var uploadPermission, deletePermission []string
+	if protected {
+		uploadPermission = []string{"files:upload"}
+		deletePermission = []string{"files:delete"}
+	}
+
+	router.Post("/upload/image/single", fileHandlers(uploadPermission, func(c *fiber.Ctx) error {
+		return upload.HandleUploadImageFile(c, fileStore)
+	})...)
+	router.Post("/upload/image/multi", fileHandlers(uploadPermission, func(c *fiber.Ctx) error {
+		return upload.HandleUploadMultipleImageFile(c, fileStore)
+	})...)
+	router.Post("/upload/music/single", fileHandlers(uploadPermission, func(c *fiber.Ctx) error {
+		return upload.HandleUploadSingleMusicFile(c, fileStore)
+	})...)
+	router.Post("/upload/music/multi", fileHandlers(uploadPermission, func(c *fiber.Ctx) error {
+		return upload.HandleUploadMultipleMusicFile(c, fileStore)
+	})...)
+	router.Post("/upload/video/single", fileHandlers(uploadPermission, func(c *fiber.Ctx) error {
+		return upload.HandleUploadSingleVideoFile(c, fileStore)
+	})...)
+	router.Post("/upload/video/multi", fileHandlers(uploadPermission, func(c *fiber.Ctx) error {
+		return upload.HandleUploadMultiVideoFile(c, fileStore)
+	})...)
+	router.Post("/upload/any/single", fileHandlers(uploadPermission, func(c *fiber.Ctx) error {
+		return upload.HandleAnyFormatSingleFile(c, fileStore)
+	})...)
+	router.Post("/upload/any/multi", fileHandlers(uploadPermission, func(c *fiber.Ctx) error {
+		return upload.HandleAnyFormatMultiFile(c, fileStore)
+	})...)
+	router.Post("/upload/bucket/:bucket/single", fileHandlers(uploadPermission, func(c *fiber.Ctx) error {
+		return upload.HandleUploadBucketFile(c, fileStore)
+	})...)
+	router.Post("/upload/bucket/:bucket/multi", fileHandlers(uploadPermission, func(c *fiber.Ctx) error {
+		return upload.HandleUploadMultipleBucketFiles(c, fileStore)
+	})...)
+	router.Delete("/deletefile", fileHandlers(deletePermission, func(c *fiber.Ctx) error {
+		return upload.HandleDeleteFile(c, fileStore)
+	})...)
+
+	router.Get("/files", middlewares.CheckJWTTokenMiddleware, func(c *fiber.Ctx) error {
+		return upload.ListFiles(c, fileStore)
+	})
+	router.Get("/files/:id", middlewares.CheckJWTTokenMiddleware, func(c *fiber.Ctx) error {
+		return upload.GetFile(c, fileStore)
+	})
 }
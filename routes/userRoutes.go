@@ -0,0 +1,69 @@
+package routes
+
+import (
+	"github.com/froggy-12/purpurbase/services/authentication"
+	"github.com/froggy-12/purpurbase/store"
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+)
+
+func UserRoutes(router fiber.Router, userStore store.UserStore, sessionStore store.SessionStore, attempts store.LoginAttemptStore, removeFiles func(userID string) error) {
+	validator := validator.New()
+	router.Get("/get-user", func(c *fiber.Ctx) error {
+		return authentication.GetUser(c, userStore)
+	})
+	router.Put("/update-username", func(c *fiber.Ctx) error {
+		return authentication.UpdateUserName(c, userStore, sessionStore, *validator)
+	})
+	router.Put("/update-user-info", func(c *fiber.Ctx) error {
+		return authentication.UpdateUser(c, userStore)
+	})
+	router.Put("/update-email", func(c *fiber.Ctx) error {
+		return authentication.ChangeEmail(c, userStore, sessionStore, *validator)
+	})
+	router.Put("/append-raw-data", func(c *fiber.Ctx) error {
+		return authentication.AddRawData(c, userStore)
+	})
+	router.Put("/change-password", func(c *fiber.Ctx) error {
+		return authentication.ChangePassword(c, userStore, sessionStore, attempts, *validator)
+	})
+	router.Post("/upgrade-account", func(c *fiber.Ctx) error {
+		return authentication.UpgradeAnonymousUser(c, userStore, *validator)
+	})
+	router.Get("/identities", func(c *fiber.Ctx) error {
+		return authentication.ListIdentities(c, userStore)
+	})
+	router.Post("/identities/password", func(c *fiber.Ctx) error {
+		return authentication.LinkPassword(c, userStore, sessionStore, *validator)
+	})
+	router.Delete("/identities/:provider", func(c *fiber.Ctx) error {
+		return authentication.UnlinkIdentity(c, userStore, sessionStore)
+	})
+	router.Delete("/delete-user", func(c *fiber.Ctx) error {
+		return authentication.DeleteUser(c, userStore, sessionStore, removeFiles, *validator)
+	})
+	router.Post("/totp/enroll", func(c *fiber.Ctx) error {
+		return authentication.EnrollTOTP(c, userStore, sessionStore, *validator)
+	})
+	router.Post("/totp/confirm", func(c *fiber.Ctx) error {
+		return authentication.ConfirmTOTP(c, userStore, *validator)
+	})
+	router.Post("/totp/recovery-codes", func(c *fiber.Ctx) error {
+		return authentication.RegenerateRecoveryCodes(c, userStore, *validator)
+	})
+	router.Post("/totp/disable", func(c *fiber.Ctx) error {
+		return authentication.DisableTOTP(c, userStore, sessionStore, *validator)
+	})
+	router.Get("/log-out", func(c *fiber.Ctx) error {
+		return authentication.LogOut(c, sessionStore)
+	})
+	router.Post("/log-out-everywhere", func(c *fiber.Ctx) error {
+		return authentication.LogOutEverywhere(c, sessionStore)
+	})
+	router.Get("/sessions", func(c *fiber.Ctx) error {
+		return authentication.ListSessions(c, sessionStore)
+	})
+	router.Delete("/sessions/:id", func(c *fiber.Ctx) error {
+		return authentication.RevokeSession(c, sessionStore)
+	})
+}
@@ -0,0 +1,10 @@
+package routes
+
+import (
+	"github.com/froggy-12/purpurbase/services/authentication"
+	"github.com/gofiber/fiber/v2"
+)
+
+func WellKnownRoutes(router fiber.Router) {
+	router.Get("/jwks.json", authentication.JWKS)
+}
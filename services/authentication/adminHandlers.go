@@ -0,0 +1,277 @@
+package authentication
+
+import (
+	"maps"
+	"strconv"
+	"strings"
+
+	"github.com/froggy-12/purpurbase/config"
+	"github.com/froggy-12/purpurbase/store"
+	"github.com/froggy-12/purpurbase/types"
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+)
+
+const (
+	adminDefaultPageSize = 50
+	adminMaxPageSize     = 500
+)
+
+// boolQuery reads an optional true/false query parameter
+func boolQuery(c *fiber.Ctx, key string) (*bool, error) {
+	value := c.Query(key)
+	if value == "" {
+		return nil, nil
+	}
+	parsed, err := strconv.ParseBool(value)
+	if err != nil {
+		return nil, err
+	}
+	return &parsed, nil
+}
+
+// adminFindUser answers with 404 when the user of the :id param doesn't exist, found is false when a response was sent
+func adminFindUser(c *fiber.Ctx, userStore store.UserStore) (types.UserRecord, bool, error) {
+	user, err := userStore.FindUserByID(c.Params("id"))
+	if err == store.ErrUserNotFound {
+		return user, false, c.Status(fiber.StatusNotFound).JSON(types.ErrorResponse{Error: "User not found"})
+	}
+	if err != nil {
+		return user, false, c.Status(fiber.StatusInternalServerError).JSON(types.ErrorResponse{Error: "Something went wrong: " + err.Error()})
+	}
+	return user, true, nil
+}
+
+// AdminListUsers supports ?limit=&offset=&search=&role=&verified=&disabled=&sort=&order=asc|desc
+func AdminListUsers(c *fiber.Ctx, userStore store.UserStore) error {
+	query := store.UserQuery{
+		Search: c.Query("search"),
+		Role:   c.Query("role"),
+		SortBy: c.Query("sort", "createdAt"),
+		Desc:   c.Query("order") == "desc",
+		Limit:  c.QueryInt("limit", adminDefaultPageSize),
+		Offset: c.QueryInt("offset", 0),
+	}
+
+	var err error
+	if query.Verified, err = boolQuery(c, "verified"); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(types.ErrorResponse{Error: "verified should be true or false"})
+	}
+	if query.Disabled, err = boolQuery(c, "disabled"); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(types.ErrorResponse{Error: "disabled should be true or false"})
+	}
+	if query.Anonymous, err = boolQuery(c, "anonymous"); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(types.ErrorResponse{Error: "anonymous should be true or false"})
+	}
+
+	if query.Limit <= 0 || query.Limit > adminMaxPageSize {
+		return c.Status(fiber.StatusBadRequest).JSON(types.ErrorResponse{Error: "limit should be between 1 and " + strconv.Itoa(adminMaxPageSize)})
+	}
+	if query.Offset < 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(types.ErrorResponse{Error: "offset can not be negative"})
+	}
+
+	users, total, err := userStore.ListUsers(query)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(types.ErrorResponse{Error: "Failed to list users: " + err.Error()})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(types.HTTPSuccessResponse{
+		Message: "Users have been found successfully",
+		Data:    map[string]any{"users": users, "total": total, "limit": query.Limit, "offset": query.Offset},
+	})
+}
+
+func AdminGetUser(c *fiber.Ctx, userStore store.UserStore) error {
+	user, found, err := adminFindUser(c, userStore)
+	if !found {
+		return err
+	}
+
+	return c.Status(fiber.StatusOK).JSON(types.HTTPSuccessResponse{
+		Message: "User has been Found successfully",
+		Data:    map[string]any{"user": user},
+	})
+}
+
+// AdminSetDisabled disables or enables the account, disabling also ends every session of the user
+func AdminSetDisabled(c *fiber.Ctx, userStore store.UserStore, sessionStore store.SessionStore, disabled bool) error {
+	user, found, err := adminFindUser(c, userStore)
+	if !found {
+		return err
+	}
+
+	user.Disabled = disabled
+	if err := userStore.UpdateUser(user); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(types.ErrorResponse{Error: "Failed to update user: " + err.Error()})
+	}
+
+	if !disabled {
+		auditRequest(c, AuditEnabled, user, true, map[string]string{"via": "admin"})
+		return c.Status(fiber.StatusOK).JSON(types.HTTPSuccessResponse{Message: "User has been enabled"})
+	}
+	auditRequest(c, AuditDisabled, user, true, map[string]string{"via": "admin"})
+
+	if err := sessionStore.DeleteUserSessions(user.ID); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(types.ErrorResponse{Error: "User has been disabled but failed to revoke sessions: " + err.Error()})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(types.HTTPSuccessResponse{Message: "User has been disabled"})
+}
+
+func AdminVerifyUser(c *fiber.Ctx, userStore store.UserStore) error {
+	user, found, err := adminFindUser(c, userStore)
+	if !found {
+		return err
+	}
+
+	err = userStore.SetVerified(user.ID, true)
+	if err == store.ErrUserNotFound {
+		return c.Status(fiber.StatusNotFound).JSON(types.ErrorResponse{Error: "User not found"})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(types.ErrorResponse{Error: "Failed to update user verification status"})
+	}
+	auditRequest(c, AuditVerified, user, true, map[string]string{"via": "admin"})
+
+	return c.Status(fiber.StatusOK).JSON(types.HTTPSuccessResponse{Message: "Email has been marked as verified"})
+}
+
+// AdminResetPassword sets the given password, without one the user gets a password reset email
+func AdminResetPassword(c *fiber.Ctx, userStore store.UserStore, sessionStore store.SessionStore) error {
+	var body struct {
+		NewPassword string `json:"newPassword"`
+	}
+
+	if err := c.BodyParser(&body); err != nil && len(c.Body()) > 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(types.ErrorResponse{Error: "Invalid request body: " + err.Error()})
+	}
+
+	user, found, err := adminFindUser(c, userStore)
+	if !found {
+		return err
+	}
+
+	if body.NewPassword == "" {
+		if !config.Configs.SMTPConfigurations.SMTPEnabled {
+			return c.Status(fiber.StatusBadRequest).JSON(types.ErrorResponse{Error: "SMTP is not configured so a new password has to be given"})
+		}
+		if err := sendPasswordReset(userStore, user); err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(types.ErrorResponse{Error: "failed to send password reset email: " + err.Error()})
+		}
+		return c.Status(fiber.StatusAccepted).JSON(types.HTTPSuccessResponse{Message: "Password reset email has been sent to " + user.Email})
+	}
+
+	if err := setPassword(&user, body.NewPassword); err != nil {
+		return passwordRejected(c, err)
+	}
+
+	user.PasswordResetToken = ""
+	if err := userStore.UpdateUser(user); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(types.ErrorResponse{Error: "Something went wrong failed to update password: " + err.Error()})
+	}
+	auditRequest(c, AuditPasswordChanged, user, true, map[string]string{"via": "admin"})
+
+	if err := sessionStore.DeleteUserSessions(user.ID); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(types.ErrorResponse{Error: "Password has been reset but failed to revoke sessions: " + err.Error()})
+	}
+
+	return c.Status(fiber.StatusAccepted).JSON(types.HTTPSuccessResponse{Message: "Password has been reset"})
+}
+
+// AdminUpdateRawData replaces the raw data of the user, ?merge=true keeps the keys that are not sent
+func AdminUpdateRawData(c *fiber.Ctx, userStore store.UserStore) error {
+	var body struct {
+		RawData map[string]any `json:"rawData"`
+	}
+
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(types.ErrorResponse{Error: err.Error()})
+	}
+
+	user, found, err := adminFindUser(c, userStore)
+	if !found {
+		return err
+	}
+
+	if c.QueryBool("merge") {
+		maps.Copy(user.RawData, body.RawData)
+	} else {
+		user.RawData = body.RawData
+	}
+	if user.RawData == nil {
+		user.RawData = map[string]any{}
+	}
+
+	if err := userStore.UpdateUser(user); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(types.ErrorResponse{Error: "Failed to update user data: " + err.Error()})
+	}
+	auditRequest(c, AuditRawDataChanged, user, true, map[string]string{"merge": strconv.FormatBool(c.QueryBool("merge"))})
+
+	return c.Status(fiber.StatusOK).JSON(types.HTTPSuccessResponse{Message: "Data updated successfully", Data: user.RawData})
+}
+
+func AdminSetRoles(c *fiber.Ctx, userStore store.UserStore, validator validator.Validate) error {
+	var body struct {
+		Roles       []string `json:"roles" validate:"required,dive,required"`
+		Permissions []string `json:"permissions" validate:"dive,required"`
+	}
+
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(types.ErrorResponse{Error: "Invalid request body: " + err.Error()})
+	}
+
+	if err := validator.Struct(&body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(types.ErrorResponse{Error: "Invalid request body: " + err.Error()})
+	}
+
+	user, found, err := adminFindUser(c, userStore)
+	if !found {
+		return err
+	}
+
+	previousRoles, previousPermissions := user.Roles, user.Permissions
+	user.Roles = body.Roles
+	user.Permissions = body.Permissions
+	if err := userStore.UpdateUser(user); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(types.ErrorResponse{Error: "Failed to update user: " + err.Error()})
+	}
+	auditRequest(c, AuditRolesChanged, user, true, map[string]string{
+		"previousRoles":       strings.Join(previousRoles, ","),
+		"roles":               strings.Join(user.Roles, ","),
+		"previousPermissions": strings.Join(previousPermissions, ","),
+		"permissions":         strings.Join(user.Permissions, ","),
+	})
+
+	return c.Status(fiber.StatusOK).JSON(types.HTTPSuccessResponse{
+		Message: "Roles have been updated, they are part of the next access token of the user",
+		Data:    map[string]any{"roles": user.Roles, "permissions": user.Permissions},
+	})
+}
+
+// AdminDeleteUser deletes the user of the :id param, removeFiles deletes the uploads of the user first
+func AdminDeleteUser(c *fiber.Ctx, userStore store.UserStore, sessionStore store.SessionStore, removeFiles func(userID string) error) error {
+	user, found, err := adminFindUser(c, userStore)
+	if !found {
+		return err
+	}
+
+	if err := removeFiles(user.ID); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(types.ErrorResponse{Error: "Failed to delete the files of the user: " + err.Error()})
+	}
+
+	err = userStore.DeleteUser(user.ID)
+	if err == store.ErrUserNotFound {
+		return c.Status(fiber.StatusNotFound).JSON(types.ErrorResponse{Error: "User not found"})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(types.ErrorResponse{Error: "Failed to delete user: " + err.Error()})
+	}
+	auditRequest(c, AuditDeleted, user, true, map[string]string{"via": "admin"})
+
+	if err := sessionStore.DeleteUserSessions(user.ID); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(types.ErrorResponse{Error: "User has been deleted but failed to revoke sessions: " + err.Error()})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(types.HTTPSuccessResponse{Message: "User has been deleted successfully"})
+}
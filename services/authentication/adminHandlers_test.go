@@ -0,0 +1,53 @@
+package authentication
+
+import (
+	"testing"
+
+	"github.com/froggy-12/purpurbase/store"
+	"github.com/froggy-12/purpurbase/types"
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestAdminAuditEventsNameTheUser(t *testing.T) {
+	auditStore := store.NewMemoryAuditStore()
+	previous := AuditLog
+	AuditLog = auditStore
+	t.Cleanup(func() { AuditLog = previous })
+
+	userStore := store.NewMemoryUserStore()
+	sessionStore := store.NewMemorySessionStore()
+	if err := userStore.CreateUser(types.UserRecord{ID: "user", Email: "user@example.com", UserName: "user"}); err != nil {
+		t.Fatal(err)
+	}
+
+	app := fiber.New()
+	app.Post("/users/:id/verify", func(c *fiber.Ctx) error { return AdminVerifyUser(c, userStore) })
+	app.Delete("/users/:id", func(c *fiber.Ctx) error {
+		return AdminDeleteUser(c, userStore, sessionStore, func(string) error { return nil })
+	})
+
+	for _, route := range []struct{ method, path string }{{"POST", "/users/missing/verify"}, {"DELETE", "/users/missing"}} {
+		if code := send(t, app, route.method, route.path, nil); code != fiber.StatusNotFound {
+			t.Errorf("%s %s answered %d, want 404", route.method, route.path, code)
+		}
+	}
+	if code := send(t, app, "POST", "/users/user/verify", nil); code != fiber.StatusOK {
+		t.Errorf("verifying answered %d, want 200", code)
+	}
+	if code := send(t, app, "DELETE", "/users/user", nil); code != fiber.StatusOK {
+		t.Errorf("deleting answered %d, want 200", code)
+	}
+
+	events, _, err := auditStore.ListAuditEvents(store.AuditQuery{Limit: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 2 {
+		t.Fatalf("recorded %d events, want 2", len(events))
+	}
+	for _, event := range events {
+		if event.UserID != "user" || event.Email != "user@example.com" {
+			t.Errorf("%s event names %q %q, want the user and their email", event.Type, event.UserID, event.Email)
+		}
+	}
+}
@@ -0,0 +1,221 @@
+package authentication
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/froggy-12/purpurbase/config"
+	"github.com/froggy-12/purpurbase/services/authentication/oauth"
+	"github.com/froggy-12/purpurbase/services/smtpconfigs"
+	"github.com/froggy-12/purpurbase/store"
+	"github.com/froggy-12/purpurbase/types"
+	"github.com/froggy-12/purpurbase/utils"
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+// guests get a placeholder email and username because both are unique in every store,
+// .invalid is a reserved domain so nothing is ever sent to it
+const guestEmailDomain = "@anonymous.invalid"
+
+func anonymousAccountTTL() time.Duration {
+	days := config.Configs.AuthenticationConfigurations.AnonymousAccounts.TTL
+	if days <= 0 {
+		days = 30
+	}
+	return time.Duration(days) * 24 * time.Hour
+}
+
+func guestPurgeInterval() time.Duration {
+	minutes := config.Configs.AuthenticationConfigurations.AnonymousAccounts.PurgeInterval
+	if minutes <= 0 {
+		minutes = 60
+	}
+	return time.Duration(minutes) * time.Minute
+}
+
+// CreateAnonymousUser creates a guest user and logs it in, guests can use every /api/data route
+func CreateAnonymousUser(c *fiber.Ctx, userStore store.UserStore, sessionStore store.SessionStore) error {
+	if !config.Configs.AuthenticationConfigurations.AnonymousAccounts.Enabled {
+		return c.Status(fiber.StatusBadRequest).JSON(types.ErrorResponse{Error: "Anonymous accounts are not configured or turned off please check again and restart the app"})
+	}
+
+	if principal, err := ReadPrincipal(c, sessionStore, nil); err == nil && principal.UserID != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(types.ErrorResponse{Error: "Valid Token Found Please lot out first then try again"})
+	}
+
+	id := uuid.New().String()
+	guest := types.UserRecord{
+		ID:                id,
+		UserName:          "guest-" + id,
+		FirstName:         "Guest",
+		Email:             id + guestEmailDomain,
+		CreatedAt:         time.Now(),
+		UpdatedAt:         time.Now(),
+		LastLoggedIn:      time.Now(),
+		VerificationToken: uuid.New().String(),
+		RawData:           map[string]any{},
+		Roles:             defaultRoles(),
+		Anonymous:         true,
+	}
+
+	if err := userStore.CreateUser(guest); err != nil {
+		return c.Status(fiber.StatusBadGateway).JSON(types.ErrorResponse{Error: "failed to create new user into the database: " + err.Error()})
+	}
+	auditRequest(c, AuditSignedUp, guest, true, map[string]string{"method": "anonymous"})
+
+	tokens, err := startSession(c, userStore, sessionStore, guest)
+	if err != nil {
+		return sessionFailed(c, err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(types.HTTPSuccessResponse{
+		Message: "Guest user has been created and logged in",
+		Data:    withTokens(c, tokens, map[string]any{"userID": guest.ID, "anonymous": true}),
+	})
+}
+
+// UpgradeAnonymousUser turns the logged in guest into a full account with an email and password,
+// the user keeps its id so its raw data, sessions and files stay with it
+func UpgradeAnonymousUser(c *fiber.Ctx, userStore store.UserStore, validator validator.Validate) error {
+	var body struct {
+		Email     string `json:"email" validate:"required,email"`
+		Password  string `json:"password" validate:"required"`
+		UserName  string `json:"username"`
+		FirstName string `json:"firstName"`
+		LastName  string `json:"lastName"`
+	}
+
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(types.ErrorResponse{Error: "Invalid request body: " + err.Error()})
+	}
+
+	if err := validator.Struct(&body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(types.ErrorResponse{Error: "Invalid request body: " + err.Error()})
+	}
+
+	userId, _ := c.Locals("userId").(string)
+	user, err := userStore.FindUserByID(userId)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(types.ErrorResponse{Error: "User Not Found: " + err.Error()})
+	}
+	if !user.Anonymous {
+		return c.Status(fiber.StatusBadRequest).JSON(types.ErrorResponse{Error: "Only guest users can be upgraded"})
+	}
+
+	user.Email = body.Email
+	if body.UserName != "" {
+		user.UserName = body.UserName
+	}
+	if body.FirstName != "" {
+		user.FirstName = body.FirstName
+	}
+	if body.LastName != "" {
+		user.LastName = body.LastName
+	}
+
+	if err := setPassword(&user, body.Password); err != nil {
+		return passwordRejected(c, err)
+	}
+
+	user.Anonymous = false
+	user.Verified = false
+	user.VerificationToken = uuid.New().String()
+
+	err = userStore.UpdateUser(user)
+	if err == store.ErrUserAlreadyExists {
+		return c.Status(fiber.StatusBadRequest).JSON(types.ErrorResponse{Error: "User already exist"})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(types.ErrorResponse{Error: "Something went wrong failed to upgrade user: " + err.Error()})
+	}
+	auditRequest(c, AuditUpgraded, user, true, map[string]string{"method": "password"})
+
+	if config.Configs.AuthenticationConfigurations.SendEmailAfterSignUpWithToken && config.Configs.AuthenticationConfigurations.EmailVerification && config.Configs.SMTPConfigurations.SMTPEnabled {
+		if err := smtpconfigs.SendVerificationEmail(user.Email, user.VerificationToken); err != nil {
+			utils.DebugLogger("anonymous", "failed to send verification email to "+user.Email+": "+err.Error())
+		}
+	}
+
+	return c.Status(fiber.StatusOK).JSON(types.HTTPSuccessResponse{
+		Message: "Guest user has been upgraded to a full account",
+		Data:    map[string]any{"userID": user.ID},
+	})
+}
+
+// currentGuest returns the guest logged in on this request, if any
+func currentGuest(c *fiber.Ctx, userStore store.UserStore, sessionStore store.SessionStore) (types.UserRecord, bool) {
+	principal, err := ReadPrincipal(c, sessionStore, nil)
+	if err != nil || principal.UserID == "" {
+		return types.UserRecord{}, false
+	}
+	user, err := userStore.FindUserByID(principal.UserID)
+	if err != nil || !user.Anonymous {
+		return types.UserRecord{}, false
+	}
+	return user, true
+}
+
+// upgradeGuestWithOAuth links the provider profile to the guest instead of creating a new user
+func upgradeGuestWithOAuth(userStore store.UserStore, guest types.UserRecord, profile oauth.Profile, identity types.Identity) (types.UserRecord, error) {
+	guest.Email = profile.Email
+	guest.FirstName = profile.FirstName
+	guest.LastName = profile.LastName
+	if profile.Picture != "" {
+		guest.ProfilePicture = profile.Picture
+	}
+	guest.UserName = pickUserName(userStore, profile)
+	guest.Identities = append(guest.Identities, identity)
+	guest.Anonymous = false
+	guest.Verified = true
+
+	return guest, userStore.UpdateUser(guest)
+}
+
+// PurgeStaleGuests deletes the guests that haven't logged in or refreshed their session within the ttl,
+// removeFiles deletes the uploads of a guest before the guest is deleted
+func PurgeStaleGuests(userStore store.UserStore, sessionStore store.SessionStore, removeFiles func(userID string) error) (int, error) {
+	anonymous := true
+	query := store.UserQuery{Anonymous: &anonymous, LastLoggedInBefore: time.Now().Add(-anonymousAccountTTL()), Limit: 100}
+
+	purged := 0
+	for {
+		guests, _, err := userStore.ListUsers(query)
+		if err != nil || len(guests) == 0 {
+			return purged, err
+		}
+
+		for _, guest := range guests {
+			if err := removeFiles(guest.ID); err != nil {
+				return purged, err
+			}
+			if err := sessionStore.DeleteUserSessions(guest.ID); err != nil {
+				return purged, err
+			}
+			if err := userStore.DeleteUser(guest.ID); err != nil && err != store.ErrUserNotFound {
+				return purged, err
+			}
+			audit(types.AuditEvent{Type: AuditDeleted, UserID: guest.ID, Email: guest.Email, Success: true, Details: map[string]string{"via": "guest_purge"}})
+			purged++
+		}
+	}
+}
+
+// StartGuestPurger runs PurgeStaleGuests every purgeInterval for as long as the server runs
+func StartGuestPurger(userStore store.UserStore, sessionStore store.SessionStore, removeFiles func(userID string) error) {
+	go func() {
+		ticker := time.NewTicker(guestPurgeInterval())
+		defer ticker.Stop()
+
+		for range ticker.C {
+			purged, err := PurgeStaleGuests(userStore, sessionStore, removeFiles)
+			if err != nil {
+				utils.DebugLogger("anonymous", "failed to purge stale guests: "+err.Error())
+			}
+			if purged > 0 {
+				utils.DebugLogger("anonymous", "purged "+strconv.Itoa(purged)+" stale guests")
+			}
+		}
+	}()
+}
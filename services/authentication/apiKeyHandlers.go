@@ -0,0 +1,151 @@
+package authentication
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/froggy-12/purpurbase/store"
+	"github.com/froggy-12/purpurbase/types"
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+// APIKeyPrefix starts every server api key so it can be told apart from an access token
+const APIKeyPrefix = "pbk_"
+
+// ServiceRole is the only role of a request made with a server api key
+const ServiceRole = "service"
+
+var ErrInvalidAPIKey = errors.New("Invalid API key")
+
+// the last use is only written once a minute so busy keys don't cause a write per request
+const apiKeyTouchInterval = time.Minute
+
+// newAPIKeySecret returns the key handed out once, "pbk_<id>.<secret>", and the hash that is stored
+func newAPIKeySecret(id string) (string, string, error) {
+	secret, err := generateToken()
+	if err != nil {
+		return "", "", errors.New("Failed to generate api key")
+	}
+	return APIKeyPrefix + id + "." + secret, hashToken(secret), nil
+}
+
+// AuthenticateAPIKey looks up the key by its id and checks the secret, expired and revoked keys are invalid
+func AuthenticateAPIKey(apiKeyStore store.APIKeyStore, apiKey string) (types.APIKey, error) {
+	id, secret, ok := strings.Cut(strings.TrimPrefix(apiKey, APIKeyPrefix), ".")
+	if !ok || !strings.HasPrefix(apiKey, APIKeyPrefix) {
+		return types.APIKey{}, ErrInvalidAPIKey
+	}
+
+	key, err := apiKeyStore.FindAPIKey(id)
+	if err == store.ErrAPIKeyNotFound {
+		return types.APIKey{}, ErrInvalidAPIKey
+	}
+	if err != nil {
+		return types.APIKey{}, err
+	}
+	if !tokenMatches(secret, key.KeyHash) {
+		return types.APIKey{}, ErrInvalidAPIKey
+	}
+
+	if time.Since(key.LastUsedAt) > apiKeyTouchInterval {
+		apiKeyStore.TouchAPIKey(key.ID)
+	}
+	return key, nil
+}
+
+func ListAPIKeys(c *fiber.Ctx, apiKeyStore store.APIKeyStore) error {
+	keys, err := apiKeyStore.ListAPIKeys()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(types.ErrorResponse{Error: "Failed to list api keys: " + err.Error()})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(types.HTTPSuccessResponse{
+		Message: "API keys have been found successfully",
+		Data:    map[string]any{"apiKeys": keys},
+	})
+}
+
+// CreateAPIKey answers with the key only this one time, scopes are permissions like "files:upload" or "admin"
+func CreateAPIKey(c *fiber.Ctx, apiKeyStore store.APIKeyStore, validator validator.Validate) error {
+	var body struct {
+		Name          string   `json:"name" validate:"required,max=255"`
+		Scopes        []string `json:"scopes" validate:"required,min=1,dive,required"`
+		ExpiresInDays int      `json:"expiresInDays" validate:"min=0"`
+	}
+
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(types.ErrorResponse{Error: "Invalid request body: " + err.Error()})
+	}
+
+	if err := validator.Struct(&body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(types.ErrorResponse{Error: "Invalid request body: " + err.Error()})
+	}
+
+	userId, _ := c.Locals("userId").(string)
+	key := types.APIKey{
+		ID:        uuid.New().String(),
+		Name:      body.Name,
+		Scopes:    body.Scopes,
+		CreatedBy: userId,
+		CreatedAt: time.Now(),
+	}
+	if body.ExpiresInDays > 0 {
+		key.ExpiresAt = key.CreatedAt.Add(time.Hour * 24 * time.Duration(body.ExpiresInDays))
+	}
+
+	apiKey, keyHash, err := newAPIKeySecret(key.ID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(types.ErrorResponse{Error: err.Error()})
+	}
+	key.KeyHash = keyHash
+
+	if err := apiKeyStore.CreateAPIKey(key); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(types.ErrorResponse{Error: "Failed to create api key: " + err.Error()})
+	}
+	auditRequest(c, AuditAPIKeyCreated, types.UserRecord{}, true, map[string]string{"keyId": key.ID, "name": key.Name, "scopes": strings.Join(key.Scopes, ",")})
+
+	return c.Status(fiber.StatusCreated).JSON(types.HTTPSuccessResponse{
+		Message: "API key has been created, store it now it won't be shown again",
+		Data:    map[string]any{"apiKey": apiKey, "key": key},
+	})
+}
+
+// RotateAPIKey hands out a new secret for the key, the old one stops working right away
+func RotateAPIKey(c *fiber.Ctx, apiKeyStore store.APIKeyStore) error {
+	id := c.Params("id")
+	apiKey, keyHash, err := newAPIKeySecret(id)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(types.ErrorResponse{Error: err.Error()})
+	}
+
+	err = apiKeyStore.RotateAPIKey(id, keyHash)
+	if err == store.ErrAPIKeyNotFound {
+		return c.Status(fiber.StatusNotFound).JSON(types.ErrorResponse{Error: "API key not found"})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(types.ErrorResponse{Error: "Failed to rotate api key: " + err.Error()})
+	}
+	auditRequest(c, AuditAPIKeyRotated, types.UserRecord{}, true, map[string]string{"keyId": id})
+
+	return c.Status(fiber.StatusOK).JSON(types.HTTPSuccessResponse{
+		Message: "API key has been rotated, store it now it won't be shown again",
+		Data:    map[string]any{"apiKey": apiKey},
+	})
+}
+
+func RevokeAPIKey(c *fiber.Ctx, apiKeyStore store.APIKeyStore) error {
+	id := c.Params("id")
+	err := apiKeyStore.DeleteAPIKey(id)
+	if err == store.ErrAPIKeyNotFound {
+		return c.Status(fiber.StatusNotFound).JSON(types.ErrorResponse{Error: "API key not found"})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(types.ErrorResponse{Error: "Failed to revoke api key: " + err.Error()})
+	}
+	auditRequest(c, AuditAPIKeyRevoked, types.UserRecord{}, true, map[string]string{"keyId": id})
+
+	return c.Status(fiber.StatusOK).JSON(types.HTTPSuccessResponse{Message: "API key has been revoked"})
+}
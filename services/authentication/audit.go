@@ -0,0 +1,206 @@
+package authentication
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/froggy-12/purpurbase/store"
+	"github.com/froggy-12/purpurbase/types"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+// AuditLog is set on start up to the audit store of the primary database, nil only writes the server log
+var AuditLog store.AuditStore
+
+const (
+	AuditSignedUp             = "user.signed_up"
+	AuditUpgraded             = "user.upgraded" // a guest became a full account
+	AuditVerified             = "user.verified"
+	AuditDeleted              = "user.deleted"
+	AuditLogInSucceeded       = "login.succeeded"
+	AuditLogInFailed          = "login.failed"
+	AuditPasswordChanged      = "password.changed"
+	AuditEmailChangeRequested = "email.change_requested"
+	AuditEmailChanged         = "email.changed"
+	AuditEmailReverted        = "email.reverted"
+	AuditUsernameChanged      = "username.changed"
+	AuditIdentityLinked       = "identity.linked"
+	AuditIdentityUnlinked     = "identity.unlinked"
+	AuditRolesChanged         = "user.roles_changed"
+	AuditDisabled             = "user.disabled"
+	AuditEnabled              = "user.enabled"
+	AuditRawDataChanged       = "user.raw_data_changed" // only changes made by admins, users change their own data all the time
+	AuditTOTPEnabled          = "totp.enabled"
+	AuditTOTPDisabled         = "totp.disabled"
+	AuditRecoveryCodesRenewed = "totp.recovery_codes_renewed"
+	AuditAPIKeyCreated        = "apikey.created"
+	AuditAPIKeyRotated        = "apikey.rotated"
+	AuditAPIKeyRevoked        = "apikey.revoked"
+)
+
+// the export reads the store in pages of this size
+const auditExportPageSize = 500
+
+// auditLogger writes every event as one json line on stdout so log collectors can pick them up
+var auditLogger = slog.New(slog.NewJSONHandler(os.Stdout, nil))
+
+// audit appends the event to the audit log and writes it on the server log. A failed write is logged but never
+// fails the request, the change it records has already happened
+func audit(event types.AuditEvent) {
+	event.ID = uuid.New().String()
+	event.CreatedAt = time.Now()
+
+	level := slog.LevelInfo
+	if !event.Success {
+		level = slog.LevelWarn
+	}
+	auditLogger.Log(context.Background(), level, "audit",
+		slog.String("id", event.ID),
+		slog.String("type", event.Type),
+		slog.String("userId", event.UserID),
+		slog.String("actorId", event.ActorID),
+		slog.String("email", event.Email),
+		slog.Bool("success", event.Success),
+		slog.String("ip", event.IP),
+		slog.String("userAgent", event.UserAgent),
+		slog.Any("details", event.Details),
+	)
+
+	if AuditLog == nil {
+		return
+	}
+	if err := AuditLog.AppendAuditEvent(event); err != nil {
+		auditLogger.Error("failed to append audit event", slog.String("id", event.ID), slog.String("error", err.Error()))
+	}
+}
+
+// auditRequest records an event of this request, the actor is the logged in user or "apikey:<id>" for api keys
+func auditRequest(c *fiber.Ctx, eventType string, user types.UserRecord, success bool, details map[string]string) {
+	event := types.AuditEvent{
+		Type:      eventType,
+		UserID:    user.ID,
+		Email:     user.Email,
+		Success:   success,
+		IP:        c.IP(),
+		UserAgent: c.Get(fiber.HeaderUserAgent),
+		Details:   details,
+	}
+	if principal, ok := CurrentPrincipal(c); ok {
+		event.ActorID = principal.UserID
+		if principal.IsService() {
+			event.ActorID = "apikey:" + principal.APIKeyID
+		}
+	}
+	audit(event)
+}
+
+// auditLogInFailed records a failed log in, the user is empty when no account has the email
+func auditLogInFailed(c *fiber.Ctx, user types.UserRecord, email, method, reason string) {
+	user.Email = email
+	auditRequest(c, AuditLogInFailed, user, false, map[string]string{"method": method, "reason": reason})
+}
+
+// auditQuery reads ?type=a,b&userId=&actorId=&email=&ip=&success=&since=&until=, without it the error response has
+// already been sent
+func auditQuery(c *fiber.Ctx) (store.AuditQuery, bool, error) {
+	query := store.AuditQuery{
+		UserID:  c.Query("userId"),
+		ActorID: c.Query("actorId"),
+		Email:   c.Query("email"),
+		IP:      c.Query("ip"),
+	}
+	if eventTypes := c.Query("type"); eventTypes != "" {
+		query.Types = strings.Split(eventTypes, ",")
+	}
+
+	var err error
+	if query.Success, err = boolQuery(c, "success"); err != nil {
+		return query, false, c.Status(fiber.StatusBadRequest).JSON(types.ErrorResponse{Error: "success should be true or false"})
+	}
+	for key, target := range map[string]*time.Time{"since": &query.Since, "until": &query.Until} {
+		if value := c.Query(key); value != "" {
+			if *target, err = time.Parse(time.RFC3339, value); err != nil {
+				return query, false, c.Status(fiber.StatusBadRequest).JSON(types.ErrorResponse{Error: key + " should be an RFC 3339 time like 2024-01-02T15:04:05Z"})
+			}
+		}
+	}
+
+	return query, true, nil
+}
+
+// AdminListAuditEvents supports ?limit=&offset= and the filters of auditQuery, newest events first
+func AdminListAuditEvents(c *fiber.Ctx, auditStore store.AuditStore) error {
+	query, ok, err := auditQuery(c)
+	if !ok {
+		return err
+	}
+	query.Limit = c.QueryInt("limit", adminDefaultPageSize)
+	query.Offset = c.QueryInt("offset", 0)
+
+	if query.Limit <= 0 || query.Limit > adminMaxPageSize {
+		return c.Status(fiber.StatusBadRequest).JSON(types.ErrorResponse{Error: "limit should be between 1 and " + strconv.Itoa(adminMaxPageSize)})
+	}
+	if query.Offset < 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(types.ErrorResponse{Error: "offset can not be negative"})
+	}
+
+	events, total, err := auditStore.ListAuditEvents(query)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(types.ErrorResponse{Error: "Failed to list audit events: " + err.Error()})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(types.HTTPSuccessResponse{
+		Message: "Audit events have been found successfully",
+		Data:    map[string]any{"events": events, "total": total, "limit": query.Limit, "offset": query.Offset},
+	})
+}
+
+// AdminExportAuditEvents streams every event matching the filters of auditQuery as newline delimited json,
+// newest first. Events appended while the export runs are left out so the pages don't shift
+func AdminExportAuditEvents(c *fiber.Ctx, auditStore store.AuditStore) error {
+	query, ok, err := auditQuery(c)
+	if !ok {
+		return err
+	}
+	if query.Until.IsZero() || query.Until.After(time.Now()) {
+		query.Until = time.Now()
+	}
+	query.Limit = auditExportPageSize
+
+	// the first page is read before answering so a broken store still gets a proper error response
+	events, _, err := auditStore.ListAuditEvents(query)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(types.ErrorResponse{Error: "Failed to export audit events: " + err.Error()})
+	}
+
+	c.Set(fiber.HeaderContentType, "application/x-ndjson")
+	c.Set(fiber.HeaderContentDisposition, `attachment; filename="audit-log-`+query.Until.UTC().Format("20060102T150405Z")+`.ndjson"`)
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		encoder := json.NewEncoder(w)
+		for len(events) > 0 {
+			for _, event := range events {
+				if err := encoder.Encode(event); err != nil {
+					return
+				}
+			}
+			if err := w.Flush(); err != nil || len(events) < query.Limit {
+				return
+			}
+
+			query.Offset += len(events)
+			if events, _, err = auditStore.ListAuditEvents(query); err != nil {
+				auditLogger.Error("failed to export audit events", slog.String("error", err.Error()))
+				return
+			}
+		}
+	})
+
+	return nil
+}
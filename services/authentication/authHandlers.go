@@ -0,0 +1,286 @@
+package authentication
+
+import (
+	"time"
+
+	"github.com/froggy-12/purpurbase/config"
+	"github.com/froggy-12/purpurbase/services/smtpconfigs"
+	"github.com/froggy-12/purpurbase/store"
+	"github.com/froggy-12/purpurbase/types"
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+func CreateUserWithEmailAndPassword(c *fiber.Ctx, userStore store.UserStore, sessionStore store.SessionStore, validator validator.Validate) error {
+	if principal, err := ReadPrincipal(c, sessionStore, nil); err == nil && principal.UserID != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(types.ErrorResponse{Error: "Valid Token Found Please lot out first then try again"})
+	}
+
+	var user types.User
+
+	if err := c.BodyParser(&user); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(types.ErrorResponse{Error: "Invalid Request body"})
+	}
+
+	if err := validator.Struct(&user); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(types.ErrorResponse{Error: err.Error()})
+	}
+
+	newUser := types.UserRecord{
+		ID:                uuid.New().String(),
+		FirstName:         user.FirstName,
+		LastName:          user.LastName,
+		UserName:          user.UserName,
+		Email:             user.Email,
+		ProfilePicture:    user.ProfilePicture,
+		CreatedAt:         time.Now(),
+		UpdatedAt:         time.Now(),
+		BirthDay:          user.BirthDay,
+		Verified:          false,
+		VerificationToken: uuid.New().String(),
+		RawData:           map[string]any{},
+		Roles:             defaultRoles(),
+	}
+
+	if err := setPassword(&newUser, user.Password); err != nil {
+		return passwordRejected(c, err)
+	}
+
+	if config.Configs.AuthenticationConfigurations.SetJWTAfterSignUp {
+		newUser.LastLoggedIn = time.Now()
+	}
+
+	err := userStore.CreateUser(newUser)
+
+	if err == store.ErrUserAlreadyExists {
+		return c.Status(fiber.StatusBadRequest).JSON(types.ErrorResponse{Error: "User already exist"})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusBadGateway).JSON(types.ErrorResponse{Error: "failed to create new user into the database: " + err.Error()})
+	}
+	auditRequest(c, AuditSignedUp, newUser, true, map[string]string{"method": "password"})
+
+	var tokens sessionTokens
+	if config.Configs.AuthenticationConfigurations.SetJWTAfterSignUp {
+		if tokens, err = startSession(c, userStore, sessionStore, newUser); err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(types.ErrorResponse{Error: "Failed to start session for user: " + newUser.ID + " " + err.Error()})
+		}
+	}
+
+	if config.Configs.AuthenticationConfigurations.SendEmailAfterSignUpWithToken {
+		if !config.Configs.AuthenticationConfigurations.EmailVerification {
+			return c.Status(fiber.StatusBadRequest).JSON(types.ErrorResponse{Error: "Email Verification is not configured or turned off please check again and restart the app"})
+		}
+
+		if !config.Configs.SMTPConfigurations.SMTPEnabled {
+			return c.Status(fiber.StatusBadRequest).JSON(types.ErrorResponse{Error: "SMTP is not configured or turned off please check again and restart the app"})
+		}
+
+		err = smtpconfigs.SendVerificationEmail(newUser.Email, newUser.VerificationToken)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(types.ErrorResponse{Error: "failed to send email to this user: " + user.Email})
+		}
+
+		return c.Status(fiber.StatusAccepted).JSON(types.HTTPSuccessResponse{
+			Message: "User has been created successfully and sent verification email",
+			Data:    withTokens(c, tokens, nil),
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(types.HTTPSuccessResponse{
+		Message: "User Has been created to the database hope you will verify the email first then everything",
+		Data:    withTokens(c, tokens, map[string]any{"userID": newUser.ID}),
+	})
+}
+
+func LogInWithEmailAndPassword(c *fiber.Ctx, userStore store.UserStore, sessionStore store.SessionStore, attempts store.LoginAttemptStore, validator validator.Validate) error {
+	if principal, err := ReadPrincipal(c, sessionStore, nil); err == nil && principal.UserID != "" {
+		return c.Status(fiber.StatusAlreadyReported).JSON(types.HTTPSuccessResponse{Message: "You are already logged in"})
+	}
+
+	return logIn(c, userStore, sessionStore, attempts, validator)
+}
+
+func logIn(c *fiber.Ctx, userStore store.UserStore, sessionStore store.SessionStore, attempts store.LoginAttemptStore, validator validator.Validate) error {
+	var details types.LogInDetails
+	if err := c.BodyParser(&details); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(types.ErrorResponse{Error: "Invalid Request body"})
+	}
+
+	if err := validator.Struct(&details); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(types.ErrorResponse{Error: err.Error()})
+	}
+
+	lockedFor, err := loginLockedFor(attempts, details.Email, c.IP())
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(types.ErrorResponse{Error: "Something went wrong: " + err.Error()})
+	}
+	if lockedFor > 0 {
+		auditLogInFailed(c, types.UserRecord{}, details.Email, "password", "locked")
+		return tooManyAttempts(c, lockedFor)
+	}
+
+	user, err := userStore.FindUserByEmail(details.Email)
+
+	if err == store.ErrUserNotFound {
+		compareDummyPassword(details.Password)
+		auditLogInFailed(c, types.UserRecord{}, details.Email, "password", "unknown_email")
+		return loginFailed(c, attempts, userStore, details.Email)
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(types.ErrorResponse{Error: "Something went wrong: " + err.Error()})
+	}
+
+	if !checkPasswordAndUpgrade(userStore, &user, details.Password) {
+		auditLogInFailed(c, user, details.Email, "password", "wrong_password")
+		return loginFailed(c, attempts, userStore, details.Email)
+	}
+
+	if user.Disabled {
+		auditLogInFailed(c, user, user.Email, "password", "disabled")
+		return sessionFailed(c, errUserDisabled)
+	}
+
+	// the failed attempts are only reset once the user is fully authenticated, VerifyMFA resets them after the
+	// second factor. Resetting them here would let the password alone clear the failures of guessed totp codes
+	if user.TOTPEnabled {
+		return sendMFAChallenge(c, user)
+	}
+	loginSucceeded(attempts, details.Email)
+
+	tokens, err := logInSession(c, userStore, sessionStore, user, "password")
+	if err != nil {
+		return sessionFailed(c, err)
+	}
+
+	return c.Status(fiber.StatusAccepted).JSON(types.HTTPSuccessResponse{
+		Message: "User has been logged in successfully",
+		Data:    withTokens(c, tokens, map[string]any{"userID": user.ID}),
+	})
+}
+
+func SendVerificationEmail(c *fiber.Ctx, userStore store.UserStore) error {
+	if !config.Configs.AuthenticationConfigurations.EmailVerification {
+		return c.Status(fiber.StatusBadRequest).JSON(types.ErrorResponse{Error: "Email Verification is not configured or turned off please check again and restart the app"})
+	}
+
+	if !config.Configs.SMTPConfigurations.SMTPEnabled {
+		return c.Status(fiber.StatusBadRequest).JSON(types.ErrorResponse{Error: "SMTP is not configured or turned off please check again and restart the app"})
+	}
+
+	var body struct {
+		ID string `json:"id"`
+	}
+
+	tokenSet := c.Query("tokenSet", "false")
+
+	if tokenSet == "true" {
+		if principal, err := ReadPrincipal(c, nil, nil); err == nil {
+			body.ID = principal.UserID
+		}
+	}
+
+	if body.ID == "" && tokenSet == "false" {
+		if err := c.BodyParser(&body); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(types.ErrorResponse{Error: "Invalid Request body"})
+		}
+	}
+
+	if body.ID == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(types.ErrorResponse{Error: "No ID Has been found"})
+	}
+
+	user, err := userStore.FindUserByID(body.ID)
+	if err == store.ErrUserNotFound {
+		return c.Status(fiber.StatusBadRequest).JSON(types.ErrorResponse{Error: "User not found"})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(types.ErrorResponse{Error: "Something went wrong: " + err.Error()})
+	}
+
+	if user.Verified {
+		return c.Status(fiber.StatusBadRequest).JSON(types.ErrorResponse{Error: "User is already verified"})
+	}
+
+	user.VerificationToken = uuid.New().String()
+	err = userStore.UpdateUser(user)
+
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(types.ErrorResponse{Error: "failed to update new token: " + err.Error()})
+	}
+
+	err = smtpconfigs.SendVerificationEmail(user.Email, user.VerificationToken)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(types.ErrorResponse{Error: "failed to send email to this user: " + user.Email + " " + err.Error()})
+	}
+
+	return c.Status(fiber.StatusAccepted).JSON(types.HTTPSuccessResponse{Message: "Email sent successfully"})
+}
+
+func VerifyEmail(c *fiber.Ctx, userStore store.UserStore, validator validator.Validate) error {
+	email := c.Query("email")
+	verificationTokenString := c.Query("token")
+
+	if email == "" || verificationTokenString == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(types.ErrorResponse{Error: "Email and token are required"})
+	}
+
+	if err := validator.Var(email, "required,email"); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(types.ErrorResponse{Error: "Invalid email: " + err.Error()})
+	}
+
+	user, err := userStore.FindUserByEmail(email)
+	if err == store.ErrUserNotFound {
+		return c.Status(fiber.StatusBadGateway).JSON(types.ErrorResponse{Error: "User not Found"})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusBadGateway).JSON(types.ErrorResponse{Error: "Something went wrong: " + err.Error()})
+	}
+
+	if user.Verified {
+		return c.Status(fiber.StatusBadRequest).JSON(types.ErrorResponse{Error: "User is already verified"})
+	}
+
+	if user.VerificationToken != verificationTokenString {
+		return c.Status(fiber.StatusBadRequest).JSON(types.ErrorResponse{Error: "Wrong token Provided"})
+	}
+
+	if err := userStore.SetVerified(user.ID, true); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(types.ErrorResponse{Error: "Failed to update user verification status"})
+	}
+	auditRequest(c, AuditVerified, user, true, map[string]string{"via": "email"})
+
+	return c.Status(fiber.StatusOK).JSON(types.HTTPSuccessResponse{Message: "Email verified successfully"})
+}
+
+func CheckIsEmailAvailable(c *fiber.Ctx, userStore store.UserStore, validator validator.Validate) error {
+	email := c.Query("email")
+
+	if email == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(types.ErrorResponse{Error: "invalid query"})
+	}
+	if err := validator.Var(email, "required,email"); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(types.ErrorResponse{Error: "Invalid Email: " + err.Error()})
+	}
+	_, err := userStore.FindUserByEmail(email)
+	if err == nil {
+		return c.Status(fiber.StatusBadRequest).JSON(types.ErrorResponse{Error: "User already exist"})
+	}
+	return c.Status(fiber.StatusOK).JSON(types.HTTPSuccessResponse{Message: "The Email is good to go"})
+}
+
+func CheckIsUsernameAvailable(c *fiber.Ctx, userStore store.UserStore, validator validator.Validate) error {
+	username := c.Query("username")
+	if username == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(types.ErrorResponse{Error: "invalid query"})
+	}
+	if err := validator.Var(username, "required"); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(types.ErrorResponse{Error: err.Error()})
+	}
+	_, err := userStore.FindUserByUsername(username)
+	if err == nil {
+		return c.Status(fiber.StatusBadRequest).JSON(types.ErrorResponse{Error: "User already exist"})
+	}
+	return c.Status(fiber.StatusOK).JSON(types.HTTPSuccessResponse{Message: "The username is good to go"})
+}
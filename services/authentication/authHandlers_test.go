@@ -0,0 +1,95 @@
+package authentication
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/froggy-12/purpurbase/config"
+	"github.com/froggy-12/purpurbase/store"
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestSignUpLogInAndVerify(t *testing.T) {
+	useTestSecret(t)
+	previousAge := config.Configs.PurpurbaseConfigurations.PurpurbaseCookieAndCoresAge
+	config.Configs.PurpurbaseConfigurations.PurpurbaseCookieAndCoresAge = 1
+	t.Cleanup(func() { config.Configs.PurpurbaseConfigurations.PurpurbaseCookieAndCoresAge = previousAge })
+	userStore := store.NewMemoryUserStore()
+	sessionStore := store.NewMemorySessionStore()
+	attempts := store.NewMemoryLoginAttemptStore()
+
+	app := fiber.New()
+	app.Post("/create-user", func(c *fiber.Ctx) error {
+		return CreateUserWithEmailAndPassword(c, userStore, sessionStore, *validator.New())
+	})
+	app.Post("/log-in", func(c *fiber.Ctx) error {
+		return LogInWithEmailAndPassword(c, userStore, sessionStore, attempts, *validator.New())
+	})
+	app.Get("/verified", func(c *fiber.Ctx) error { return VerifyEmail(c, userStore, *validator.New()) })
+
+	signUp := map[string]string{"username": "user", "firstName": "A", "lastName": "User", "email": "user@example.com", "password": "the password 1"}
+	if code := send(t, app, "POST", "/create-user", map[string]string{"email": "user@example.com"}); code != fiber.StatusBadRequest {
+		t.Errorf("a sign up without a username answered %d, want 400", code)
+	}
+	if code := send(t, app, "POST", "/create-user", signUp); code != fiber.StatusOK {
+		t.Fatalf("the sign up answered %d, want 200", code)
+	}
+	for _, duplicate := range []map[string]string{
+		{"username": "other", "firstName": "A", "lastName": "User", "email": "user@example.com", "password": "the password 1"},
+		{"username": "user", "firstName": "A", "lastName": "User", "email": "other@example.com", "password": "the password 1"},
+	} {
+		if code := send(t, app, "POST", "/create-user", duplicate); code != fiber.StatusBadRequest {
+			t.Errorf("signing up as %s %s again answered %d, want 400", duplicate["username"], duplicate["email"], code)
+		}
+	}
+
+	user, err := userStore.FindUserByEmail("user@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.Password == "" || user.Password == "the password 1" || user.Verified {
+		t.Fatalf("stored the password %q and verified %v", user.Password, user.Verified)
+	}
+
+	if code := send(t, app, "POST", "/log-in", map[string]string{"email": "user@example.com", "password": "guessed"}); code != fiber.StatusBadRequest {
+		t.Errorf("a wrong password answered %d, want 400", code)
+	}
+	if code := send(t, app, "POST", "/log-in", map[string]string{"email": "nobody@example.com", "password": "the password 1"}); code != fiber.StatusBadRequest {
+		t.Errorf("an unknown email answered %d, want 400", code)
+	}
+	if code := send(t, app, "POST", "/log-in", map[string]string{"email": "user@example.com", "password": "the password 1"}); code != fiber.StatusAccepted {
+		t.Errorf("the right password answered %d, want 202", code)
+	}
+	if sessions, err := sessionStore.ListUserSessions(user.ID); err != nil || len(sessions) != 1 {
+		t.Errorf("the log in left %d sessions, %v", len(sessions), err)
+	}
+
+	if code := send(t, app, "GET", "/verified?email=user@example.com&token=guessed", nil); code != fiber.StatusBadRequest {
+		t.Errorf("a wrong verification token answered %d, want 400", code)
+	}
+	if code := send(t, app, "GET", "/verified?email=user@example.com&token="+user.VerificationToken, nil); code != fiber.StatusOK {
+		t.Errorf("the verification token answered %d, want 200", code)
+	}
+	if user, err = userStore.FindUserByID(user.ID); err != nil || !user.Verified {
+		t.Errorf("the user is not verified: %v", err)
+	}
+
+	// the hash and the verification token never leave the server
+	getUser := fiber.New()
+	getUser.Get("/get-user", loggedInAs(user.ID, ""), func(c *fiber.Ctx) error { return GetUser(c, userStore) })
+	response, err := getUser.Test(httptest.NewRequest("GET", "/get-user", nil), -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var body map[string]any
+	if err := json.NewDecoder(response.Body).Decode(&body); err != nil {
+		t.Fatal(err)
+	}
+	encoded, _ := json.Marshal(body)
+	if strings.Contains(string(encoded), user.Password) || strings.Contains(string(encoded), user.VerificationToken) || !strings.Contains(string(encoded), user.Email) {
+		t.Errorf("get-user answered %s", encoded)
+	}
+}
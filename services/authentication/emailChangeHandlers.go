@@ -0,0 +1,231 @@
+package authentication
+
+import (
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/froggy-12/purpurbase/config"
+	"github.com/froggy-12/purpurbase/services/smtpconfigs"
+	"github.com/froggy-12/purpurbase/store"
+	"github.com/froggy-12/purpurbase/types"
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+)
+
+func emailChangeTokenAge() time.Duration {
+	minutes := config.Configs.AuthenticationConfigurations.EmailChange.TokenAge
+	if minutes <= 0 {
+		minutes = 60
+	}
+	return time.Duration(minutes) * time.Minute
+}
+
+func emailRevertAge() time.Duration {
+	hours := config.Configs.AuthenticationConfigurations.EmailChange.RevertAge
+	if hours <= 0 {
+		hours = 168
+	}
+	return time.Duration(hours) * time.Hour
+}
+
+func emailChangeURL(base, path, userID, token string) string {
+	if base == "" {
+		base = config.Configs.PurpurbaseConfigurations.PurpurbaseBaseURL + ":" + config.Configs.PurpurbaseConfigurations.PurpurbasePort + path
+	}
+
+	query := url.Values{}
+	query.Set("id", userID)
+	query.Set("token", token)
+
+	if strings.Contains(base, "?") {
+		return base + "&" + query.Encode()
+	}
+	return base + "?" + query.Encode()
+}
+
+// ChangeEmail stages the new email, it only replaces the current one once the link sent to it is opened.
+// The current email is told about the change and gets a link to revert it. Accounts without a password need a fresh log in
+func ChangeEmail(c *fiber.Ctx, userStore store.UserStore, sessionStore store.SessionStore, validator validator.Validate) error {
+	if !config.Configs.SMTPConfigurations.SMTPEnabled {
+		return c.Status(fiber.StatusBadRequest).JSON(types.ErrorResponse{Error: "SMTP is not configured or turned off please check again and restart the app"})
+	}
+
+	var body struct {
+		Email    string `json:"email" validate:"required,email"`
+		NewEmail string `json:"newEmail" validate:"required,email"`
+		Password string `json:"password"`
+	}
+
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(types.ErrorResponse{Error: "Invalid request body: " + err.Error()})
+	}
+
+	if err := validator.Struct(&body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(types.ErrorResponse{Error: "Invalid request body: " + err.Error()})
+	}
+
+	user, err := userStore.FindUserByEmail(body.Email)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(types.ErrorResponse{Error: "User Not Found: " + err.Error()})
+	}
+	if user.ID != c.Locals("userId") {
+		return c.Status(fiber.StatusBadRequest).JSON(types.ErrorResponse{Error: "Wrong Password or email"})
+	}
+	confirmed, err := confirmIdentity(c, sessionStore, user, body.Password)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(types.ErrorResponse{Error: "Something went wrong: " + err.Error()})
+	}
+	if !confirmed {
+		return identityNotConfirmed(c, user, "Wrong Password or email")
+	}
+
+	if strings.EqualFold(body.NewEmail, user.Email) {
+		return c.Status(fiber.StatusBadRequest).JSON(types.ErrorResponse{Error: "The new email is the current email"})
+	}
+	if _, err := userStore.FindUserByEmail(body.NewEmail); err == nil {
+		return c.Status(fiber.StatusBadRequest).JSON(types.ErrorResponse{Error: "User already exist"})
+	} else if err != store.ErrUserNotFound {
+		return c.Status(fiber.StatusInternalServerError).JSON(types.ErrorResponse{Error: "Something went wrong: " + err.Error()})
+	}
+
+	confirmToken, err := generateToken()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(types.ErrorResponse{Error: "Failed to generate token: " + err.Error()})
+	}
+	revertToken, err := generateToken()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(types.ErrorResponse{Error: "Failed to generate token: " + err.Error()})
+	}
+
+	// while an earlier change can still be reverted the address from before it keeps the revert link,
+	// so whoever changed the email can't change it again to take the revert link away
+	revertAddress := user.Email
+	if user.EmailRevertAddress != "" && time.Now().Before(user.EmailRevertExpiresAt) {
+		revertAddress = user.EmailRevertAddress
+	}
+
+	user.PendingEmail = body.NewEmail
+	user.EmailChangeToken = hashToken(confirmToken)
+	user.EmailChangeExpiresAt = time.Now().Add(emailChangeTokenAge())
+	user.EmailRevertToken = hashToken(revertToken)
+	user.EmailRevertAddress = revertAddress
+	user.EmailRevertExpiresAt = time.Now().Add(emailRevertAge())
+
+	if err := userStore.UpdateUser(user); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(types.ErrorResponse{Error: "Something went wrong failed to update email: " + err.Error()})
+	}
+
+	emailChange := config.Configs.AuthenticationConfigurations.EmailChange
+	confirmLink := emailChangeURL(emailChange.ConfirmURL, "/api/auth/email-change/confirm", user.ID, confirmToken)
+	if err := smtpconfigs.SendEmailChangeConfirmationEmail(body.NewEmail, confirmLink, emailChangeTokenAge()); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(types.ErrorResponse{Error: "failed to send email to this user: " + body.NewEmail + " " + err.Error()})
+	}
+	revertLink := emailChangeURL(emailChange.RevertURL, "/api/auth/email-change/revert", user.ID, revertToken)
+	if err := smtpconfigs.SendEmailChangeNoticeEmail(revertAddress, body.NewEmail, revertLink, emailRevertAge()); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(types.ErrorResponse{Error: "failed to send email to this user: " + revertAddress + " " + err.Error()})
+	}
+	auditRequest(c, AuditEmailChangeRequested, user, true, map[string]string{"newEmail": body.NewEmail})
+
+	return c.Status(fiber.StatusAccepted).JSON(types.HTTPSuccessResponse{Message: "A confirmation link has been sent to the new email, the email changes once it is opened"})
+}
+
+// emailChangeBody reads the user id and token of an emailed link, from ?id=&token= or {"id": ..., "token": ...},
+// without them the error response has already been sent
+func emailChangeBody(c *fiber.Ctx, validator validator.Validate) (id, token string, ok bool, err error) {
+	var body struct {
+		ID    string `json:"id" validate:"required"`
+		Token string `json:"token" validate:"required"`
+	}
+
+	body.ID, body.Token = c.Query("id"), c.Query("token")
+	if body.Token == "" && c.Method() == fiber.MethodPost {
+		if err := c.BodyParser(&body); err != nil {
+			return "", "", false, c.Status(fiber.StatusBadRequest).JSON(types.ErrorResponse{Error: "Invalid Request body"})
+		}
+	}
+
+	if err := validator.Struct(&body); err != nil {
+		return "", "", false, c.Status(fiber.StatusBadRequest).JSON(types.ErrorResponse{Error: err.Error()})
+	}
+
+	return body.ID, body.Token, true, nil
+}
+
+// ConfirmEmailChange redeems the link sent to the new email and makes it the email of the user, the link
+// proves the user owns the address so the user is verified again
+func ConfirmEmailChange(c *fiber.Ctx, userStore store.UserStore, validator validator.Validate) error {
+	id, token, ok, err := emailChangeBody(c, validator)
+	if !ok {
+		return err
+	}
+
+	user, err := userStore.FindUserByID(id)
+	if err != nil && err != store.ErrUserNotFound {
+		return c.Status(fiber.StatusInternalServerError).JSON(types.ErrorResponse{Error: "Something went wrong: " + err.Error()})
+	}
+	if err == store.ErrUserNotFound || user.PendingEmail == "" || !tokenMatches(token, user.EmailChangeToken) || time.Now().After(user.EmailChangeExpiresAt) {
+		return c.Status(fiber.StatusBadRequest).JSON(types.ErrorResponse{Error: "Invalid or expired email change token"})
+	}
+
+	previousEmail := user.Email
+	user.Email = user.PendingEmail
+	user.Verified = true
+	user.VerificationToken = ""
+	user.PendingEmail = ""
+	user.EmailChangeToken = ""
+	user.EmailChangeExpiresAt = time.Time{}
+
+	err = userStore.UpdateUser(user)
+	if err == store.ErrUserAlreadyExists {
+		return c.Status(fiber.StatusBadRequest).JSON(types.ErrorResponse{Error: "User already exist"})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(types.ErrorResponse{Error: "Something went wrong failed to update email: " + err.Error()})
+	}
+	auditRequest(c, AuditEmailChanged, user, true, map[string]string{"previousEmail": previousEmail})
+
+	return c.Status(fiber.StatusOK).JSON(types.HTTPSuccessResponse{Message: "Email Has been Updated"})
+}
+
+// RevertEmailChange redeems the link sent to the previous email. It drops a pending change or restores the
+// previous email after a confirmed one and logs out every device, someone else may have used the account
+func RevertEmailChange(c *fiber.Ctx, userStore store.UserStore, sessionStore store.SessionStore, validator validator.Validate) error {
+	id, token, ok, err := emailChangeBody(c, validator)
+	if !ok {
+		return err
+	}
+
+	user, err := userStore.FindUserByID(id)
+	if err != nil && err != store.ErrUserNotFound {
+		return c.Status(fiber.StatusInternalServerError).JSON(types.ErrorResponse{Error: "Something went wrong: " + err.Error()})
+	}
+	if err == store.ErrUserNotFound || user.EmailRevertAddress == "" || !tokenMatches(token, user.EmailRevertToken) || time.Now().After(user.EmailRevertExpiresAt) {
+		return c.Status(fiber.StatusBadRequest).JSON(types.ErrorResponse{Error: "Invalid or expired email revert token"})
+	}
+
+	revertedEmail := user.Email
+	user.Email = user.EmailRevertAddress
+	user.Verified = true
+	user.PendingEmail = ""
+	user.EmailChangeToken = ""
+	user.EmailChangeExpiresAt = time.Time{}
+	user.EmailRevertToken = ""
+	user.EmailRevertAddress = ""
+	user.EmailRevertExpiresAt = time.Time{}
+
+	err = userStore.UpdateUser(user)
+	if err == store.ErrUserAlreadyExists {
+		return c.Status(fiber.StatusConflict).JSON(types.ErrorResponse{Error: "The previous email belongs to another account now"})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(types.ErrorResponse{Error: "Something went wrong failed to update email: " + err.Error()})
+	}
+	auditRequest(c, AuditEmailReverted, user, true, map[string]string{"revertedEmail": revertedEmail})
+
+	if err := sessionStore.DeleteUserSessions(user.ID); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(types.ErrorResponse{Error: "Email has been reverted but failed to revoke sessions: " + err.Error()})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(types.HTTPSuccessResponse{Message: "Email has been reverted and every device has been logged out, please reset your password if it wasn't you"})
+}
@@ -0,0 +1,49 @@
+package authentication
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/froggy-12/purpurbase/config"
+	"github.com/gofiber/fiber/v2"
+)
+
+// useTestSecret signs tokens with a throwaway jwt secret for the test
+func useTestSecret(t *testing.T) {
+	t.Helper()
+	previous := config.Configs.PurpurbaseConfigurations.PurpurbaseJWTTokenSecret
+	config.Configs.PurpurbaseConfigurations.PurpurbaseJWTTokenSecret = "test-secret"
+	t.Cleanup(func() { config.Configs.PurpurbaseConfigurations.PurpurbaseJWTTokenSecret = previous })
+}
+
+// loggedInAs stands in for the access token middleware
+func loggedInAs(userID, sessionID string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		c.Locals("userId", userID)
+		c.Locals("sessionId", sessionID)
+		return c.Next()
+	}
+}
+
+// send runs a json request through the app and returns the status code
+func send(t *testing.T, app *fiber.App, method, path string, body any) int {
+	t.Helper()
+	var reader io.Reader
+	if body != nil {
+		encoded, err := json.Marshal(body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		reader = bytes.NewReader(encoded)
+	}
+	req := httptest.NewRequest(method, path, reader)
+	req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+	response, err := app.Test(req, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return response.StatusCode
+}
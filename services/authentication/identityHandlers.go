@@ -0,0 +1,182 @@
+package authentication
+
+import (
+	"slices"
+
+	"github.com/froggy-12/purpurbase/services/authentication/oauth"
+	"github.com/froggy-12/purpurbase/store"
+	"github.com/froggy-12/purpurbase/types"
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+)
+
+// the email and password of a user is listed as an identity of this provider
+const passwordProvider = "password"
+
+func linkedProvider(user types.UserRecord, provider string) bool {
+	return slices.ContainsFunc(user.Identities, func(identity types.Identity) bool { return identity.Provider == provider })
+}
+
+// loginMethods counts the ways the user can log in, the last one can't be removed
+func loginMethods(user types.UserRecord) int {
+	methods := len(user.Identities)
+	if user.Password != "" {
+		methods++
+	}
+	return methods
+}
+
+// linkOAuthIdentity links the provider account to the logged in user, one account per provider
+func linkOAuthIdentity(c *fiber.Ctx, userStore store.UserStore, sessionStore store.SessionStore, identity types.Identity) error {
+	principal, err := ReadPrincipal(c, sessionStore, nil)
+	if err != nil || principal.UserID == "" {
+		return c.Status(fiber.StatusUnauthorized).JSON(types.ErrorResponse{Error: "Log in first to link a " + identity.Provider + " account"})
+	}
+
+	owner, err := userStore.FindUserByIdentity(identity.Provider, identity.Subject)
+	if err == nil {
+		if owner.ID == principal.UserID {
+			return c.Status(fiber.StatusBadRequest).JSON(types.ErrorResponse{Error: "This " + identity.Provider + " account is already linked"})
+		}
+		return c.Status(fiber.StatusConflict).JSON(types.ErrorResponse{Error: "This " + identity.Provider + " account is linked to another user"})
+	}
+	if err != store.ErrUserNotFound {
+		return c.Status(fiber.StatusInternalServerError).JSON(types.ErrorResponse{Error: "Something went wrong: " + err.Error()})
+	}
+
+	user, err := userStore.FindUserByID(principal.UserID)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(types.ErrorResponse{Error: "User Not Found: " + err.Error()})
+	}
+	if user.Anonymous {
+		return c.Status(fiber.StatusBadRequest).JSON(types.ErrorResponse{Error: "Guests link a " + identity.Provider + " account by logging in with it"})
+	}
+	if linkedProvider(user, identity.Provider) {
+		return c.Status(fiber.StatusBadRequest).JSON(types.ErrorResponse{Error: "A " + identity.Provider + " account is already linked, unlink it first"})
+	}
+
+	user.Identities = append(user.Identities, identity)
+	if err := userStore.UpdateUser(user); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(types.ErrorResponse{Error: "Failed to link " + identity.Provider + " account: " + err.Error()})
+	}
+	auditRequest(c, AuditIdentityLinked, user, true, map[string]string{"provider": identity.Provider})
+
+	return oauth.FinishLink(c, identity.Provider)
+}
+
+// ListIdentities lists the ways the user can log in, the password is listed with the "password" provider
+func ListIdentities(c *fiber.Ctx, userStore store.UserStore) error {
+	userId, _ := c.Locals("userId").(string)
+	user, err := userStore.FindUserByID(userId)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(types.ErrorResponse{Error: "User Not Found: " + err.Error()})
+	}
+
+	identities := []types.Identity{}
+	if user.Password != "" {
+		identities = append(identities, types.Identity{Provider: passwordProvider, Email: user.Email, LinkedAt: user.CreatedAt})
+	}
+	identities = append(identities, user.Identities...)
+
+	return c.Status(fiber.StatusOK).JSON(types.HTTPSuccessResponse{
+		Message: "Identities have been found",
+		Data:    map[string]any{"identities": identities},
+	})
+}
+
+// LinkPassword lets a user that only logs in with oauth or magic links add a password to the account email,
+// a password unlocks every change guarded by confirmIdentity so it needs a fresh log in too
+func LinkPassword(c *fiber.Ctx, userStore store.UserStore, sessionStore store.SessionStore, validator validator.Validate) error {
+	var body struct {
+		Password string `json:"password" validate:"required"`
+	}
+
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(types.ErrorResponse{Error: "Invalid request body: " + err.Error()})
+	}
+
+	if err := validator.Struct(&body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(types.ErrorResponse{Error: "Invalid request body: " + err.Error()})
+	}
+
+	userId, _ := c.Locals("userId").(string)
+	user, err := userStore.FindUserByID(userId)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(types.ErrorResponse{Error: "User Not Found: " + err.Error()})
+	}
+	if user.Anonymous {
+		return c.Status(fiber.StatusBadRequest).JSON(types.ErrorResponse{Error: "Guests add a password with /api/data/upgrade-account"})
+	}
+	if user.Password != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(types.ErrorResponse{Error: "The account already has a password, use change-password instead"})
+	}
+	confirmed, err := confirmIdentity(c, sessionStore, user, "")
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(types.ErrorResponse{Error: "Something went wrong: " + err.Error()})
+	}
+	if !confirmed {
+		return identityNotConfirmed(c, user, "")
+	}
+
+	if err := setPassword(&user, body.Password); err != nil {
+		return passwordRejected(c, err)
+	}
+
+	if err := userStore.UpdateUser(user); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(types.ErrorResponse{Error: "Something went wrong failed to update password: " + err.Error()})
+	}
+	auditRequest(c, AuditIdentityLinked, user, true, map[string]string{"provider": passwordProvider})
+
+	return c.Status(fiber.StatusOK).JSON(types.HTTPSuccessResponse{Message: "Password has been added"})
+}
+
+// UnlinkIdentity removes the identity of the :provider param, "password" removes the password.
+// The last way to log in can't be removed and the change is guarded by confirmIdentity, the body carries
+// the password of accounts that have one
+func UnlinkIdentity(c *fiber.Ctx, userStore store.UserStore, sessionStore store.SessionStore) error {
+	provider := c.Params("provider")
+
+	var body struct {
+		Password string `json:"password"`
+	}
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&body); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(types.ErrorResponse{Error: "Invalid request body: " + err.Error()})
+		}
+	}
+
+	userId, _ := c.Locals("userId").(string)
+	user, err := userStore.FindUserByID(userId)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(types.ErrorResponse{Error: "User Not Found: " + err.Error()})
+	}
+
+	if provider == passwordProvider && user.Password == "" || provider != passwordProvider && !linkedProvider(user, provider) {
+		return c.Status(fiber.StatusNotFound).JSON(types.ErrorResponse{Error: "No " + provider + " identity is linked"})
+	}
+	if loginMethods(user) <= 1 {
+		return c.Status(fiber.StatusBadRequest).JSON(types.ErrorResponse{Error: "The last way to log in can not be removed, link another one first"})
+	}
+	confirmed, err := confirmIdentity(c, sessionStore, user, body.Password)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(types.ErrorResponse{Error: "Something went wrong: " + err.Error()})
+	}
+	if !confirmed {
+		return identityNotConfirmed(c, user, "Wrong Password")
+	}
+
+	if provider == passwordProvider {
+		// the history belongs to the password, a password added later starts a new one
+		user.Password = ""
+		user.PasswordHistory = nil
+	} else {
+		user.Identities = slices.DeleteFunc(user.Identities, func(identity types.Identity) bool { return identity.Provider == provider })
+	}
+
+	if err := userStore.UpdateUser(user); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(types.ErrorResponse{Error: "Failed to unlink " + provider + ": " + err.Error()})
+	}
+	auditRequest(c, AuditIdentityUnlinked, user, true, map[string]string{"provider": provider})
+
+	return c.Status(fiber.StatusOK).JSON(types.HTTPSuccessResponse{Message: provider + " has been unlinked"})
+}
@@ -0,0 +1,74 @@
+package authentication
+
+import (
+	"testing"
+	"time"
+
+	"github.com/froggy-12/purpurbase/store"
+	"github.com/froggy-12/purpurbase/types"
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestUnlinkIdentityConfirmsIdentity(t *testing.T) {
+	userStore := store.NewMemoryUserStore()
+	sessionStore := store.NewMemorySessionStore()
+
+	hash, err := hashPassword("the password 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	user := types.UserRecord{
+		ID: "user", Email: "user@example.com", UserName: "user", Password: hash, PasswordHistory: []string{"an older hash"},
+		Identities: []types.Identity{{Provider: "github", Subject: "1"}, {Provider: "google", Subject: "2"}},
+	}
+	if err := userStore.CreateUser(user); err != nil {
+		t.Fatal(err)
+	}
+	if err := sessionStore.CreateSession(types.Session{ID: "session", UserID: "user", CreatedAt: time.Now(), ExpiresAt: time.Now().Add(time.Hour)}); err != nil {
+		t.Fatal(err)
+	}
+
+	app := fiber.New()
+	app.Use(loggedInAs("user", "session"))
+	app.Delete("/identities/:provider", func(c *fiber.Ctx) error { return UnlinkIdentity(c, userStore, sessionStore) })
+
+	// a fresh session is not enough while the account has a password
+	if code := send(t, app, "DELETE", "/identities/github", nil); code != fiber.StatusBadRequest {
+		t.Errorf("unlinking without the password answered %d, want 400", code)
+	}
+	if code := send(t, app, "DELETE", "/identities/github", map[string]string{"password": "guessed"}); code != fiber.StatusBadRequest {
+		t.Errorf("unlinking with a wrong password answered %d, want 400", code)
+	}
+	if code := send(t, app, "DELETE", "/identities/github", map[string]string{"password": "the password 1"}); code != fiber.StatusOK {
+		t.Errorf("unlinking with the password answered %d, want 200", code)
+	}
+
+	if code := send(t, app, "DELETE", "/identities/password", map[string]string{"password": "the password 1"}); code != fiber.StatusOK {
+		t.Fatalf("removing the password answered %d, want 200", code)
+	}
+	user, err = userStore.FindUserByID("user")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.Password != "" || len(user.PasswordHistory) != 0 || linkedProvider(user, "github") {
+		t.Errorf("left password %q, history %v and identities %v", user.Password, user.PasswordHistory, user.Identities)
+	}
+
+	// without a password the session has to be fresh
+	stale := types.Session{ID: "stale", UserID: "user", CreatedAt: time.Now().Add(-time.Hour), ExpiresAt: time.Now().Add(time.Hour)}
+	if err := sessionStore.CreateSession(stale); err != nil {
+		t.Fatal(err)
+	}
+	if err := userStore.UpdateUser(types.UserRecord{ID: "user", Email: "user@example.com", UserName: "user", Identities: append(user.Identities, types.Identity{Provider: "gitlab", Subject: "3"})}); err != nil {
+		t.Fatal(err)
+	}
+	staleApp := fiber.New()
+	staleApp.Use(loggedInAs("user", "stale"))
+	staleApp.Delete("/identities/:provider", func(c *fiber.Ctx) error { return UnlinkIdentity(c, userStore, sessionStore) })
+	if code := send(t, staleApp, "DELETE", "/identities/gitlab", nil); code != fiber.StatusUnauthorized {
+		t.Errorf("unlinking from an old session answered %d, want 401", code)
+	}
+	if code := send(t, app, "DELETE", "/identities/gitlab", nil); code != fiber.StatusOK {
+		t.Errorf("unlinking from a fresh session answered %d, want 200", code)
+	}
+}
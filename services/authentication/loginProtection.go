@@ -0,0 +1,232 @@
+package authentication
+
+import (
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/froggy-12/purpurbase/config"
+	"github.com/froggy-12/purpurbase/services/smtpconfigs"
+	"github.com/froggy-12/purpurbase/store"
+	"github.com/froggy-12/purpurbase/types"
+	"github.com/froggy-12/purpurbase/utils"
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+)
+
+// the same message for unknown emails and wrong passwords so accounts cant be enumerated
+const wrongCredentials = "Wrong email or password"
+
+func loginProtection() config.LoginProtection {
+	protection := config.Configs.AuthenticationConfigurations.LoginProtection
+	if protection.MaxAccountAttempts <= 0 {
+		protection.MaxAccountAttempts = 5
+	}
+	if protection.MaxIPAttempts <= 0 {
+		protection.MaxIPAttempts = 20
+	}
+	if protection.AttemptWindow <= 0 {
+		protection.AttemptWindow = 15
+	}
+	if protection.LockoutDuration <= 0 {
+		protection.LockoutDuration = 15
+	}
+	if protection.MaxLockoutDuration <= 0 {
+		protection.MaxLockoutDuration = 24 * 60
+	}
+	return protection
+}
+
+func accountAttemptKey(email string) string {
+	return "account:" + strings.ToLower(email)
+}
+
+func ipAttemptKey(ip string) string {
+	return "ip:" + ip
+}
+
+// loginLockedFor is how long log ins for the email or from the ip stay locked, 0 when they aren't
+func loginLockedFor(attempts store.LoginAttemptStore, email, ip string) (time.Duration, error) {
+	if config.Configs.AuthenticationConfigurations.LoginProtection.Disabled {
+		return 0, nil
+	}
+
+	account, err := attempts.LockedFor(accountAttemptKey(email))
+	if err != nil {
+		return 0, err
+	}
+	address, err := attempts.LockedFor(ipAttemptKey(ip))
+	if err != nil {
+		return 0, err
+	}
+	return max(account, address), nil
+}
+
+// lockoutDuration doubles with every lockout of the key within a day, up to maxLockoutDuration
+func lockoutDuration(attempts store.LoginAttemptStore, key string) (time.Duration, error) {
+	protection := loginProtection()
+	lockouts, err := attempts.Lockouts(key)
+	if err != nil {
+		return 0, err
+	}
+
+	duration := time.Duration(protection.LockoutDuration) * time.Minute
+	limit := time.Duration(protection.MaxLockoutDuration) * time.Minute
+	for i := 0; i < lockouts && duration < limit; i++ {
+		duration *= 2
+	}
+	return min(duration, limit), nil
+}
+
+// recordLoginFailure counts the failure for the email and the ip and locks them once they are over the limit,
+// the owner of a locked account gets an email to unlock it. It returns how long the log in is locked now
+func recordLoginFailure(attempts store.LoginAttemptStore, userStore store.UserStore, email, ip string) (time.Duration, error) {
+	protection := loginProtection()
+	if protection.Disabled {
+		return 0, nil
+	}
+
+	window := time.Duration(protection.AttemptWindow) * time.Minute
+	var locked time.Duration
+	for key, limit := range map[string]int{accountAttemptKey(email): protection.MaxAccountAttempts, ipAttemptKey(ip): protection.MaxIPAttempts} {
+		failures, err := attempts.RecordFailure(key, window)
+		if err != nil {
+			return 0, err
+		}
+		if failures < limit {
+			continue
+		}
+
+		duration, err := lockoutDuration(attempts, key)
+		if err != nil {
+			return 0, err
+		}
+		if _, err := attempts.Lock(key, duration); err != nil {
+			return 0, err
+		}
+		utils.DebugLogger("login-protection", "locked "+key+" for "+duration.String())
+
+		if key == accountAttemptKey(email) && config.Configs.SMTPConfigurations.SMTPEnabled {
+			// sent in the background so the response time doesn't tell if the account exists
+			go sendUnlockEmail(attempts, userStore, email, duration)
+		}
+		locked = max(locked, duration)
+	}
+
+	return locked, nil
+}
+
+// loginSucceeded forgets the failures of the account, the ip keeps its count
+func loginSucceeded(attempts store.LoginAttemptStore, email string) {
+	if config.Configs.AuthenticationConfigurations.LoginProtection.Disabled {
+		return
+	}
+	if err := attempts.Unlock(accountAttemptKey(email)); err != nil {
+		utils.DebugLogger("login-protection", "failed to reset failed log ins of "+email+": "+err.Error())
+	}
+}
+
+// tooManyAttempts answers a locked log in
+func tooManyAttempts(c *fiber.Ctx, lockedFor time.Duration) error {
+	c.Set(fiber.HeaderRetryAfter, strconv.Itoa(int(lockedFor.Seconds())+1))
+	return c.Status(fiber.StatusTooManyRequests).JSON(types.ErrorResponse{Error: "Too many failed log in attempts please try again later"})
+}
+
+// loginFailed records a wrong email or password and answers with the same error for both
+func loginFailed(c *fiber.Ctx, attempts store.LoginAttemptStore, userStore store.UserStore, email string) error {
+	locked, err := recordLoginFailure(attempts, userStore, email, c.IP())
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(types.ErrorResponse{Error: "Something went wrong: " + err.Error()})
+	}
+	if locked > 0 {
+		return tooManyAttempts(c, locked)
+	}
+	return c.Status(fiber.StatusBadRequest).JSON(types.ErrorResponse{Error: wrongCredentials})
+}
+
+func unlockURL(token string) string {
+	base := config.Configs.AuthenticationConfigurations.LoginProtection.UnlockURL
+	if base == "" {
+		base = config.Configs.PurpurbaseConfigurations.PurpurbaseBaseURL + ":" + config.Configs.PurpurbaseConfigurations.PurpurbasePort + "/api/auth/unlock"
+	}
+
+	query := url.Values{}
+	query.Set("token", token)
+
+	if strings.Contains(base, "?") {
+		return base + "&" + query.Encode()
+	}
+	return base + "?" + query.Encode()
+}
+
+func sendUnlockEmail(attempts store.LoginAttemptStore, userStore store.UserStore, email string, lockedFor time.Duration) {
+	user, err := userStore.FindUserByEmail(email)
+	if err != nil {
+		return
+	}
+
+	token, err := generateToken()
+	if err != nil {
+		return
+	}
+	if err := attempts.SaveUnlockToken(hashToken(token), accountAttemptKey(user.Email), lockedFor); err != nil {
+		utils.DebugLogger("login-protection", "failed to save unlock token: "+err.Error())
+		return
+	}
+
+	if err := smtpconfigs.SendAccountLockedEmail(user.Email, unlockURL(token), lockedFor); err != nil {
+		utils.DebugLogger("login-protection", "failed to send unlock email to "+user.Email+": "+err.Error())
+	}
+}
+
+// UnlockAccount redeems the emailed unlock token, from ?token= or {"token": ...}
+func UnlockAccount(c *fiber.Ctx, attempts store.LoginAttemptStore, validator validator.Validate) error {
+	var body struct {
+		Token string `json:"token" validate:"required"`
+	}
+
+	body.Token = c.Query("token")
+	if body.Token == "" && c.Method() == fiber.MethodPost {
+		if err := c.BodyParser(&body); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(types.ErrorResponse{Error: "Invalid Request body"})
+		}
+	}
+
+	if err := validator.Struct(&body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(types.ErrorResponse{Error: err.Error()})
+	}
+
+	key, err := attempts.TakeUnlockToken(hashToken(body.Token))
+	if err == store.ErrUnlockTokenNotFound {
+		return c.Status(fiber.StatusBadRequest).JSON(types.ErrorResponse{Error: "Invalid or expired unlock token"})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(types.ErrorResponse{Error: "Something went wrong: " + err.Error()})
+	}
+
+	if err := attempts.Unlock(key); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(types.ErrorResponse{Error: "Failed to unlock account: " + err.Error()})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(types.HTTPSuccessResponse{Message: "Account has been unlocked you can log in again"})
+}
+
+// AdminUnlockUser lifts the log in lock of the user, ?ip= also unlocks that ip
+func AdminUnlockUser(c *fiber.Ctx, userStore store.UserStore, attempts store.LoginAttemptStore) error {
+	user, found, err := adminFindUser(c, userStore)
+	if !found {
+		return err
+	}
+
+	if err := attempts.Unlock(accountAttemptKey(user.Email)); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(types.ErrorResponse{Error: "Failed to unlock user: " + err.Error()})
+	}
+	if ip := c.Query("ip"); ip != "" {
+		if err := attempts.Unlock(ipAttemptKey(ip)); err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(types.ErrorResponse{Error: "Failed to unlock ip: " + err.Error()})
+		}
+	}
+
+	return c.Status(fiber.StatusOK).JSON(types.HTTPSuccessResponse{Message: "User has been unlocked"})
+}
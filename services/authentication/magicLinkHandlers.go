@@ -0,0 +1,267 @@
+package authentication
+
+import (
+	"crypto/rand"
+	"fmt"
+	"math/big"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/froggy-12/purpurbase/config"
+	"github.com/froggy-12/purpurbase/services/authentication/oauth"
+	"github.com/froggy-12/purpurbase/services/smtpconfigs"
+	"github.com/froggy-12/purpurbase/store"
+	"github.com/froggy-12/purpurbase/types"
+	"github.com/froggy-12/purpurbase/utils"
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+const (
+	// codes that can be tried until the user logs in with the emailed link, requesting another code doesn't reset
+	// the count so a 6 digit code can not be guessed 5 tries at a time
+	magicLinkMaxAttempts = 5
+	// links and codes one email can ask for within magicLinkRequestWindow
+	magicLinkMaxRequests   = 3
+	magicLinkRequestWindow = 15 * time.Minute
+)
+
+func magicLinkTokenAge() time.Duration {
+	minutes := config.Configs.AuthenticationConfigurations.MagicLinkTokenAge
+	if minutes <= 0 {
+		minutes = 15
+	}
+	return time.Duration(minutes) * time.Minute
+}
+
+func magicLinkURL(email, token string) string {
+	base := config.Configs.AuthenticationConfigurations.MagicLinkURL
+	if base == "" {
+		base = config.Configs.PurpurbaseConfigurations.PurpurbaseBaseURL + ":" + config.Configs.PurpurbaseConfigurations.PurpurbasePort + "/api/auth/magic-link/verify"
+	}
+
+	query := url.Values{}
+	query.Set("email", email)
+	query.Set("token", token)
+
+	if strings.Contains(base, "?") {
+		return base + "&" + query.Encode()
+	}
+	return base + "?" + query.Encode()
+}
+
+func generateCode() (string, error) {
+	n, err := rand.Int(rand.Reader, big.NewInt(1000000))
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%06d", n.Int64()), nil
+}
+
+// the code is short so it is hashed together with the user id
+func hashMagicLinkCode(userID, code string) string {
+	return hashToken(userID + ":" + code)
+}
+
+func clearMagicLink(user *types.UserRecord) {
+	user.MagicLinkToken = ""
+	user.MagicLinkCode = ""
+	user.MagicLinkExpiresAt = time.Time{}
+	user.MagicLinkAttempts = 0
+}
+
+func magicLinkRequestKey(email string) string {
+	return "magic-link:" + strings.ToLower(email)
+}
+
+// magicLinkFailed counts a wrong link or code like a wrong password
+func magicLinkFailed(c *fiber.Ctx, attempts store.LoginAttemptStore, userStore store.UserStore, email string) error {
+	locked, err := recordLoginFailure(attempts, userStore, email, c.IP())
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(types.ErrorResponse{Error: "Something went wrong: " + err.Error()})
+	}
+	if locked > 0 {
+		return tooManyAttempts(c, locked)
+	}
+	return c.Status(fiber.StatusBadRequest).JSON(types.ErrorResponse{Error: "Invalid or expired log in link or code"})
+}
+
+func createPasswordlessUser(userStore store.UserStore, email string) (types.UserRecord, error) {
+	newUser := types.UserRecord{
+		ID:                uuid.New().String(),
+		Email:             email,
+		Password:          "",
+		CreatedAt:         time.Now(),
+		UpdatedAt:         time.Now(),
+		Verified:          false,
+		VerificationToken: uuid.New().String(),
+		RawData:           map[string]any{},
+		Roles:             defaultRoles(),
+	}
+
+	localPart, _, _ := strings.Cut(email, "@")
+	profile := oauth.Profile{Provider: "email", Email: email, UserName: localPart}
+	newUser.UserName = pickUserName(userStore, profile)
+
+	return newUser, userStore.CreateUser(newUser)
+}
+
+func RequestMagicLink(c *fiber.Ctx, userStore store.UserStore, attempts store.LoginAttemptStore, validator validator.Validate) error {
+	if !config.Configs.AuthenticationConfigurations.MagicLink {
+		return c.Status(fiber.StatusBadRequest).JSON(types.ErrorResponse{Error: "Magic link log in is not configured or turned off please check again and restart the app"})
+	}
+
+	if !config.Configs.SMTPConfigurations.SMTPEnabled {
+		return c.Status(fiber.StatusBadRequest).JSON(types.ErrorResponse{Error: "SMTP is not configured or turned off please check again and restart the app"})
+	}
+
+	var body struct {
+		Email string `json:"email" validate:"required,email"`
+	}
+
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(types.ErrorResponse{Error: "Invalid Request body"})
+	}
+
+	if err := validator.Struct(&body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(types.ErrorResponse{Error: err.Error()})
+	}
+
+	// counted for unknown emails too, only known emails being limited would give them away
+	requests, err := attempts.RecordFailure(magicLinkRequestKey(body.Email), magicLinkRequestWindow)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(types.ErrorResponse{Error: "Something went wrong: " + err.Error()})
+	}
+	if requests > magicLinkMaxRequests {
+		c.Set(fiber.HeaderRetryAfter, strconv.Itoa(int(magicLinkRequestWindow.Seconds())))
+		return c.Status(fiber.StatusTooManyRequests).JSON(types.ErrorResponse{Error: "Too many log in links have been requested for this email please try again later"})
+	}
+
+	// same answer for known and unknown emails so accounts can't be enumerated
+	sent := types.HTTPSuccessResponse{Message: "If the email can log in a link and a code have been sent"}
+
+	user, err := userStore.FindUserByEmail(body.Email)
+	if err == store.ErrUserNotFound {
+		if !config.Configs.AuthenticationConfigurations.MagicLinkSignUp {
+			return c.Status(fiber.StatusAccepted).JSON(sent)
+		}
+		user, err = createPasswordlessUser(userStore, body.Email)
+		if err != nil {
+			return c.Status(fiber.StatusBadGateway).JSON(types.ErrorResponse{Error: "failed to create new user into the database: " + err.Error()})
+		}
+		auditRequest(c, AuditSignedUp, user, true, map[string]string{"method": "magic_link"})
+	} else if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(types.ErrorResponse{Error: "Something went wrong: " + err.Error()})
+	}
+
+	token, err := generateToken()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(types.ErrorResponse{Error: "Failed to generate log in token"})
+	}
+	code, err := generateCode()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(types.ErrorResponse{Error: "Failed to generate log in code"})
+	}
+
+	if err := userStore.SetMagicLink(user.ID, hashToken(token), hashMagicLinkCode(user.ID, code), time.Now().Add(magicLinkTokenAge())); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(types.ErrorResponse{Error: "failed to update new token: " + err.Error()})
+	}
+
+	if err := smtpconfigs.SendMagicLinkEmail(user.Email, magicLinkURL(user.Email, token), code, magicLinkTokenAge()); err != nil {
+		utils.DebugLogger("magic-link", "failed to send magic link to "+user.Email+": "+err.Error())
+	}
+
+	return c.Status(fiber.StatusAccepted).JSON(sent)
+}
+
+// VerifyMagicLink logs the user in with the emailed token (GET from the link or POST) or the 6 digit code (POST)
+func VerifyMagicLink(c *fiber.Ctx, userStore store.UserStore, sessionStore store.SessionStore, attempts store.LoginAttemptStore, validator validator.Validate) error {
+	if !config.Configs.AuthenticationConfigurations.MagicLink {
+		return c.Status(fiber.StatusBadRequest).JSON(types.ErrorResponse{Error: "Magic link log in is not configured or turned off please check again and restart the app"})
+	}
+
+	var body struct {
+		Email string `json:"email" validate:"required,email"`
+		Token string `json:"token" validate:"required_without=Code"`
+		Code  string `json:"code"`
+	}
+
+	if c.Method() == fiber.MethodGet {
+		body.Email = c.Query("email")
+		body.Token = c.Query("token")
+	} else if err := c.BodyParser(&body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(types.ErrorResponse{Error: "Invalid Request body"})
+	}
+
+	if err := validator.Struct(&body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(types.ErrorResponse{Error: err.Error()})
+	}
+
+	lockedFor, err := loginLockedFor(attempts, body.Email, c.IP())
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(types.ErrorResponse{Error: "Something went wrong: " + err.Error()})
+	}
+	if lockedFor > 0 {
+		auditLogInFailed(c, types.UserRecord{}, body.Email, "magic_link", "locked")
+		return tooManyAttempts(c, lockedFor)
+	}
+
+	user, err := userStore.FindUserByEmail(body.Email)
+	if err == store.ErrUserNotFound {
+		return magicLinkFailed(c, attempts, userStore, body.Email)
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(types.ErrorResponse{Error: "Something went wrong: " + err.Error()})
+	}
+
+	if user.MagicLinkToken == "" || time.Now().After(user.MagicLinkExpiresAt) {
+		auditLogInFailed(c, user, user.Email, "magic_link", "expired")
+		return magicLinkFailed(c, attempts, userStore, user.Email)
+	}
+
+	var matches bool
+	if body.Token != "" {
+		matches = tokenMatches(body.Token, user.MagicLinkToken)
+	} else {
+		// the attempt is counted in the store before the code is checked so parallel guesses can't pass the limit
+		err := userStore.CountMagicLinkAttempt(user.ID, magicLinkMaxAttempts)
+		if err == store.ErrTooManyAttempts {
+			auditLogInFailed(c, user, user.Email, "magic_link", "too_many_codes")
+			return magicLinkFailed(c, attempts, userStore, user.Email)
+		}
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(types.ErrorResponse{Error: "Something went wrong: " + err.Error()})
+		}
+		matches = tokenMatches(user.ID+":"+body.Code, user.MagicLinkCode)
+	}
+
+	if !matches {
+		auditLogInFailed(c, user, user.Email, "magic_link", "wrong_code")
+		return magicLinkFailed(c, attempts, userStore, user.Email)
+	}
+
+	// redeeming the link proves the user owns the email
+	clearMagicLink(&user)
+	user.Verified = true
+	if err := userStore.UpdateUser(user); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(types.ErrorResponse{Error: "Something went wrong: " + err.Error()})
+	}
+
+	if user.TOTPEnabled {
+		return sendMFAChallenge(c, user)
+	}
+	loginSucceeded(attempts, user.Email)
+
+	tokens, err := logInSession(c, userStore, sessionStore, user, "magic_link")
+	if err != nil {
+		return sessionFailed(c, err)
+	}
+
+	return c.Status(fiber.StatusAccepted).JSON(types.HTTPSuccessResponse{
+		Message: "User has been logged in successfully",
+		Data:    withTokens(c, tokens, map[string]any{"userID": user.ID}),
+	})
+}
@@ -0,0 +1,51 @@
+package mongodb
+
+import (
+	"context"
+
+	"github.com/froggy-12/purpurbase/store"
+	"github.com/froggy-12/purpurbase/types"
+	"github.com/gofiber/contrib/websocket"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func GetRealTimeUserData(c *websocket.Conn, mongoClient *mongo.Client) {
+	userId := c.Query("user_id")
+
+	if userId == "" {
+		c.WriteJSON(types.ErrorResponse{Error: "User ID is required"})
+		c.Close()
+		return
+	}
+	coll := mongoClient.Database("purpurbase").Collection("users")
+	userStore := store.NewMongoUserStore(mongoClient)
+
+	user, err := userStore.FindUserByID(userId)
+
+	if err != nil {
+		c.WriteJSON(types.ErrorResponse{Error: "User Not Found!"})
+		c.Close()
+		return
+	}
+
+	c.WriteJSON(types.HTTPSuccessResponse{Data: map[string]any{"userData": user}})
+
+	cur, err := coll.Watch(context.TODO(), mongo.Pipeline{})
+	if err != nil {
+		c.WriteJSON(types.ErrorResponse{Error: "Failed to establish change stream: " + err.Error()})
+		c.Close()
+		return
+	}
+
+	defer cur.Close(context.TODO())
+
+	for cur.Next(context.TODO()) {
+		user, err = userStore.FindUserByID(user.ID)
+		if err != nil {
+			c.WriteJSON("Something Went Wrong")
+			c.Close()
+			return
+		}
+		c.WriteJSON(types.HTTPSuccessResponse{Data: map[string]any{"user": user}})
+	}
+}
@@ -0,0 +1,458 @@
+package oauth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/froggy-12/purpurbase/config"
+	"github.com/froggy-12/purpurbase/types"
+	"github.com/froggy-12/purpurbase/utils"
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const stateCookieName = "oauthState"
+
+// stateKey signs the state cookie, it is derived so the cookie can't pass as any other token
+func stateKey() []byte {
+	return utils.DeriveKey(config.Configs.PurpurbaseConfigurations.PurpurbaseJWTTokenSecret, "oauth-state")
+}
+
+// Profile is the part of the provider's user info purpurbase cares about
+type Profile struct {
+	Provider      string
+	ProviderID    string
+	Email         string
+	EmailVerified bool
+	FirstName     string
+	LastName      string
+	UserName      string
+	Picture       string
+	Link          bool // the log in was started with ?link=true to link the account to the logged in user
+}
+
+type provider struct {
+	name      string
+	clientID  string
+	secret    string
+	scopes    []string
+	endpoints config.OAuthEndpoints
+}
+
+var httpClient = &http.Client{Timeout: 15 * time.Second}
+
+func getProvider(name string) (provider, error) {
+	authConfigs := config.Configs.AuthenticationConfigurations
+	if !authConfigs.OAuth {
+		return provider{}, errors.New("OAuth is not configured or turned off please check again and restart the app")
+	}
+
+	switch name {
+	case "google":
+		if !authConfigs.GoogleOAuth {
+			return provider{}, errors.New("google oauth is turned off")
+		}
+		return provider{
+			name:     "google",
+			clientID: authConfigs.GoogleOAuthAppID,
+			secret:   authConfigs.GoogleOAuthAppSecret,
+			scopes:   []string{"openid", "email", "profile"},
+			endpoints: withDefaults(authConfigs.GoogleOAuthEndpoints, config.OAuthEndpoints{
+				AuthURL:     "https://accounts.google.com/o/oauth2/v2/auth",
+				TokenURL:    "https://oauth2.googleapis.com/token",
+				UserInfoURL: "https://openidconnect.googleapis.com/v1/userinfo",
+			}),
+		}, nil
+	case "github":
+		if !authConfigs.GithubOAuth {
+			return provider{}, errors.New("github oauth is turned off")
+		}
+		return provider{
+			name:     "github",
+			clientID: authConfigs.GithubOAuthAppID,
+			secret:   authConfigs.GithubOAuthAppSecret,
+			scopes:   []string{"read:user", "user:email"},
+			endpoints: withDefaults(authConfigs.GithubOAuthEndpoints, config.OAuthEndpoints{
+				AuthURL:     "https://github.com/login/oauth/authorize",
+				TokenURL:    "https://github.com/login/oauth/access_token",
+				UserInfoURL: "https://api.github.com/user",
+				EmailsURL:   "https://api.github.com/user/emails",
+			}),
+		}, nil
+	default:
+		return provider{}, errors.New("unsupported oauth provider: " + name)
+	}
+}
+
+// older configs.json files dont have the endpoints so fall back to the real providers
+func withDefaults(endpoints, defaults config.OAuthEndpoints) config.OAuthEndpoints {
+	if endpoints.AuthURL == "" {
+		endpoints.AuthURL = defaults.AuthURL
+	}
+	if endpoints.TokenURL == "" {
+		endpoints.TokenURL = defaults.TokenURL
+	}
+	if endpoints.UserInfoURL == "" {
+		endpoints.UserInfoURL = defaults.UserInfoURL
+	}
+	if endpoints.EmailsURL == "" {
+		endpoints.EmailsURL = defaults.EmailsURL
+	}
+	return endpoints
+}
+
+func callbackURL(providerName string) string {
+	return strings.TrimSuffix(config.Configs.AuthenticationConfigurations.OAuthCallbackBaseURL, "/") + "/api/auth/oauth/" + providerName + "/callback"
+}
+
+func randomString(size int) (string, error) {
+	b := make([]byte, size)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// StartLogin redirects the browser to the provider, the state and pkce verifier are kept
+// in a short lived signed cookie so the callback can validate them. ?link=true links the
+// provider account to the logged in user instead of logging in with it
+func StartLogin(c *fiber.Ctx) error {
+	p, err := getProvider(c.Params("provider"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(types.ErrorResponse{Error: err.Error()})
+	}
+
+	state, err := randomString(32)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(types.ErrorResponse{Error: "failed to generate oauth state: " + err.Error()})
+	}
+	verifier, err := randomString(32)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(types.ErrorResponse{Error: "failed to generate pkce verifier: " + err.Error()})
+	}
+
+	stateToken, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"provider": p.name,
+		"state":    state,
+		"verifier": verifier,
+		"link":     c.Query("link") == "true",
+		"exp":      time.Now().Add(10 * time.Minute).Unix(),
+	}).SignedString(stateKey())
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(types.ErrorResponse{Error: "failed to sign oauth state: " + err.Error()})
+	}
+
+	c.Cookie(&fiber.Cookie{
+		Name:     stateCookieName,
+		Value:    stateToken,
+		Path:     "/api/auth/oauth",
+		HTTPOnly: true,
+		Secure:   true,
+		SameSite: fiber.CookieSameSiteLaxMode,
+		MaxAge:   10 * 60,
+	})
+
+	challenge := sha256.Sum256([]byte(verifier))
+
+	query := url.Values{}
+	query.Set("response_type", "code")
+	query.Set("client_id", p.clientID)
+	query.Set("redirect_uri", callbackURL(p.name))
+	query.Set("scope", strings.Join(p.scopes, " "))
+	query.Set("state", state)
+	query.Set("code_challenge", base64.RawURLEncoding.EncodeToString(challenge[:]))
+	query.Set("code_challenge_method", "S256")
+
+	return c.Redirect(p.endpoints.AuthURL+"?"+query.Encode(), fiber.StatusTemporaryRedirect)
+}
+
+// CompleteLogin validates the callback against the state cookie, exchanges the code and
+// returns the provider profile. the email is only returned when the provider verified it
+func CompleteLogin(c *fiber.Ctx) (Profile, error) {
+	p, err := getProvider(c.Params("provider"))
+	if err != nil {
+		return Profile{}, err
+	}
+
+	if providerError := c.Query("error"); providerError != "" {
+		return Profile{}, errors.New("provider returned an error: " + providerError)
+	}
+
+	stateCookie := c.Cookies(stateCookieName)
+	c.Cookie(&fiber.Cookie{Name: stateCookieName, Value: "", Path: "/api/auth/oauth", HTTPOnly: true, Secure: true, MaxAge: -1})
+	if stateCookie == "" {
+		return Profile{}, errors.New("oauth state not found please start the log in again")
+	}
+
+	claims := jwt.MapClaims{}
+	_, err = jwt.ParseWithClaims(stateCookie, claims, func(t *jwt.Token) (interface{}, error) {
+		return stateKey(), nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}), jwt.WithExpirationRequired())
+	if err != nil {
+		return Profile{}, errors.New("invalid oauth state: " + err.Error())
+	}
+
+	state, _ := claims["state"].(string)
+	verifier, _ := claims["verifier"].(string)
+	providerName, _ := claims["provider"].(string)
+	link, _ := claims["link"].(bool)
+	if state == "" || verifier == "" || providerName != p.name || c.Query("state") != state {
+		return Profile{}, errors.New("oauth state mismatch")
+	}
+
+	code := c.Query("code")
+	if code == "" {
+		return Profile{}, errors.New("no authorization code provided")
+	}
+
+	accessToken, err := exchangeCode(p, code, verifier)
+	if err != nil {
+		return Profile{}, err
+	}
+
+	fetchProfile := fetchGoogleProfile
+	if p.name == "github" {
+		fetchProfile = fetchGithubProfile
+	}
+
+	profile, err := fetchProfile(p, accessToken)
+	profile.Link = link
+	return profile, err
+}
+
+func exchangeCode(p provider, code, verifier string) (string, error) {
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", callbackURL(p.name))
+	form.Set("client_id", p.clientID)
+	form.Set("client_secret", p.secret)
+	form.Set("code_verifier", verifier)
+
+	req, err := http.NewRequest(http.MethodPost, p.endpoints.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	var body struct {
+		AccessToken      string `json:"access_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	if err := doJSON(req, &body); err != nil {
+		return "", errors.New("failed to exchange authorization code: " + err.Error())
+	}
+	if body.Error != "" {
+		return "", errors.New("failed to exchange authorization code: " + body.Error + " " + body.ErrorDescription)
+	}
+	if body.AccessToken == "" {
+		return "", errors.New("provider did not return an access token")
+	}
+
+	return body.AccessToken, nil
+}
+
+func fetchGoogleProfile(p provider, accessToken string) (Profile, error) {
+	var info struct {
+		Sub           string `json:"sub"`
+		Email         string `json:"email"`
+		EmailVerified any    `json:"email_verified"` // google has sent both bools and strings here
+		GivenName     string `json:"given_name"`
+		FamilyName    string `json:"family_name"`
+		Name          string `json:"name"`
+		Picture       string `json:"picture"`
+	}
+
+	if err := getJSON(p.endpoints.UserInfoURL, accessToken, &info); err != nil {
+		return Profile{}, errors.New("failed to fetch google profile: " + err.Error())
+	}
+
+	if info.Sub == "" {
+		return Profile{}, errors.New("google profile has no subject")
+	}
+
+	profile := Profile{
+		Provider:      "google",
+		ProviderID:    info.Sub,
+		Email:         strings.ToLower(info.Email),
+		EmailVerified: info.EmailVerified == true || info.EmailVerified == "true",
+		FirstName:     info.GivenName,
+		LastName:      info.FamilyName,
+		Picture:       info.Picture,
+	}
+	if profile.FirstName == "" {
+		profile.FirstName, profile.LastName = splitName(info.Name)
+	}
+	profile.UserName = strings.Split(profile.Email, "@")[0]
+
+	return profile, nil
+}
+
+func fetchGithubProfile(p provider, accessToken string) (Profile, error) {
+	var info struct {
+		ID        int64  `json:"id"`
+		Login     string `json:"login"`
+		Name      string `json:"name"`
+		AvatarURL string `json:"avatar_url"`
+	}
+
+	if err := getJSON(p.endpoints.UserInfoURL, accessToken, &info); err != nil {
+		return Profile{}, errors.New("failed to fetch github profile: " + err.Error())
+	}
+
+	if info.ID == 0 {
+		return Profile{}, errors.New("github profile has no id")
+	}
+
+	// the email on /user is whatever the user made public, so ask for the verified primary one
+	var emails []struct {
+		Email    string `json:"email"`
+		Primary  bool   `json:"primary"`
+		Verified bool   `json:"verified"`
+	}
+	if err := getJSON(p.endpoints.EmailsURL, accessToken, &emails); err != nil {
+		return Profile{}, errors.New("failed to fetch github emails: " + err.Error())
+	}
+
+	profile := Profile{
+		Provider:   "github",
+		ProviderID: strconv.FormatInt(info.ID, 10),
+		UserName:   info.Login,
+		Picture:    info.AvatarURL,
+	}
+	for _, email := range emails {
+		if email.Primary {
+			profile.Email = strings.ToLower(email.Email)
+			profile.EmailVerified = email.Verified
+		}
+	}
+
+	profile.FirstName, profile.LastName = splitName(info.Name)
+	if profile.FirstName == "" {
+		profile.FirstName = info.Login
+	}
+
+	return profile, nil
+}
+
+func getJSON(endpoint, accessToken string, v any) error {
+	req, err := http.NewRequest(http.MethodGet, endpoint, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+	req.Header.Set("Accept", "application/json")
+	return doJSON(req, v)
+}
+
+func doJSON(req *http.Request, v any) error {
+	res, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return fmt.Errorf("unexpected status %d from %s", res.StatusCode, req.URL.Host)
+	}
+
+	return json.NewDecoder(res.Body).Decode(v)
+}
+
+func splitName(name string) (string, string) {
+	parts := strings.Fields(name)
+	if len(parts) == 0 {
+		return "", ""
+	}
+	return parts[0], strings.Join(parts[1:], " ")
+}
+
+// CheckProfile makes sure the profile can be used to find or create a purpurbase user
+func CheckProfile(profile Profile) error {
+	if profile.Email == "" || !profile.EmailVerified {
+		return errors.New("the " + profile.Provider + " account has no verified email address")
+	}
+	return nil
+}
+
+// UserNameCandidate returns a username derived from the profile, attempt > 0 appends a random suffix
+func UserNameCandidate(profile Profile, attempt int) string {
+	base := strings.ToLower(profile.UserName)
+	if base == "" {
+		base = profile.Provider + "user"
+	}
+	if attempt == 0 {
+		return base
+	}
+	suffix, err := randomString(4)
+	if err != nil {
+		suffix = strconv.Itoa(attempt)
+	}
+	return base + "-" + strings.ToLower(suffix)
+}
+
+// FinishLogin sends the browser back to the frontend, or answers with json when no redirect is configured
+func FinishLogin(c *fiber.Ctx, userID string) error {
+	redirectURL := config.Configs.AuthenticationConfigurations.OAuthSuccessRedirectURL
+	if redirectURL != "" {
+		return c.Redirect(redirectURL, fiber.StatusSeeOther)
+	}
+
+	return c.Status(fiber.StatusAccepted).JSON(types.HTTPSuccessResponse{
+		Message: "User has been logged in successfully",
+		Data:    map[string]any{"userID": userID},
+	})
+}
+
+// FinishLink sends the browser back to the frontend with ?linked=<provider> after a provider account has been
+// linked, or answers with json when no redirect is configured
+func FinishLink(c *fiber.Ctx, provider string) error {
+	redirectURL := config.Configs.AuthenticationConfigurations.OAuthSuccessRedirectURL
+	if redirectURL != "" {
+		target, err := url.Parse(redirectURL)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(types.ErrorResponse{Error: "Invalid oauth success redirect url"})
+		}
+		query := target.Query()
+		query.Set("linked", provider)
+		target.RawQuery = query.Encode()
+		return c.Redirect(target.String(), fiber.StatusSeeOther)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(types.HTTPSuccessResponse{
+		Message: "The " + provider + " account has been linked",
+		Data:    map[string]any{"linked": provider},
+	})
+}
+
+// FinishMFAChallenge hands the mfa token to the client when the account has two factor authentication,
+// the redirect carries it as the mfaToken query parameter
+func FinishMFAChallenge(c *fiber.Ctx, mfaToken string) error {
+	redirectURL := config.Configs.AuthenticationConfigurations.OAuthSuccessRedirectURL
+	if redirectURL != "" {
+		target, err := url.Parse(redirectURL)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(types.ErrorResponse{Error: "Invalid oauth success redirect url"})
+		}
+		query := target.Query()
+		query.Set("mfaToken", mfaToken)
+		target.RawQuery = query.Encode()
+		return c.Redirect(target.String(), fiber.StatusSeeOther)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(types.HTTPSuccessResponse{
+		Message: "Two factor authentication code required",
+		Data:    map[string]any{"mfaRequired": true, "mfaToken": mfaToken},
+	})
+}
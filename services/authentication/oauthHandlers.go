@@ -0,0 +1,173 @@
+package authentication
+
+import (
+	"strings"
+	"time"
+
+	"github.com/froggy-12/purpurbase/config"
+	"github.com/froggy-12/purpurbase/services/authentication/oauth"
+	"github.com/froggy-12/purpurbase/store"
+	"github.com/froggy-12/purpurbase/types"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+func OAuthCallback(c *fiber.Ctx, userStore store.UserStore, sessionStore store.SessionStore) error {
+	profile, err := oauth.CompleteLogin(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(types.ErrorResponse{Error: err.Error()})
+	}
+
+	if err := oauth.CheckProfile(profile); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(types.ErrorResponse{Error: err.Error()})
+	}
+
+	identity := types.Identity{Provider: profile.Provider, Subject: profile.ProviderID, Email: profile.Email, LinkedAt: time.Now()}
+	method := "oauth:" + identity.Provider
+
+	if profile.Link {
+		return linkOAuthIdentity(c, userStore, sessionStore, identity)
+	}
+
+	user, err := userStore.FindUserByIdentity(identity.Provider, identity.Subject)
+	if err != nil && err != store.ErrUserNotFound {
+		return c.Status(fiber.StatusInternalServerError).JSON(types.ErrorResponse{Error: "Something went wrong: " + err.Error()})
+	}
+
+	if err == store.ErrUserNotFound {
+		// a guest logging in with a provider keeps its user, unless the email already has an account
+		user, err = userStore.FindUserByEmail(profile.Email)
+		guest, isGuest := currentGuest(c, userStore, sessionStore)
+
+		switch {
+		case err == store.ErrUserNotFound && isGuest:
+			if user, err = upgradeGuestWithOAuth(userStore, guest, profile, identity); err != nil {
+				return c.Status(fiber.StatusBadGateway).JSON(types.ErrorResponse{Error: "failed to upgrade guest user: " + err.Error()})
+			}
+			auditRequest(c, AuditUpgraded, user, true, map[string]string{"method": method})
+		case err == store.ErrUserNotFound:
+			if user, err = createOAuthUser(userStore, profile, identity); err != nil {
+				return c.Status(fiber.StatusBadGateway).JSON(types.ErrorResponse{Error: "failed to create new user into the database: " + err.Error()})
+			}
+			auditRequest(c, AuditSignedUp, user, true, map[string]string{"method": method})
+		case err != nil:
+			return c.Status(fiber.StatusInternalServerError).JSON(types.ErrorResponse{Error: "Something went wrong: " + err.Error()})
+		default:
+			if !oauthMergeAllowed(user) || linkedProvider(user, identity.Provider) {
+				auditLogInFailed(c, user, profile.Email, method, "merge_refused")
+				return c.Status(fiber.StatusConflict).JSON(types.ErrorResponse{Error: "An account with this email already exists, log in to it and link your " + identity.Provider + " account from there"})
+			}
+			claimed := !user.Verified
+			if claimed {
+				// nobody proved they own the email of the account, whoever signed it up keeps none of its credentials
+				dropCredentials(&user)
+				if err := sessionStore.DeleteUserSessions(user.ID); err != nil {
+					return c.Status(fiber.StatusInternalServerError).JSON(types.ErrorResponse{Error: "Failed to log out the sessions of the account: " + err.Error()})
+				}
+			}
+			user.Identities = append(user.Identities, identity)
+			if err := userStore.UpdateUser(user); err != nil {
+				return c.Status(fiber.StatusInternalServerError).JSON(types.ErrorResponse{Error: "Failed to link " + identity.Provider + " account: " + err.Error()})
+			}
+			details := map[string]string{"provider": identity.Provider, "via": "email_match"}
+			if claimed {
+				details["credentialsDropped"] = "true"
+			}
+			auditRequest(c, AuditIdentityLinked, user, true, details)
+		}
+	}
+
+	// the provider has verified the email so the account counts as verified too, an unverified account only gets
+	// here with credentials its owner proved or after they were dropped by the merge above
+	if !user.Verified && strings.EqualFold(user.Email, profile.Email) {
+		if err := userStore.SetVerified(user.ID, true); err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(types.ErrorResponse{Error: "Failed to update user verification status"})
+		}
+	}
+
+	if user.TOTPEnabled {
+		token, err := generateMFAChallenge(user)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(types.ErrorResponse{Error: "Failed to generate mfa challenge token"})
+		}
+		return oauth.FinishMFAChallenge(c, token)
+	}
+
+	if _, err := logInSession(c, userStore, sessionStore, user, method); err != nil {
+		return sessionFailed(c, err)
+	}
+
+	return oauth.FinishLogin(c, user.ID)
+}
+
+func createOAuthUser(userStore store.UserStore, profile oauth.Profile, identity types.Identity) (types.UserRecord, error) {
+	newUser := types.UserRecord{
+		ID:                uuid.New().String(),
+		FirstName:         profile.FirstName,
+		LastName:          profile.LastName,
+		Email:             profile.Email,
+		Password:          "",
+		ProfilePicture:    profile.Picture,
+		CreatedAt:         time.Now(),
+		UpdatedAt:         time.Now(),
+		Verified:          true,
+		VerificationToken: uuid.New().String(),
+		RawData:           map[string]any{},
+		Roles:             defaultRoles(),
+		Identities:        []types.Identity{identity},
+	}
+
+	newUser.UserName = pickUserName(userStore, profile)
+
+	return newUser, userStore.CreateUser(newUser)
+}
+
+// pickUserName returns the first free username candidate for the profile
+func pickUserName(userStore store.UserStore, profile oauth.Profile) string {
+	var userName string
+	for attempt := 0; attempt < 5; attempt++ {
+		userName = oauth.UserNameCandidate(profile, attempt)
+		if _, err := userStore.FindUserByUsername(userName); err == store.ErrUserNotFound {
+			break
+		}
+	}
+	return userName
+}
+
+// oauthMergeAllowed applies oauthAccountMerge to an oauth log in with the email of an account that hasn't
+// linked the provider. Accounts made by oauth before identities existed are verified so the default still links them
+func oauthMergeAllowed(user types.UserRecord) bool {
+	switch config.Configs.AuthenticationConfigurations.OAuthAccountMerge {
+	case "never":
+		return false
+	case "link":
+		return true
+	default:
+		return user.Verified
+	}
+}
+
+// dropCredentials removes every way into an account whose email was never verified, the password, other linked
+// identities, 2fa and pending tokens or email changes could all belong to somebody that signed up with an email
+// that isn't theirs
+func dropCredentials(user *types.UserRecord) {
+	user.Password = ""
+	user.PasswordHistory = nil
+	user.PasswordResetToken = ""
+	user.PasswordResetExpiresAt = time.Time{}
+	user.PendingEmail = ""
+	user.EmailChangeToken = ""
+	user.EmailChangeExpiresAt = time.Time{}
+	user.EmailRevertToken = ""
+	user.EmailRevertAddress = ""
+	user.EmailRevertExpiresAt = time.Time{}
+	user.Identities = nil
+	user.TOTPEnabled = false
+	user.TOTPSecret = ""
+	user.TOTPRecoveryCodes = nil
+	user.TOTPLastStep = 0
+	user.MagicLinkToken = ""
+	user.MagicLinkCode = ""
+	user.MagicLinkExpiresAt = time.Time{}
+	user.MagicLinkAttempts = 0
+}
@@ -0,0 +1,201 @@
+package authentication
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/froggy-12/purpurbase/config"
+	"github.com/froggy-12/purpurbase/services/authentication/oauth"
+	"github.com/froggy-12/purpurbase/store"
+	"github.com/froggy-12/purpurbase/types"
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestOAuthMergeAllowed(t *testing.T) {
+	previous := config.Configs.AuthenticationConfigurations.OAuthAccountMerge
+	t.Cleanup(func() { config.Configs.AuthenticationConfigurations.OAuthAccountMerge = previous })
+
+	verified := types.UserRecord{Password: "hash", Verified: true}
+	unverified := types.UserRecord{Password: "hash"}
+	noCredentials := types.UserRecord{}
+
+	tests := []struct {
+		setting string
+		user    types.UserRecord
+		want    bool
+	}{
+		{"verified", verified, true},
+		{"verified", unverified, false},
+		{"verified", noCredentials, false},
+		{"link", unverified, true},
+		{"link", noCredentials, true},
+		{"never", verified, false},
+		{"never", noCredentials, false},
+	}
+
+	for _, test := range tests {
+		config.Configs.AuthenticationConfigurations.OAuthAccountMerge = test.setting
+		if got := oauthMergeAllowed(test.user); got != test.want {
+			t.Errorf("oauthMergeAllowed(%+v) with %q = %v, want %v", test.user, test.setting, got, test.want)
+		}
+	}
+}
+
+// mockProvider is a google compatible provider that only hands out tokens for codes it issued, and only to the
+// verifier of the pkce challenge the code was issued for
+type mockProvider struct {
+	*httptest.Server
+	mu         sync.Mutex
+	challenges map[string]string // code -> code_challenge
+}
+
+func newMockProvider(t *testing.T) *mockProvider {
+	t.Helper()
+	provider := &mockProvider{challenges: map[string]string{}}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		provider.mu.Lock()
+		challenge, ok := provider.challenges[r.FormValue("code")]
+		delete(provider.challenges, r.FormValue("code"))
+		provider.mu.Unlock()
+
+		verifier := sha256.Sum256([]byte(r.FormValue("code_verifier")))
+		if !ok || r.FormValue("client_id") != "client" || r.FormValue("client_secret") != "client-secret" ||
+			r.FormValue("redirect_uri") != "http://purpurbase.test/api/auth/oauth/google/callback" ||
+			base64.RawURLEncoding.EncodeToString(verifier[:]) != challenge {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{"access_token": "access-token", "token_type": "Bearer"})
+	})
+	mux.HandleFunc("/userinfo", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer access-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]any{"sub": "42", "email": "User@Example.com", "email_verified": true, "name": "Some User"})
+	})
+	provider.Server = httptest.NewServer(mux)
+	t.Cleanup(provider.Close)
+
+	previous := config.Configs.AuthenticationConfigurations
+	t.Cleanup(func() { config.Configs.AuthenticationConfigurations = previous })
+	authConfigs := &config.Configs.AuthenticationConfigurations
+	authConfigs.OAuth, authConfigs.GoogleOAuth, authConfigs.GithubOAuth = true, true, true
+	authConfigs.GoogleOAuthAppID, authConfigs.GoogleOAuthAppSecret = "client", "client-secret"
+	authConfigs.GoogleOAuthEndpoints = config.OAuthEndpoints{AuthURL: provider.URL + "/authorize", TokenURL: provider.URL + "/token", UserInfoURL: provider.URL + "/userinfo"}
+	authConfigs.OAuthCallbackBaseURL = "http://purpurbase.test"
+	authConfigs.OAuthSuccessRedirectURL = ""
+	return provider
+}
+
+// authorize is the user agreeing at the provider, it returns the code the provider redirects back with
+func (p *mockProvider) authorize(t *testing.T, redirect string) (string, string) {
+	t.Helper()
+	target, err := url.Parse(redirect)
+	if err != nil {
+		t.Fatal(err)
+	}
+	query := target.Query()
+	if !strings.HasPrefix(redirect, p.URL+"/authorize?") || query.Get("code_challenge_method") != "S256" || query.Get("client_id") != "client" {
+		t.Fatalf("the log in was sent to %s", redirect)
+	}
+	code := "code-" + query.Get("state")
+	p.mu.Lock()
+	p.challenges[code] = query.Get("code_challenge")
+	p.mu.Unlock()
+	return code, query.Get("state")
+}
+
+func TestOAuthLogInChecksStateAndPKCE(t *testing.T) {
+	useTestSecret(t)
+	previousAge := config.Configs.PurpurbaseConfigurations.PurpurbaseCookieAndCoresAge
+	config.Configs.PurpurbaseConfigurations.PurpurbaseCookieAndCoresAge = 1
+	t.Cleanup(func() { config.Configs.PurpurbaseConfigurations.PurpurbaseCookieAndCoresAge = previousAge })
+	provider := newMockProvider(t)
+	userStore := store.NewMemoryUserStore()
+	sessionStore := store.NewMemorySessionStore()
+
+	app := fiber.New()
+	app.Get("/api/auth/oauth/:provider/start", oauth.StartLogin)
+	app.Get("/api/auth/oauth/:provider/callback", func(c *fiber.Ctx) error { return OAuthCallback(c, userStore, sessionStore) })
+
+	start := func() (stateCookie, code, state string) {
+		response, err := app.Test(httptest.NewRequest("GET", "/api/auth/oauth/google/start", nil), -1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, cookie := range response.Cookies() {
+			if cookie.Name == "oauthState" {
+				stateCookie = cookie.Value
+			}
+		}
+		if response.StatusCode != fiber.StatusTemporaryRedirect || stateCookie == "" {
+			t.Fatalf("starting answered %d with the state cookie %q", response.StatusCode, stateCookie)
+		}
+		code, state = provider.authorize(t, response.Header.Get(fiber.HeaderLocation))
+		return stateCookie, code, state
+	}
+	callback := func(provider, stateCookie, code, state string) int {
+		req := httptest.NewRequest("GET", "/api/auth/oauth/"+provider+"/callback?"+url.Values{"code": {code}, "state": {state}}.Encode(), nil)
+		if stateCookie != "" {
+			req.Header.Set("Cookie", "oauthState="+stateCookie)
+		}
+		response, err := app.Test(req, -1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return response.StatusCode
+	}
+
+	cookie, code, state := start()
+	otherCookie, _, otherState := start()
+	refused := []struct {
+		name                               string
+		provider, stateCookie, code, state string
+	}{
+		{"no state cookie", "google", "", code, state},
+		{"state of another log in", "google", cookie, code, otherState},
+		{"tampered state cookie", "google", cookie[:len(cookie)-2] + "xx", code, state},
+		{"state cookie of another provider", "github", cookie, code, state},
+		// the state matches its cookie but the code was issued for the challenge of the first log in
+		{"verifier of another log in", "google", otherCookie, code, otherState},
+	}
+	for _, test := range refused {
+		if status := callback(test.provider, test.stateCookie, test.code, test.state); status != fiber.StatusBadRequest {
+			t.Errorf("%s answered %d, want 400", test.name, status)
+		}
+	}
+	if _, err := userStore.FindUserByIdentity("google", "42"); err != store.ErrUserNotFound {
+		t.Fatalf("a refused callback created the user: %v", err)
+	}
+
+	// the provider only hands out each code once, the ones above were used up so start again
+	cookie, code, state = start()
+	if status := callback("google", cookie, code, state); status != fiber.StatusAccepted {
+		t.Fatalf("the callback answered %d, want 202", status)
+	}
+	user, err := userStore.FindUserByIdentity("google", "42")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.Email != "user@example.com" || !user.Verified || user.Password != "" || len(user.Identities) != 1 {
+		t.Errorf("created %+v", user)
+	}
+	if sessions, err := sessionStore.ListUserSessions(user.ID); err != nil || len(sessions) != 1 {
+		t.Errorf("the log in left %d sessions, %v", len(sessions), err)
+	}
+
+	// a replayed callback fails at the provider, the code was already exchanged
+	if status := callback("google", cookie, code, state); status != fiber.StatusBadRequest {
+		t.Errorf("a replayed callback answered %d, want 400", status)
+	}
+}
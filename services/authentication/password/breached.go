@@ -0,0 +1,99 @@
+package password
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// BreachedList tells if a password is part of a known breach without any network call. It reads either
+//   - one file of "SHA1[:count]" lines, which is loaded into memory, or
+//   - a directory of k-anonymity range files as the pwned passwords downloader writes them, one file per
+//     5 character sha1 prefix (00000.txt, 00001.txt...) holding "SUFFIX[:count]" lines, only the range
+//     of the password is read on every check
+type BreachedList struct {
+	hashes map[[sha1.Size]byte]struct{}
+	dir    string
+}
+
+func LoadBreachedList(path string) (*BreachedList, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		return &BreachedList{dir: path}, nil
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	list := &BreachedList{hashes: map[[sha1.Size]byte]struct{}{}}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := breachedLineHash(scanner.Text())
+		var sum [sha1.Size]byte
+		if n, err := hex.Decode(sum[:], []byte(line)); err != nil || n != sha1.Size {
+			continue
+		}
+		list.hashes[sum] = struct{}{}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(list.hashes) == 0 {
+		return nil, errors.New(path + " has no sha1 hashes")
+	}
+
+	return list, nil
+}
+
+// Contains reports if the password is in the list, a nil list contains nothing
+func (b *BreachedList) Contains(plain string) (bool, error) {
+	if b == nil {
+		return false, nil
+	}
+
+	sum := sha1.Sum([]byte(plain))
+	if b.hashes != nil {
+		_, found := b.hashes[sum]
+		return found, nil
+	}
+
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+	prefix, suffix := hash[:5], hash[5:]
+
+	file, err := os.Open(filepath.Join(b.dir, prefix+".txt"))
+	if errors.Is(err, fs.ErrNotExist) {
+		file, err = os.Open(filepath.Join(b.dir, prefix))
+	}
+	if errors.Is(err, fs.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if strings.EqualFold(breachedLineHash(scanner.Text()), suffix) {
+			return true, nil
+		}
+	}
+	return false, scanner.Err()
+}
+
+// breachedLineHash drops the ":count" of a line
+func breachedLineHash(line string) string {
+	hash, _, _ := strings.Cut(strings.TrimSpace(line), ":")
+	return hash
+}
@@ -0,0 +1,79 @@
+package password
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func sha1Hex(plain string) string {
+	sum := sha1.Sum([]byte(plain))
+	return strings.ToUpper(hex.EncodeToString(sum[:]))
+}
+
+func TestBreachedListFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "breached.txt")
+	lines := []string{
+		sha1Hex("password") + ":3861493",
+		strings.ToLower(sha1Hex("letmein")),
+		"  " + sha1Hex("qwerty") + ":1  ",
+		"not a hash",
+		"",
+	}
+	if err := os.WriteFile(path, []byte(strings.Join(lines, "\n")), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	list, err := LoadBreachedList(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for plain, want := range map[string]bool{"password": true, "letmein": true, "qwerty": true, "Password": false, "a strong passphrase": false} {
+		if found, err := list.Contains(plain); err != nil || found != want {
+			t.Errorf("Contains(%q) = %v, %v, want %v", plain, found, err, want)
+		}
+	}
+
+	empty := filepath.Join(t.TempDir(), "empty.txt")
+	if err := os.WriteFile(empty, []byte("not a hash\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadBreachedList(empty); err == nil {
+		t.Error("a file without hashes was loaded")
+	}
+	if _, err := LoadBreachedList(filepath.Join(t.TempDir(), "missing.txt")); err == nil {
+		t.Error("a missing file was loaded")
+	}
+}
+
+func TestBreachedListRanges(t *testing.T) {
+	dir := t.TempDir()
+	password, letmein := sha1Hex("password"), sha1Hex("letmein")
+	// the downloader writes PREFIX.txt, older dumps have no extension
+	if err := os.WriteFile(filepath.Join(dir, password[:5]+".txt"), []byte("0018A45C4D1DEF81644B54AB7F969B88D65:1\r\n"+password[5:]+":3861493\r\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, letmein[:5]), []byte(strings.ToLower(letmein[5:])+"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	list, err := LoadBreachedList(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for plain, want := range map[string]bool{"password": true, "letmein": true, "a strong passphrase": false} {
+		if found, err := list.Contains(plain); err != nil || found != want {
+			t.Errorf("Contains(%q) = %v, %v, want %v", plain, found, err, want)
+		}
+	}
+}
+
+func TestNilBreachedListContainsNothing(t *testing.T) {
+	var list *BreachedList
+	if found, err := list.Contains("password"); err != nil || found {
+		t.Errorf("a nil list returned %v, %v", found, err)
+	}
+}
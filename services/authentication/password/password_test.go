@@ -0,0 +1,152 @@
+package password
+
+import (
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+var (
+	fastArgon2id = Argon2id{Memory: 1024, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32}
+	fastBcrypt   = Bcrypt{Cost: bcrypt.MinCost}
+)
+
+func TestArgon2idEncodesPHC(t *testing.T) {
+	hash, err := fastArgon2id.Hash("the password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(hash, "$argon2id$v=19$m=1024,t=1,p=1$") || strings.Count(hash, "$") != 5 {
+		t.Fatalf("hash %q is no argon2id phc string", hash)
+	}
+
+	params, salt, key, err := decodeArgon2id(hash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if params != fastArgon2id || len(salt) != 16 || len(key) != 32 {
+		t.Errorf("decoded %+v with a %d byte salt and a %d byte key", params, len(salt), len(key))
+	}
+
+	other, err := fastArgon2id.Hash("the password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if other == hash {
+		t.Error("two hashes of the same password share their salt")
+	}
+}
+
+func TestBcryptKeepsItsFormat(t *testing.T) {
+	hash, err := fastBcrypt.Hash("the password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !fastBcrypt.Owns(hash) || (Argon2id{}).Owns(hash) {
+		t.Errorf("hash %q is not owned by bcrypt only", hash)
+	}
+	if cost, err := bcrypt.Cost([]byte(hash)); err != nil || cost != bcrypt.MinCost {
+		t.Errorf("hash %q has cost %d, %v", hash, cost, err)
+	}
+}
+
+func TestVerify(t *testing.T) {
+	argon2idHash, err := fastArgon2id.Hash("the password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	bcryptHash, err := fastBcrypt.Hash("the password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	stronger := fastArgon2id
+	stronger.Iterations = 2
+
+	tests := []struct {
+		name      string
+		preferred Hasher
+		password  string
+		hash      string
+		ok        bool
+		rehash    bool
+		err       bool
+	}{
+		{"argon2id", fastArgon2id, "the password", argon2idHash, true, false, false},
+		{"argon2id wrong password", fastArgon2id, "guessed", argon2idHash, false, false, false},
+		{"bcrypt", fastBcrypt, "the password", bcryptHash, true, false, false},
+		{"bcrypt wrong password", fastBcrypt, "guessed", bcryptHash, false, false, false},
+		{"argon2id parameters raised", stronger, "the password", argon2idHash, true, true, false},
+		{"bcrypt cost raised", Bcrypt{Cost: bcrypt.MinCost + 1}, "the password", bcryptHash, true, true, false},
+		{"bcrypt to argon2id", fastArgon2id, "the password", bcryptHash, true, true, false},
+		{"argon2id to bcrypt", fastBcrypt, "the password", argon2idHash, true, true, false},
+		{"no rehash of a wrong password", stronger, "guessed", argon2idHash, false, false, false},
+		{"no password", fastBcrypt, "the password", "", false, false, true},
+		{"plain text", fastBcrypt, "the password", "the password", false, false, true},
+		{"unknown algorithm", fastArgon2id, "the password", "$scrypt$ln=16,r=8,p=1$c2FsdA$aGFzaA", false, false, true},
+	}
+
+	for _, test := range tests {
+		ok, rehash, err := Verify(test.preferred, test.password, test.hash)
+		if ok != test.ok || rehash != test.rehash || (err != nil) != test.err {
+			t.Errorf("%s: Verify = %v, %v, %v, want %v, %v and an error %v", test.name, ok, rehash, err, test.ok, test.rehash, test.err)
+		}
+	}
+}
+
+func TestMalformedArgon2idHashes(t *testing.T) {
+	hash, err := fastArgon2id.Hash("the password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	parts := strings.Split(hash, "$")
+	with := func(index int, value string) string {
+		changed := append([]string{}, parts...)
+		changed[index] = value
+		return strings.Join(changed, "$")
+	}
+
+	for name, malformed := range map[string]string{
+		"missing key":       strings.Join(parts[:5], "$"),
+		"other version":     with(2, "v=16"),
+		"no version":        with(2, "version"),
+		"no parameters":     with(3, "m=1024"),
+		"salt not base64":   with(4, "not base64!"),
+		"key not base64":    with(5, "not base64!"),
+		"empty key":         with(5, ""),
+		"extra field":       hash + "$extra",
+		"other algorithm":   with(1, "argon2i"),
+		"truncated in half": hash[:len(hash)/2],
+	} {
+		if ok, err := fastArgon2id.Verify("the password", malformed); ok || err == nil {
+			t.Errorf("%s: Verify(%q) = %v, %v, want an error", name, malformed, ok, err)
+		}
+		if !fastArgon2id.Outdated(malformed) {
+			t.Errorf("%s: a malformed hash is not outdated", name)
+		}
+	}
+}
+
+func TestOutdated(t *testing.T) {
+	hash, err := fastArgon2id.Hash("the password")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, change := range map[string]func(*Argon2id){
+		"memory":      func(a *Argon2id) { a.Memory *= 2 },
+		"iterations":  func(a *Argon2id) { a.Iterations++ },
+		"parallelism": func(a *Argon2id) { a.Parallelism++ },
+		"salt length": func(a *Argon2id) { a.SaltLength = 32 },
+		"key length":  func(a *Argon2id) { a.KeyLength = 64 },
+	} {
+		changed := fastArgon2id
+		change(&changed)
+		if !changed.Outdated(hash) {
+			t.Errorf("a hash is not outdated when the %s changed", name)
+		}
+	}
+	if fastArgon2id.Outdated(hash) {
+		t.Error("a hash of the current parameters is outdated")
+	}
+}
@@ -0,0 +1,89 @@
+package password
+
+import (
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Policy is what a new password has to look like, lengths are counted in characters
+type Policy struct {
+	MinLength            int
+	MaxLength            int
+	MaxBytes             int // limit of the hasher in utf-8 bytes, 0 when it takes any length
+	RequireUppercase     bool
+	RequireLowercase     bool
+	RequireDigit         bool
+	RequireSymbol        bool
+	DisallowPersonalInfo bool
+}
+
+// PolicyError lists every rule a password breaks
+type PolicyError struct {
+	Problems []string
+}
+
+func (e *PolicyError) Error() string {
+	return "Password " + strings.Join(e.Problems, ", ")
+}
+
+// Check returns a *PolicyError when the password breaks the policy, personal are the username, email and
+// similar values the password must not contain when DisallowPersonalInfo is set
+func (p Policy) Check(plain string, personal ...string) error {
+	var problems []string
+
+	length := utf8.RuneCountInString(plain)
+	if length < p.MinLength {
+		problems = append(problems, "must be at least "+strconv.Itoa(p.MinLength)+" characters long")
+	}
+	if p.MaxLength > 0 && length > p.MaxLength {
+		problems = append(problems, "must be at most "+strconv.Itoa(p.MaxLength)+" characters long")
+	} else if p.MaxBytes > 0 && len(plain) > p.MaxBytes {
+		problems = append(problems, "must be at most "+strconv.Itoa(p.MaxBytes)+" bytes long, characters outside of ascii take up to 4 bytes")
+	}
+
+	if p.RequireUppercase && !strings.ContainsFunc(plain, unicode.IsUpper) {
+		problems = append(problems, "needs an uppercase letter")
+	}
+	if p.RequireLowercase && !strings.ContainsFunc(plain, unicode.IsLower) {
+		problems = append(problems, "needs a lowercase letter")
+	}
+	if p.RequireDigit && !strings.ContainsFunc(plain, unicode.IsDigit) {
+		problems = append(problems, "needs a digit")
+	}
+	if p.RequireSymbol && !strings.ContainsFunc(plain, isSymbol) {
+		problems = append(problems, "needs a symbol")
+	}
+
+	if p.DisallowPersonalInfo && containsPersonalInfo(plain, personal) {
+		problems = append(problems, "must not contain your username or email")
+	}
+
+	if len(problems) > 0 {
+		return &PolicyError{Problems: problems}
+	}
+	return nil
+}
+
+func isSymbol(r rune) bool {
+	return !unicode.IsLetter(r) && !unicode.IsDigit(r) && !unicode.IsSpace(r)
+}
+
+// containsPersonalInfo also checks the part of an email before the @, values shorter than 3 characters are ignored
+func containsPersonalInfo(plain string, personal []string) bool {
+	lower := strings.ToLower(plain)
+	for _, value := range personal {
+		value = strings.ToLower(strings.TrimSpace(value))
+		candidates := []string{value}
+		if local, _, ok := strings.Cut(value, "@"); ok {
+			candidates = append(candidates, local)
+		}
+		for _, candidate := range candidates {
+			if utf8.RuneCountInString(candidate) >= 3 && strings.Contains(lower, candidate) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,87 @@
+package password
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPolicyCheck(t *testing.T) {
+	strict := Policy{MinLength: 8, MaxLength: 20, RequireUppercase: true, RequireLowercase: true, RequireDigit: true, RequireSymbol: true}
+
+	tests := []struct {
+		name     string
+		policy   Policy
+		password string
+		personal []string
+		problems []string
+	}{
+		{"long enough", Policy{MinLength: 8}, "abcdefgh", nil, nil},
+		{"too short", Policy{MinLength: 8}, "abcdefg", nil, []string{"at least 8"}},
+		{"characters not bytes", Policy{MinLength: 8}, "ääääääää", nil, nil},
+		{"too long", Policy{MaxLength: 10}, "abcdefghijk", nil, []string{"at most 10 characters"}},
+		{"no maximum", Policy{}, strings.Repeat("a", 1000), nil, nil},
+		{"every class", strict, "Abcdef1!", nil, nil},
+		{"no uppercase", strict, "abcdef1!", nil, []string{"uppercase"}},
+		{"no lowercase", strict, "ABCDEF1!", nil, []string{"lowercase"}},
+		{"no digit", strict, "Abcdefg!", nil, []string{"digit"}},
+		{"no symbol", strict, "Abcdefg1", nil, []string{"symbol"}},
+		{"space is no symbol", strict, "Abcdef1 ", nil, []string{"symbol"}},
+		{"unicode letters", strict, "Ábcdéf1!", nil, nil},
+		{"every problem", strict, "", nil, []string{"at least 8", "uppercase", "lowercase", "digit", "symbol"}},
+		{"username", Policy{DisallowPersonalInfo: true}, "xxFroggyxx", []string{"froggy", "someone@example.com"}, []string{"username or email"}},
+		{"email", Policy{DisallowPersonalInfo: true}, "someone@example.com1", []string{"froggy", "someone@example.com"}, []string{"username or email"}},
+		{"local part of the email", Policy{DisallowPersonalInfo: true}, "my-SOMEONE-pass", []string{"froggy", "someone@example.com"}, []string{"username or email"}},
+		{"short values are ignored", Policy{DisallowPersonalInfo: true}, "abcdefgh", []string{"ab", "ab@example.com"}, nil},
+		{"personal info allowed", Policy{}, "xxfroggyxx", []string{"froggy"}, nil},
+	}
+
+	for _, test := range tests {
+		err := test.policy.Check(test.password, test.personal...)
+		if test.problems == nil {
+			if err != nil {
+				t.Errorf("%s: Check = %v, want no error", test.name, err)
+			}
+			continue
+		}
+		policyErr, ok := err.(*PolicyError)
+		if !ok {
+			t.Errorf("%s: Check = %v, want a *PolicyError", test.name, err)
+			continue
+		}
+		if len(policyErr.Problems) != len(test.problems) {
+			t.Errorf("%s: got the problems %q, want %q", test.name, policyErr.Problems, test.problems)
+			continue
+		}
+		for i, problem := range test.problems {
+			if !strings.Contains(policyErr.Problems[i], problem) {
+				t.Errorf("%s: problem %q doesn't mention %q", test.name, policyErr.Problems[i], problem)
+			}
+		}
+	}
+}
+
+func TestPolicyMaxBytes(t *testing.T) {
+	policy := Policy{MaxLength: 72, MaxBytes: BcryptMaxBytes}
+
+	if err := policy.Check(strings.Repeat("a", 72)); err != nil {
+		t.Errorf("72 ascii characters were refused: %v", err)
+	}
+	// 40 characters pass the length but take 80 bytes
+	err := policy.Check(strings.Repeat("ä", 40))
+	if policyErr, ok := err.(*PolicyError); !ok || len(policyErr.Problems) != 1 || !strings.Contains(policyErr.Problems[0], "72 bytes") {
+		t.Errorf("80 bytes of umlauts returned %v, want a bytes problem", err)
+	}
+	if err := (Policy{MaxLength: 10, MaxBytes: BcryptMaxBytes}).Check(strings.Repeat("ä", 40)); err == nil || strings.Contains(err.Error(), "bytes") {
+		t.Errorf("a password over both limits returned %v, want only the character limit", err)
+	}
+}
+
+func TestBcryptRefusesLongPasswords(t *testing.T) {
+	if _, err := fastBcrypt.Hash(strings.Repeat("a", BcryptMaxBytes)); err != nil {
+		t.Errorf("a %d byte password was refused: %v", BcryptMaxBytes, err)
+	}
+	// bcrypt would otherwise ignore everything after the 72nd byte
+	if _, err := fastBcrypt.Hash(strings.Repeat("a", BcryptMaxBytes+1)); err == nil {
+		t.Errorf("a %d byte password was hashed", BcryptMaxBytes+1)
+	}
+}
@@ -0,0 +1,93 @@
+package authentication
+
+import (
+	"sync"
+
+	"github.com/froggy-12/purpurbase/config"
+	"github.com/froggy-12/purpurbase/services/authentication/password"
+	"github.com/froggy-12/purpurbase/store"
+	"github.com/froggy-12/purpurbase/types"
+	"github.com/froggy-12/purpurbase/utils"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// passwordHasher is the hasher new passwords are hashed with, picked by the passwordHashing configuration
+func passwordHasher() password.Hasher {
+	hashing := config.Configs.AuthenticationConfigurations.PasswordHashing
+
+	if hashing.Algorithm == "argon2id" {
+		hasher := password.Argon2id{
+			Memory:      hashing.Memory,
+			Iterations:  hashing.Iterations,
+			Parallelism: hashing.Parallelism,
+			SaltLength:  16,
+			KeyLength:   32,
+		}
+		if hasher.Memory == 0 {
+			hasher.Memory = 64 * 1024
+		}
+		if hasher.Iterations == 0 {
+			hasher.Iterations = 3
+		}
+		if hasher.Parallelism == 0 {
+			hasher.Parallelism = 2
+		}
+		return hasher
+	}
+
+	cost := config.Configs.PurpurbaseConfigurations.PurpurbasePasswordEncryptionRate
+	if cost < bcrypt.MinCost || cost > bcrypt.MaxCost {
+		cost = bcrypt.DefaultCost
+	}
+	return password.Bcrypt{Cost: cost}
+}
+
+func hashPassword(plain string) (string, error) {
+	return passwordHasher().Hash(plain)
+}
+
+// checkPassword reports if the password matches the user's hash, accounts without a password never match
+func checkPassword(user types.UserRecord, plain string) bool {
+	ok, _, _ := password.Verify(passwordHasher(), plain, user.Password)
+	return ok
+}
+
+// checkPasswordAndUpgrade is checkPassword for log ins, a matching hash made with an older algorithm or
+// older parameters is replaced with a fresh one so costs can be raised without resetting passwords
+func checkPasswordAndUpgrade(userStore store.UserStore, user *types.UserRecord, plain string) bool {
+	hasher := passwordHasher()
+	ok, rehash, _ := password.Verify(hasher, plain, user.Password)
+	if !ok || !rehash {
+		return ok
+	}
+
+	hash, err := hasher.Hash(plain)
+	if err != nil {
+		utils.DebugLogger("password-hashing", "failed to rehash the password of "+user.ID+": "+err.Error())
+		return true
+	}
+
+	upgraded := *user
+	upgraded.Password = hash
+	if err := userStore.UpdateUser(upgraded); err != nil {
+		utils.DebugLogger("password-hashing", "failed to store the rehashed password of "+user.ID+": "+err.Error())
+		return true
+	}
+	*user = upgraded
+
+	return true
+}
+
+var (
+	dummyPasswordHash     string
+	dummyPasswordHashOnce sync.Once
+)
+
+// compareDummyPassword takes as long as a real password check so unknown emails can't be told apart by timing
+func compareDummyPassword(plain string) {
+	hasher := passwordHasher()
+	dummyPasswordHashOnce.Do(func() {
+		dummyPasswordHash, _ = hasher.Hash("purpurbase")
+	})
+	password.Verify(hasher, plain, dummyPasswordHash)
+}
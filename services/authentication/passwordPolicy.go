@@ -0,0 +1,101 @@
+package authentication
+
+import (
+	"errors"
+
+	"github.com/froggy-12/purpurbase/config"
+	"github.com/froggy-12/purpurbase/services/authentication/password"
+	"github.com/froggy-12/purpurbase/types"
+	"github.com/gofiber/fiber/v2"
+)
+
+// BreachedPasswords is loaded on start up from passwordPolicy.breachedPasswordsFile, nil skips the check
+var BreachedPasswords *password.BreachedList
+
+var (
+	ErrPasswordBreached = errors.New("This password has appeared in a data breach please choose another one")
+	ErrPasswordReused   = errors.New("This password has been used recently please choose another one")
+)
+
+func passwordPolicy() password.Policy {
+	policy := config.Configs.AuthenticationConfigurations.PasswordPolicy
+	if policy.MinLength == 0 {
+		policy.MinLength = 8
+	}
+	if policy.MaxLength == 0 {
+		policy.MaxLength = 72
+	}
+
+	// bcrypt counts bytes, a long password of non ascii characters would pass the policy and fail the hashing
+	var maxBytes int
+	if _, ok := passwordHasher().(password.Bcrypt); ok {
+		maxBytes = password.BcryptMaxBytes
+	}
+
+	return password.Policy{
+		MinLength:            policy.MinLength,
+		MaxLength:            policy.MaxLength,
+		MaxBytes:             maxBytes,
+		RequireUppercase:     policy.RequireUppercase,
+		RequireLowercase:     policy.RequireLowercase,
+		RequireDigit:         policy.RequireDigit,
+		RequireSymbol:        policy.RequireSymbol,
+		DisallowPersonalInfo: policy.DisallowPersonalInfo,
+	}
+}
+
+// checkNewPassword runs the policy, the breached password list and the password history of the user
+func checkNewPassword(user types.UserRecord, plain string) error {
+	if err := passwordPolicy().Check(plain, user.UserName, user.Email); err != nil {
+		return err
+	}
+
+	breached, err := BreachedPasswords.Contains(plain)
+	if err != nil {
+		return err
+	}
+	if breached {
+		return ErrPasswordBreached
+	}
+
+	if config.Configs.AuthenticationConfigurations.PasswordPolicy.History > 0 {
+		hasher := passwordHasher()
+		for _, hash := range append([]string{user.Password}, user.PasswordHistory...) {
+			if used, _, _ := password.Verify(hasher, plain, hash); used {
+				return ErrPasswordReused
+			}
+		}
+	}
+
+	return nil
+}
+
+// setPassword checks the new password and hashes it into the user, the old hash moves into the history
+func setPassword(user *types.UserRecord, plain string) error {
+	if err := checkNewPassword(*user, plain); err != nil {
+		return err
+	}
+
+	hash, err := hashPassword(plain)
+	if err != nil {
+		return err
+	}
+
+	history := config.Configs.AuthenticationConfigurations.PasswordPolicy.History
+	if history > 0 && user.Password != "" {
+		user.PasswordHistory = append([]string{user.Password}, user.PasswordHistory...)
+	}
+	user.PasswordHistory = user.PasswordHistory[:min(len(user.PasswordHistory), max(history, 0))]
+	user.Password = hash
+
+	return nil
+}
+
+// passwordRejected answers a failed setPassword, broken rules are the client's fault and everything else is ours
+func passwordRejected(c *fiber.Ctx, err error) error {
+	var policyErr *password.PolicyError
+	if errors.As(err, &policyErr) || err == ErrPasswordBreached || err == ErrPasswordReused {
+		return c.Status(fiber.StatusBadRequest).JSON(types.ErrorResponse{Error: err.Error()})
+	}
+	return c.Status(fiber.StatusInternalServerError).JSON(types.ErrorResponse{Error: "failed to generate new password: " + err.Error()})
+}
@@ -0,0 +1,122 @@
+package authentication
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/froggy-12/purpurbase/config"
+	"github.com/froggy-12/purpurbase/services/authentication/password"
+	"github.com/froggy-12/purpurbase/types"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// usePasswordConfig sets the policy and a fast hasher for the test
+func usePasswordConfig(t *testing.T, policy config.PasswordPolicy, hashing config.PasswordHashing) {
+	t.Helper()
+	previousPolicy := config.Configs.AuthenticationConfigurations.PasswordPolicy
+	previousHashing := config.Configs.AuthenticationConfigurations.PasswordHashing
+	previousCost := config.Configs.PurpurbaseConfigurations.PurpurbasePasswordEncryptionRate
+	config.Configs.AuthenticationConfigurations.PasswordPolicy = policy
+	config.Configs.AuthenticationConfigurations.PasswordHashing = hashing
+	config.Configs.PurpurbaseConfigurations.PurpurbasePasswordEncryptionRate = bcrypt.MinCost
+	t.Cleanup(func() {
+		config.Configs.AuthenticationConfigurations.PasswordPolicy = previousPolicy
+		config.Configs.AuthenticationConfigurations.PasswordHashing = previousHashing
+		config.Configs.PurpurbaseConfigurations.PurpurbasePasswordEncryptionRate = previousCost
+	})
+}
+
+func TestSetPasswordKeepsTheHistory(t *testing.T) {
+	usePasswordConfig(t, config.PasswordPolicy{History: 2}, config.PasswordHashing{})
+	user := types.UserRecord{UserName: "user", Email: "user@example.com"}
+
+	for _, plain := range []string{"first password", "second password", "third password"} {
+		if err := setPassword(&user, plain); err != nil {
+			t.Fatalf("setting %q: %v", plain, err)
+		}
+	}
+	if len(user.PasswordHistory) != 2 {
+		t.Fatalf("kept %d previous hashes, want 2", len(user.PasswordHistory))
+	}
+
+	for plain, want := range map[string]error{
+		"third password":  ErrPasswordReused, // the current one
+		"second password": ErrPasswordReused,
+		"first password":  ErrPasswordReused,
+		"fourth password": nil,
+	} {
+		if err := checkNewPassword(user, plain); err != want {
+			t.Errorf("checkNewPassword(%q) = %v, want %v", plain, err, want)
+		}
+	}
+
+	// the oldest hash falls out of the history
+	if err := setPassword(&user, "fourth password"); err != nil {
+		t.Fatal(err)
+	}
+	if err := checkNewPassword(user, "first password"); err != nil {
+		t.Errorf("a password older than the history was refused: %v", err)
+	}
+
+	config.Configs.AuthenticationConfigurations.PasswordPolicy.History = 0
+	if err := setPassword(&user, "fourth password"); err != nil {
+		t.Errorf("without a history the current password was refused: %v", err)
+	}
+	if len(user.PasswordHistory) != 0 {
+		t.Errorf("kept %d previous hashes without a history", len(user.PasswordHistory))
+	}
+}
+
+func TestCheckNewPassword(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "breached.txt")
+	if err := os.WriteFile(path, []byte("5BAA61E4C9B93F3F0682250B6CF8331B7EE68FD8:3861493\n"), 0o644); err != nil { // sha1 of "password"
+		t.Fatal(err)
+	}
+	breached, err := password.LoadBreachedList(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	BreachedPasswords = breached
+	t.Cleanup(func() { BreachedPasswords = nil })
+
+	user := types.UserRecord{UserName: "froggy", Email: "froggy@example.com"}
+	tests := []struct {
+		name     string
+		policy   config.PasswordPolicy
+		hashing  config.PasswordHashing
+		password string
+		policyOK bool
+		want     error
+	}{
+		{"default minimum", config.PasswordPolicy{}, config.PasswordHashing{}, "short", false, nil},
+		{"breached", config.PasswordPolicy{}, config.PasswordHashing{}, "password", true, ErrPasswordBreached},
+		{"username", config.PasswordPolicy{DisallowPersonalInfo: true}, config.PasswordHashing{}, "i am froggy", false, nil},
+		{"72 bytes with bcrypt", config.PasswordPolicy{}, config.PasswordHashing{}, strings.Repeat("ä", 36), true, nil},
+		{"73 bytes with bcrypt", config.PasswordPolicy{}, config.PasswordHashing{}, strings.Repeat("ä", 36) + "a", false, nil},
+		{"73 bytes with argon2id", config.PasswordPolicy{}, config.PasswordHashing{Algorithm: "argon2id", Memory: 1024, Iterations: 1, Parallelism: 1}, strings.Repeat("ä", 36) + "a", true, nil},
+		{"73 characters", config.PasswordPolicy{}, config.PasswordHashing{Algorithm: "argon2id"}, strings.Repeat("a", 73), false, nil},
+	}
+
+	for _, test := range tests {
+		usePasswordConfig(t, test.policy, test.hashing)
+		err := checkNewPassword(user, test.password)
+		var policyErr *password.PolicyError
+		if !test.policyOK {
+			if !errors.As(err, &policyErr) {
+				t.Errorf("%s: checkNewPassword = %v, want a policy error", test.name, err)
+			}
+			continue
+		}
+		if err != test.want {
+			t.Errorf("%s: checkNewPassword = %v, want %v", test.name, err, test.want)
+		}
+		if err == nil {
+			if _, err := hashPassword(test.password); err != nil {
+				t.Errorf("%s: a password the policy accepts failed to hash: %v", test.name, err)
+			}
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package authentication
+
+import (
+	"time"
+
+	"github.com/froggy-12/purpurbase/config"
+	"github.com/froggy-12/purpurbase/services/smtpconfigs"
+	"github.com/froggy-12/purpurbase/store"
+	"github.com/froggy-12/purpurbase/types"
+	"github.com/froggy-12/purpurbase/utils"
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+)
+
+func passwordResetTokenAge() time.Duration {
+	minutes := config.Configs.AuthenticationConfigurations.PasswordResetTokenAge
+	if minutes <= 0 {
+		minutes = 30
+	}
+	return time.Duration(minutes) * time.Minute
+}
+
+// sendPasswordReset stores a new reset token on the user and emails it
+func sendPasswordReset(userStore store.UserStore, user types.UserRecord) error {
+	token, err := generateToken()
+	if err != nil {
+		return err
+	}
+
+	user.PasswordResetToken = hashToken(token)
+	user.PasswordResetExpiresAt = time.Now().Add(passwordResetTokenAge())
+	if err := userStore.UpdateUser(user); err != nil {
+		return err
+	}
+
+	return smtpconfigs.SendPasswordResetEmail(user.Email, token, passwordResetTokenAge())
+}
+
+func RequestPasswordReset(c *fiber.Ctx, userStore store.UserStore, validator validator.Validate) error {
+	if !config.Configs.SMTPConfigurations.SMTPEnabled {
+		return c.Status(fiber.StatusBadRequest).JSON(types.ErrorResponse{Error: "SMTP is not configured or turned off please check again and restart the app"})
+	}
+
+	var body struct {
+		Email string `json:"email" validate:"required,email"`
+	}
+
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(types.ErrorResponse{Error: "Invalid Request body"})
+	}
+
+	if err := validator.Struct(&body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(types.ErrorResponse{Error: err.Error()})
+	}
+
+	// the response is the same whether the account exists or not so emails cant be enumerated
+	sent := types.HTTPSuccessResponse{Message: "If the email belongs to an account a password reset token has been sent"}
+
+	user, err := userStore.FindUserByEmail(body.Email)
+	if err == store.ErrUserNotFound {
+		return c.Status(fiber.StatusAccepted).JSON(sent)
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(types.ErrorResponse{Error: "Something went wrong: " + err.Error()})
+	}
+
+	if err := sendPasswordReset(userStore, user); err != nil {
+		utils.DebugLogger("password-reset", "failed to send reset email to "+user.Email+": "+err.Error())
+	}
+
+	return c.Status(fiber.StatusAccepted).JSON(sent)
+}
+
+func ResetPassword(c *fiber.Ctx, userStore store.UserStore, sessionStore store.SessionStore, validator validator.Validate) error {
+	var body struct {
+		Email       string `json:"email" validate:"required,email"`
+		Token       string `json:"token" validate:"required"`
+		NewPassword string `json:"newPassword" validate:"required"`
+	}
+
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(types.ErrorResponse{Error: "Invalid Request body"})
+	}
+
+	if err := validator.Struct(&body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(types.ErrorResponse{Error: err.Error()})
+	}
+
+	user, err := userStore.FindUserByEmail(body.Email)
+	if err != nil && err != store.ErrUserNotFound {
+		return c.Status(fiber.StatusInternalServerError).JSON(types.ErrorResponse{Error: "Something went wrong: " + err.Error()})
+	}
+
+	if err == store.ErrUserNotFound || !tokenMatches(body.Token, user.PasswordResetToken) || time.Now().After(user.PasswordResetExpiresAt) {
+		return c.Status(fiber.StatusBadRequest).JSON(types.ErrorResponse{Error: "Invalid or expired reset token"})
+	}
+
+	if err := setPassword(&user, body.NewPassword); err != nil {
+		return passwordRejected(c, err)
+	}
+
+	user.PasswordResetToken = ""
+	user.PasswordResetExpiresAt = time.Time{}
+
+	if err := userStore.UpdateUser(user); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(types.ErrorResponse{Error: "Something went wrong failed to update password: " + err.Error()})
+	}
+	auditRequest(c, AuditPasswordChanged, user, true, map[string]string{"via": "reset"})
+
+	if err := sessionStore.DeleteUserSessions(user.ID); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(types.ErrorResponse{Error: "Password has been reset but failed to revoke sessions: " + err.Error()})
+	}
+
+	utils.ClearJwtHttpCookies(c)
+	return c.Status(fiber.StatusAccepted).JSON(types.HTTPSuccessResponse{Message: "Password has been reset please log in again"})
+}
@@ -0,0 +1,125 @@
+package authentication
+
+import (
+	"errors"
+	"slices"
+	"strings"
+
+	"github.com/froggy-12/purpurbase/config"
+	"github.com/froggy-12/purpurbase/store"
+	"github.com/froggy-12/purpurbase/utils"
+	"github.com/gofiber/fiber/v2"
+)
+
+// Principal is whoever makes the request, a logged in user or a server api key
+type Principal struct {
+	UserID      string
+	SessionID   string
+	APIKeyID    string // only set for server api keys, UserID and SessionID are empty then
+	Roles       []string
+	Permissions []string
+}
+
+func (p Principal) IsService() bool {
+	return p.APIKeyID != ""
+}
+
+// IsAdmin reports if the principal is a user with the admin role or an api key with the admin scope
+func (p Principal) IsAdmin() bool {
+	if p.IsService() {
+		return slices.Contains(p.Permissions, "admin") || slices.Contains(p.Permissions, "*")
+	}
+	return slices.Contains(p.Roles, "admin")
+}
+
+var (
+	ErrNotAuthenticated   = errors.New("User is not authorised please log in")
+	ErrAccessTokenExpired = errors.New("Access token has expired please refresh it")
+	ErrSessionRevoked     = errors.New("Session has been revoked please log in again")
+)
+
+// bearerToken returns the value of "Authorization: Bearer <token>"
+func bearerToken(c *fiber.Ctx) string {
+	scheme, value, _ := strings.Cut(c.Get(fiber.HeaderAuthorization), " ")
+	if !strings.EqualFold(scheme, "Bearer") && !strings.EqualFold(scheme, "ApiKey") {
+		return ""
+	}
+	return strings.TrimSpace(value)
+}
+
+// requestAPIKey reads the key from X-API-Key or from an Authorization header holding a pbk_ key
+func requestAPIKey(c *fiber.Ctx) string {
+	if apiKey := c.Get("X-API-Key"); apiKey != "" {
+		return apiKey
+	}
+	if token := bearerToken(c); strings.HasPrefix(token, APIKeyPrefix) {
+		return token
+	}
+	return ""
+}
+
+// requestAccessToken prefers "Authorization: Bearer" over the jwtToken cookie
+func requestAccessToken(c *fiber.Ctx) string {
+	if token := bearerToken(c); token != "" {
+		return token
+	}
+	return c.Cookies("jwtToken")
+}
+
+// ReadPrincipal finds out who makes the request from a server api key, a bearer access token or the jwtToken cookie.
+// Without a sessionStore the session of the access token isn't checked, without an apiKeyStore api keys are turned away
+func ReadPrincipal(c *fiber.Ctx, sessionStore store.SessionStore, apiKeyStore store.APIKeyStore) (Principal, error) {
+	if apiKey := requestAPIKey(c); apiKey != "" {
+		if apiKeyStore == nil {
+			return Principal{}, ErrInvalidAPIKey
+		}
+		key, err := AuthenticateAPIKey(apiKeyStore, apiKey)
+		if err != nil {
+			return Principal{}, err
+		}
+		return Principal{APIKeyID: key.ID, Roles: []string{ServiceRole}, Permissions: key.Scopes}, nil
+	}
+
+	token := requestAccessToken(c)
+	if token == "" {
+		return Principal{}, ErrNotAuthenticated
+	}
+
+	claims, err := utils.ReadJWTClaims(token, config.Configs.PurpurbaseConfigurations.PurpurbaseJWTTokenSecret)
+	if err != nil {
+		return Principal{}, ErrNotAuthenticated
+	}
+	if claims.Expired() {
+		return Principal{}, ErrAccessTokenExpired
+	}
+
+	if sessionStore != nil {
+		session, err := sessionStore.FindSession(claims.SessionID)
+		if err != nil || session.UserID != claims.UserID {
+			return Principal{}, ErrSessionRevoked
+		}
+	}
+
+	return Principal{
+		UserID:      claims.UserID,
+		SessionID:   claims.SessionID,
+		Roles:       claims.Roles,
+		Permissions: claims.Permissions,
+	}, nil
+}
+
+// SetPrincipal keeps the principal on the request, handlers read it with CurrentPrincipal or the userId, sessionId,
+// apiKeyId, roles and permissions locals
+func SetPrincipal(c *fiber.Ctx, p Principal) {
+	c.Locals("principal", p)
+	c.Locals("userId", p.UserID)
+	c.Locals("sessionId", p.SessionID)
+	c.Locals("apiKeyId", p.APIKeyID)
+	c.Locals("roles", p.Roles)
+	c.Locals("permissions", p.Permissions)
+}
+
+func CurrentPrincipal(c *fiber.Ctx) (Principal, bool) {
+	p, ok := c.Locals("principal").(Principal)
+	return p, ok
+}
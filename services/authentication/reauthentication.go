@@ -0,0 +1,41 @@
+package authentication
+
+import (
+	"time"
+
+	"github.com/froggy-12/purpurbase/store"
+	"github.com/froggy-12/purpurbase/types"
+	"github.com/gofiber/fiber/v2"
+)
+
+// freshLogInAge is how long after logging in an account without a password can still change sensitive settings
+const freshLogInAge = 10 * time.Minute
+
+// confirmIdentity is the check in front of sensitive changes. Accounts with a password confirm it, accounts that
+// log in with oauth or magic links have nothing to type so their session has to come from a log in of the last minutes
+func confirmIdentity(c *fiber.Ctx, sessionStore store.SessionStore, user types.UserRecord, password string) (bool, error) {
+	if user.Password != "" {
+		return checkPassword(user, password), nil
+	}
+
+	sessionID, _ := c.Locals("sessionId").(string)
+	if sessionID == "" {
+		return false, nil
+	}
+	session, err := sessionStore.FindSession(sessionID)
+	if err == store.ErrSessionNotFound {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return session.UserID == user.ID && time.Since(session.CreatedAt) < freshLogInAge, nil
+}
+
+// identityNotConfirmed answers a failed confirmIdentity, message is the answer for a wrong password
+func identityNotConfirmed(c *fiber.Ctx, user types.UserRecord, message string) error {
+	if user.Password != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(types.ErrorResponse{Error: message})
+	}
+	return c.Status(fiber.StatusUnauthorized).JSON(types.ErrorResponse{Error: "Log in again to confirm it is you, this account has no password"})
+}
@@ -0,0 +1,49 @@
+package authentication
+
+import (
+	"slices"
+
+	"github.com/froggy-12/purpurbase/config"
+	"github.com/froggy-12/purpurbase/types"
+	"github.com/froggy-12/purpurbase/utils"
+)
+
+func defaultRoles() []string {
+	if config.Configs.AuthenticationConfigurations.DefaultRoles == nil {
+		return []string{"user"}
+	}
+	return slices.Clone(config.Configs.AuthenticationConfigurations.DefaultRoles)
+}
+
+// userRoles returns the roles that go into the access token, verified users listed in adminEmails are always admins
+func userRoles(user types.UserRecord) []string {
+	roles := slices.Clone(user.Roles)
+	if len(roles) == 0 {
+		roles = defaultRoles()
+	}
+
+	if user.Verified && slices.Contains(config.Configs.AuthenticationConfigurations.AdminEmails, user.Email) && !slices.Contains(roles, "admin") {
+		roles = append(roles, "admin")
+	}
+	return roles
+}
+
+// userPermissions merges the permissions of the roles with the ones granted to the user directly
+func userPermissions(user types.UserRecord, roles []string) []string {
+	permissions := slices.Clone(user.Permissions)
+	for _, role := range roles {
+		permissions = append(permissions, config.Configs.AuthenticationConfigurations.Roles[role]...)
+	}
+	slices.Sort(permissions)
+	return slices.Compact(permissions)
+}
+
+func accessTokenClaims(user types.UserRecord, sessionID string) utils.JWTClaims {
+	roles := userRoles(user)
+	return utils.JWTClaims{
+		UserID:      user.ID,
+		SessionID:   sessionID,
+		Roles:       roles,
+		Permissions: userPermissions(user, roles),
+	}
+}
@@ -0,0 +1,293 @@
+package authentication
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/froggy-12/purpurbase/config"
+	"github.com/froggy-12/purpurbase/store"
+	"github.com/froggy-12/purpurbase/types"
+	"github.com/froggy-12/purpurbase/utils"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+var errUserDisabled = errors.New("This account has been disabled")
+
+func accessTokenAge() time.Duration {
+	minutes := config.Configs.AuthenticationConfigurations.AccessTokenAge
+	if minutes <= 0 {
+		minutes = 15
+	}
+	return time.Duration(minutes) * time.Minute
+}
+
+func refreshTokenAge() time.Duration {
+	return time.Hour * 24 * time.Duration(config.Configs.PurpurbaseConfigurations.PurpurbaseCookieAndCoresAge)
+}
+
+// sessionTokens are the tokens handed out by a log in or a refresh
+type sessionTokens struct {
+	AccessToken  string
+	RefreshToken string
+}
+
+// issueTokens sets both cookies, the refresh token is "<session id>.<secret>" so it can be looked up without a scan
+func issueTokens(c *fiber.Ctx, user types.UserRecord, sessionID, secret string) (sessionTokens, error) {
+	accessToken, err := utils.GenerateJWTToken(accessTokenClaims(user, sessionID), accessTokenAge(), config.Configs.PurpurbaseConfigurations.PurpurbaseJWTTokenSecret)
+	if err != nil {
+		return sessionTokens{}, errors.New("Failed to generate JWT token")
+	}
+
+	tokens := sessionTokens{AccessToken: accessToken, RefreshToken: sessionID + "." + secret}
+	utils.SetJwtHttpCookies(c, tokens.AccessToken, accessTokenAge(), tokens.RefreshToken, refreshTokenAge())
+	return tokens, nil
+}
+
+// tokensInBody reports if the client asked for the tokens in the response, only allowed when tokensInBody is on
+func tokensInBody(c *fiber.Ctx) bool {
+	return config.Configs.AuthenticationConfigurations.TokensInBody && c.Query("tokens") == "body"
+}
+
+// withTokens adds the tokens to the response data when the client asked for them with ?tokens=body
+func withTokens(c *fiber.Ctx, tokens sessionTokens, data map[string]any) map[string]any {
+	if !tokensInBody(c) {
+		return data
+	}
+	if data == nil {
+		data = map[string]any{}
+	}
+	data["accessToken"] = tokens.AccessToken
+	data["refreshToken"] = tokens.RefreshToken
+	data["tokenType"] = "Bearer"
+	data["expiresIn"] = int(accessTokenAge().Seconds())
+	return data
+}
+
+// startSession creates a new session for the device, sets the token cookies and records the log in
+func startSession(c *fiber.Ctx, userStore store.UserStore, sessionStore store.SessionStore, user types.UserRecord) (sessionTokens, error) {
+	if user.Disabled {
+		return sessionTokens{}, errUserDisabled
+	}
+
+	secret, err := generateToken()
+	if err != nil {
+		return sessionTokens{}, errors.New("Failed to generate refresh token")
+	}
+
+	session := types.Session{
+		ID:               uuid.New().String(),
+		UserID:           user.ID,
+		RefreshTokenHash: hashToken(secret),
+		UserAgent:        c.Get(fiber.HeaderUserAgent),
+		IP:               c.IP(),
+		CreatedAt:        time.Now(),
+		LastUsedAt:       time.Now(),
+		ExpiresAt:        time.Now().Add(refreshTokenAge()),
+	}
+
+	if err := sessionStore.CreateSession(session); err != nil {
+		return sessionTokens{}, errors.New("Failed to create session: " + err.Error())
+	}
+
+	if err := userStore.TouchLastLogin(user.ID); err != nil {
+		return sessionTokens{}, errors.New("Something Went Wrong while updating last log in informations: " + err.Error())
+	}
+
+	return issueTokens(c, user, session.ID, secret)
+}
+
+// logInSession starts the session of a log in and records it in the audit log, method is how the user proved
+// who they are like password, totp or oauth:github
+func logInSession(c *fiber.Ctx, userStore store.UserStore, sessionStore store.SessionStore, user types.UserRecord, method string) (sessionTokens, error) {
+	tokens, err := startSession(c, userStore, sessionStore, user)
+	if err == errUserDisabled {
+		auditLogInFailed(c, user, user.Email, method, "disabled")
+	} else if err == nil {
+		auditRequest(c, AuditLogInSucceeded, user, true, map[string]string{"method": method})
+	}
+	return tokens, err
+}
+
+// sessionFailed answers a request whose startSession failed
+func sessionFailed(c *fiber.Ctx, err error) error {
+	if err == errUserDisabled {
+		return c.Status(fiber.StatusForbidden).JSON(types.ErrorResponse{Error: err.Error()})
+	}
+	return c.Status(fiber.StatusInternalServerError).JSON(types.ErrorResponse{Error: err.Error()})
+}
+
+// requestRefreshToken reads the refreshToken cookie, with tokensInBody on it can also be sent as {"refreshToken": ...}
+func requestRefreshToken(c *fiber.Ctx) string {
+	if token := c.Cookies("refreshToken"); token != "" {
+		return token
+	}
+	if !config.Configs.AuthenticationConfigurations.TokensInBody {
+		return ""
+	}
+
+	var body struct {
+		RefreshToken string `json:"refreshToken"`
+	}
+	c.BodyParser(&body)
+	return body.RefreshToken
+}
+
+func RefreshSession(c *fiber.Ctx, userStore store.UserStore, sessionStore store.SessionStore) error {
+	sessionID, secret, found := strings.Cut(requestRefreshToken(c), ".")
+	if !found || sessionID == "" || secret == "" {
+		return c.Status(fiber.StatusUnauthorized).JSON(types.ErrorResponse{Error: "No refresh token found please log in"})
+	}
+
+	session, err := sessionStore.FindSession(sessionID)
+	if err == store.ErrSessionNotFound {
+		utils.ClearJwtHttpCookies(c)
+		return c.Status(fiber.StatusUnauthorized).JSON(types.ErrorResponse{Error: "Session has expired or been revoked please log in"})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(types.ErrorResponse{Error: "Something went wrong: " + err.Error()})
+	}
+
+	newSecret, err := generateToken()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(types.ErrorResponse{Error: "Failed to generate refresh token"})
+	}
+
+	// the token that was rotated away coming back means it has been copied, the whole session is dropped.
+	// Any other secret is only rejected, the session id is part of every access token and proves nothing
+	switch {
+	case tokenMatches(secret, session.RefreshTokenHash):
+		err = sessionStore.RotateRefreshToken(session.ID, session.RefreshTokenHash, hashToken(newSecret), time.Now().Add(refreshTokenAge()))
+	case session.PreviousRefreshTokenHash != "" && tokenMatches(secret, session.PreviousRefreshTokenHash):
+		err = store.ErrSessionNotFound
+	default:
+		utils.ClearJwtHttpCookies(c)
+		return c.Status(fiber.StatusUnauthorized).JSON(types.ErrorResponse{Error: "Invalid refresh token please log in"})
+	}
+	if err == store.ErrSessionNotFound {
+		utils.DebugLogger("sessions", "refresh token reuse detected for session "+session.ID+" of user "+session.UserID)
+		sessionStore.DeleteSession(session.ID)
+		utils.ClearJwtHttpCookies(c)
+		return c.Status(fiber.StatusUnauthorized).JSON(types.ErrorResponse{Error: "Refresh token has already been used, the session has been revoked please log in"})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(types.ErrorResponse{Error: "Failed to rotate refresh token: " + err.Error()})
+	}
+
+	// roles and permissions are read again so changes reach the user with the next access token
+	user, err := userStore.FindUserByID(session.UserID)
+	if err != nil || user.Disabled {
+		sessionStore.DeleteSession(session.ID)
+		utils.ClearJwtHttpCookies(c)
+		return c.Status(fiber.StatusUnauthorized).JSON(types.ErrorResponse{Error: "User not found or disabled please log in"})
+	}
+
+	// guests are purged by their last log in, using the app counts as logging in again
+	if user.Anonymous {
+		if err := userStore.TouchLastLogin(user.ID); err != nil {
+			utils.DebugLogger("anonymous", "failed to touch last log in of guest "+user.ID+": "+err.Error())
+		}
+	}
+
+	tokens, err := issueTokens(c, user, session.ID, newSecret)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(types.ErrorResponse{Error: err.Error()})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(types.HTTPSuccessResponse{
+		Message: "Session has been refreshed",
+		Data:    withTokens(c, tokens, map[string]any{"userID": session.UserID}),
+	})
+}
+
+func LogOut(c *fiber.Ctx, sessionStore store.SessionStore) error {
+	sessionID, _ := c.Locals("sessionId").(string)
+
+	if err := sessionStore.DeleteSession(sessionID); err != nil && err != store.ErrSessionNotFound {
+		return c.Status(fiber.StatusInternalServerError).JSON(types.ErrorResponse{Error: "Failed to revoke session: " + err.Error()})
+	}
+
+	return utils.LogOut(c)
+}
+
+func LogOutEverywhere(c *fiber.Ctx, sessionStore store.SessionStore) error {
+	userId, _ := c.Locals("userId").(string)
+
+	if err := sessionStore.DeleteUserSessions(userId); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(types.ErrorResponse{Error: "Failed to revoke sessions: " + err.Error()})
+	}
+
+	utils.ClearJwtHttpCookies(c)
+	return c.Status(fiber.StatusOK).JSON(types.HTTPSuccessResponse{Message: "User has been logged out from every device"})
+}
+
+func ListSessions(c *fiber.Ctx, sessionStore store.SessionStore) error {
+	userId, _ := c.Locals("userId").(string)
+
+	sessions, err := sessionStore.ListUserSessions(userId)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(types.ErrorResponse{Error: "Failed to list sessions: " + err.Error()})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(types.HTTPSuccessResponse{
+		Message: "Sessions have been found successfully",
+		Data:    map[string]any{"sessions": sessions, "currentSessionID": c.Locals("sessionId")},
+	})
+}
+
+func RevokeSession(c *fiber.Ctx, sessionStore store.SessionStore) error {
+	userId, _ := c.Locals("userId").(string)
+
+	session, err := sessionStore.FindSession(c.Params("id"))
+	if err == store.ErrSessionNotFound || (err == nil && session.UserID != userId) {
+		return c.Status(fiber.StatusNotFound).JSON(types.ErrorResponse{Error: "Session not found"})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(types.ErrorResponse{Error: "Something went wrong: " + err.Error()})
+	}
+
+	if err := sessionStore.DeleteSession(session.ID); err != nil && err != store.ErrSessionNotFound {
+		return c.Status(fiber.StatusInternalServerError).JSON(types.ErrorResponse{Error: "Failed to revoke session: " + err.Error()})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(types.HTTPSuccessResponse{Message: "Session has been revoked"})
+}
+
+func AdminListUserSessions(c *fiber.Ctx, sessionStore store.SessionStore) error {
+	sessions, err := sessionStore.ListUserSessions(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(types.ErrorResponse{Error: "Failed to list sessions: " + err.Error()})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(types.HTTPSuccessResponse{
+		Message: "Sessions have been found successfully",
+		Data:    map[string]any{"sessions": sessions},
+	})
+}
+
+func AdminRevokeUserSessions(c *fiber.Ctx, sessionStore store.SessionStore) error {
+	if err := sessionStore.DeleteUserSessions(c.Params("id")); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(types.ErrorResponse{Error: "Failed to revoke sessions: " + err.Error()})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(types.HTTPSuccessResponse{Message: "Every session of the user has been revoked"})
+}
+
+func AdminRevokeSession(c *fiber.Ctx, sessionStore store.SessionStore) error {
+	err := sessionStore.DeleteSession(c.Params("id"))
+	if err == store.ErrSessionNotFound {
+		return c.Status(fiber.StatusNotFound).JSON(types.ErrorResponse{Error: "Session not found"})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(types.ErrorResponse{Error: "Failed to revoke session: " + err.Error()})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(types.HTTPSuccessResponse{Message: "Session has been revoked"})
+}
+
+// JWKS publishes the public keys other services verify access tokens with
+func JWKS(c *fiber.Ctx) error {
+	c.Set(fiber.HeaderCacheControl, "public, max-age=300")
+	return c.Status(fiber.StatusOK).JSON(utils.JWTKeys.JWKS())
+}
@@ -0,0 +1,66 @@
+package authentication
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/froggy-12/purpurbase/store"
+	"github.com/froggy-12/purpurbase/types"
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestRefreshSessionOnlyRevokesOnReuse(t *testing.T) {
+	useTestSecret(t)
+	userStore := store.NewMemoryUserStore()
+	sessionStore := store.NewMemorySessionStore()
+	if err := userStore.CreateUser(types.UserRecord{ID: "user", Email: "user@example.com", UserName: "user"}); err != nil {
+		t.Fatal(err)
+	}
+	session := types.Session{ID: "session", UserID: "user", RefreshTokenHash: hashToken("first"), CreatedAt: time.Now(), ExpiresAt: time.Now().Add(time.Hour)}
+	if err := sessionStore.CreateSession(session); err != nil {
+		t.Fatal(err)
+	}
+
+	app := fiber.New()
+	app.Post("/refresh", func(c *fiber.Ctx) error { return RefreshSession(c, userStore, sessionStore) })
+	refresh := func(token string) (int, string) {
+		req := httptest.NewRequest("POST", "/refresh", nil)
+		req.Header.Set("Cookie", "refreshToken="+token)
+		response, err := app.Test(req, -1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, cookie := range response.Cookies() {
+			if cookie.Name == "refreshToken" {
+				return response.StatusCode, cookie.Value
+			}
+		}
+		return response.StatusCode, ""
+	}
+
+	// the session id is in every access token, guessing a secret must not end the session
+	if code, _ := refresh("session.guessed"); code != fiber.StatusUnauthorized {
+		t.Errorf("a wrong secret answered %d, want 401", code)
+	}
+	if _, err := sessionStore.FindSession("session"); err != nil {
+		t.Fatalf("a wrong secret revoked the session: %v", err)
+	}
+
+	code, rotated := refresh("session.first")
+	if code != fiber.StatusOK || !strings.HasPrefix(rotated, "session.") {
+		t.Fatalf("the current secret answered %d with %q", code, rotated)
+	}
+
+	// the secret that was just rotated away is a copied token
+	if code, _ := refresh("session.first"); code != fiber.StatusUnauthorized {
+		t.Errorf("a reused secret answered %d, want 401", code)
+	}
+	if _, err := sessionStore.FindSession("session"); err != store.ErrSessionNotFound {
+		t.Errorf("a reused secret kept the session: %v", err)
+	}
+	if code, _ := refresh(rotated); code != fiber.StatusUnauthorized {
+		t.Errorf("the newest secret of a revoked session answered %d, want 401", code)
+	}
+}
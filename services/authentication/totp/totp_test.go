@@ -0,0 +1,84 @@
+package totp
+
+import (
+	"encoding/base32"
+	"strings"
+	"testing"
+	"time"
+)
+
+// the SHA1 seed of RFC 6238 appendix B
+var rfcSecret = base32.StdEncoding.EncodeToString([]byte("12345678901234567890"))
+
+func TestCodeMatchesRFC6238(t *testing.T) {
+	// the 8 digit codes of the appendix, 6 digit codes are their last 6 digits
+	tests := []struct {
+		unix int64
+		code string
+	}{
+		{59, "94287082"},
+		{1111111109, "07081804"},
+		{1111111111, "14050471"},
+		{1234567890, "89005924"},
+		{2000000000, "69279037"},
+		{20000000000, "65353130"},
+	}
+
+	for _, test := range tests {
+		code, err := Code(rfcSecret, time.Unix(test.unix, 0))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := test.code[len(test.code)-digits:]; code != want {
+			t.Errorf("Code at %d = %s, want %s", test.unix, code, want)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+	code, err := Code(rfcSecret, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		secret string
+		code   string
+		at     time.Time
+		ok     bool
+	}{
+		{"same step", rfcSecret, code, now, true},
+		{"one step later", rfcSecret, code, now.Add(period * time.Second), true},
+		{"one step earlier", rfcSecret, code, now.Add(-period * time.Second), true},
+		{"two steps later", rfcSecret, code, now.Add(2 * period * time.Second), false},
+		{"two steps earlier", rfcSecret, code, now.Add(-2 * period * time.Second), false},
+		{"spaces", rfcSecret, code[:3] + " " + code[3:], now, true},
+		{"lowercase secret", strings.ToLower(rfcSecret), code, now, true},
+		{"padded secret", rfcSecret + "====", code, now, true},
+		{"wrong code", rfcSecret, "000000", now, code == "000000"},
+		{"too short", rfcSecret, code[:5], now, false},
+		{"8 digits", rfcSecret, "14050471", now, false},
+		{"invalid secret", "not base32!", code, now, false},
+	}
+
+	for _, test := range tests {
+		step, ok := Validate(test.secret, test.code, test.at)
+		if ok != test.ok {
+			t.Errorf("%s: Validate = %v, want %v", test.name, ok, test.ok)
+		}
+		if ok && step != Step(now) {
+			t.Errorf("%s: matched step %d, want %d", test.name, step, Step(now))
+		}
+	}
+}
+
+func TestURI(t *testing.T) {
+	uri := URI("Purpur Base", "user@example.com", "SECRET")
+	for _, part := range []string{"otpauth://totp/Purpur%20Base:user@example.com?", "secret=SECRET", "issuer=Purpur+Base", "digits=6", "period=30", "algorithm=SHA1"} {
+		if !strings.Contains(uri, part) {
+			t.Errorf("%s is missing %s", uri, part)
+		}
+	}
+}
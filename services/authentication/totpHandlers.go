@@ -0,0 +1,380 @@
+package authentication
+
+import (
+	"crypto/rand"
+	"encoding/base32"
+	"errors"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/froggy-12/purpurbase/config"
+	"github.com/froggy-12/purpurbase/services/authentication/totp"
+	"github.com/froggy-12/purpurbase/store"
+	"github.com/froggy-12/purpurbase/types"
+	"github.com/froggy-12/purpurbase/utils"
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+)
+
+const (
+	mfaChallengeAge   = 5 * time.Minute
+	recoveryCodeCount = 10
+)
+
+func totpIssuer() string {
+	if config.Configs.AuthenticationConfigurations.TOTPIssuer == "" {
+		return "Purpurbase"
+	}
+	return config.Configs.AuthenticationConfigurations.TOTPIssuer
+}
+
+func generateMFAChallenge(user types.UserRecord) (string, error) {
+	return utils.GenerateMFAChallengeToken(user.ID, mfaChallengeAge, config.Configs.PurpurbaseConfigurations.PurpurbaseJWTTokenSecret)
+}
+
+// sendMFAChallenge answers a correct password when the account still needs its second factor
+func sendMFAChallenge(c *fiber.Ctx, user types.UserRecord) error {
+	token, err := generateMFAChallenge(user)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(types.ErrorResponse{Error: "Failed to generate mfa challenge token"})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(types.HTTPSuccessResponse{
+		Message: "Two factor authentication code required",
+		Data:    map[string]any{"mfaRequired": true, "mfaToken": token},
+	})
+}
+
+func normalizeRecoveryCode(code string) string {
+	return strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+}
+
+// generateRecoveryCodes returns the codes to show once and the hashes to store
+func generateRecoveryCodes() ([]string, []string, error) {
+	codes := make([]string, recoveryCodeCount)
+	hashes := make([]string, recoveryCodeCount)
+	for i := range codes {
+		b := make([]byte, 5)
+		if _, err := rand.Read(b); err != nil {
+			return nil, nil, err
+		}
+		code := strings.ToLower(base32.StdEncoding.EncodeToString(b))
+		codes[i] = code[:4] + "-" + code[4:]
+		hashes[i] = hashToken(normalizeRecoveryCode(code))
+	}
+	return codes, hashes, nil
+}
+
+// checkTOTPCode accepts each code only once, the matched step is claimed in the store so parallel requests can't both use it
+func checkTOTPCode(userStore store.UserStore, user *types.UserRecord, code string) (bool, error) {
+	step, ok := totp.Validate(user.TOTPSecret, code, time.Now())
+	if !ok || int(step) <= user.TOTPLastStep {
+		return false, nil
+	}
+	if err := userStore.UseTOTPStep(user.ID, int(step)); err != nil {
+		if errors.Is(err, store.ErrCodeAlreadyUsed) {
+			return false, nil
+		}
+		return false, err
+	}
+	user.TOTPLastStep = int(step)
+	return true, nil
+}
+
+// useRecoveryCode removes the matching recovery code in the store, only one request can use it
+func useRecoveryCode(userStore store.UserStore, user *types.UserRecord, code string) (bool, error) {
+	code = normalizeRecoveryCode(code)
+	index := slices.IndexFunc(user.TOTPRecoveryCodes, func(hash string) bool { return tokenMatches(code, hash) })
+	if index < 0 {
+		return false, nil
+	}
+	if err := userStore.UseRecoveryCode(user.ID, user.TOTPRecoveryCodes[index]); err != nil {
+		if errors.Is(err, store.ErrCodeAlreadyUsed) {
+			return false, nil
+		}
+		return false, err
+	}
+	user.TOTPRecoveryCodes = slices.Delete(user.TOTPRecoveryCodes, index, index+1)
+	return true, nil
+}
+
+func LogInWithTOTP(c *fiber.Ctx, userStore store.UserStore, sessionStore store.SessionStore, attempts store.LoginAttemptStore, validator validator.Validate) error {
+	var body struct {
+		MFAToken     string `json:"mfaToken" validate:"required"`
+		Code         string `json:"code" validate:"required_without=RecoveryCode"`
+		RecoveryCode string `json:"recoveryCode"`
+	}
+
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(types.ErrorResponse{Error: "Invalid Request body"})
+	}
+
+	if err := validator.Struct(&body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(types.ErrorResponse{Error: err.Error()})
+	}
+
+	userID, err := utils.ReadMFAChallengeToken(body.MFAToken, config.Configs.PurpurbaseConfigurations.PurpurbaseJWTTokenSecret)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(types.ErrorResponse{Error: "Invalid or expired mfa token please log in again"})
+	}
+
+	user, err := userStore.FindUserByID(userID)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(types.ErrorResponse{Error: "Invalid or expired mfa token please log in again"})
+	}
+
+	if !user.TOTPEnabled {
+		return c.Status(fiber.StatusBadRequest).JSON(types.ErrorResponse{Error: "Two factor authentication is not enabled for this user"})
+	}
+
+	// wrong codes count as failed log ins too, six digits are quick to guess otherwise
+	lockedFor, err := loginLockedFor(attempts, user.Email, c.IP())
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(types.ErrorResponse{Error: "Something went wrong: " + err.Error()})
+	}
+	method := "totp"
+	if body.Code == "" {
+		method = "recovery_code"
+	}
+	if lockedFor > 0 {
+		auditLogInFailed(c, user, user.Email, method, "locked")
+		return tooManyAttempts(c, lockedFor)
+	}
+
+	var verified bool
+	if body.Code != "" {
+		verified, err = checkTOTPCode(userStore, &user, body.Code)
+	} else {
+		verified, err = useRecoveryCode(userStore, &user, body.RecoveryCode)
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(types.ErrorResponse{Error: "Something went wrong: " + err.Error()})
+	}
+
+	if !verified {
+		auditLogInFailed(c, user, user.Email, method, "wrong_code")
+		lockedFor, err := recordLoginFailure(attempts, userStore, user.Email, c.IP())
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(types.ErrorResponse{Error: "Something went wrong: " + err.Error()})
+		}
+		if lockedFor > 0 {
+			return tooManyAttempts(c, lockedFor)
+		}
+		return c.Status(fiber.StatusBadRequest).JSON(types.ErrorResponse{Error: "Wrong two factor authentication code"})
+	}
+
+	loginSucceeded(attempts, user.Email)
+
+	tokens, err := logInSession(c, userStore, sessionStore, user, method)
+	if err != nil {
+		return sessionFailed(c, err)
+	}
+
+	return c.Status(fiber.StatusAccepted).JSON(types.HTTPSuccessResponse{
+		Message: "User has been logged in successfully",
+		Data:    withTokens(c, tokens, map[string]any{"userID": user.ID, "recoveryCodesLeft": len(user.TOTPRecoveryCodes)}),
+	})
+}
+
+// EnrollTOTP starts the enrollment, accounts without a password need a fresh log in instead of the password
+func EnrollTOTP(c *fiber.Ctx, userStore store.UserStore, sessionStore store.SessionStore, validator validator.Validate) error {
+	userId, _ := c.Locals("userId").(string)
+
+	var body struct {
+		Password string `json:"password"`
+	}
+
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(types.ErrorResponse{Error: "Invalid request body: " + err.Error()})
+	}
+
+	if err := validator.Struct(&body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(types.ErrorResponse{Error: "Invalid request body: " + err.Error()})
+	}
+
+	user, err := userStore.FindUserByID(userId)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(types.ErrorResponse{Error: "User Not Found: " + err.Error()})
+	}
+
+	confirmed, err := confirmIdentity(c, sessionStore, user, body.Password)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(types.ErrorResponse{Error: "Something went wrong: " + err.Error()})
+	}
+	if !confirmed {
+		return identityNotConfirmed(c, user, "Wrong Password")
+	}
+
+	if user.TOTPEnabled {
+		return c.Status(fiber.StatusBadRequest).JSON(types.ErrorResponse{Error: "Two factor authentication is already enabled"})
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(types.ErrorResponse{Error: "Failed to generate totp secret"})
+	}
+
+	user.TOTPSecret = secret
+	if err := userStore.UpdateUser(user); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(types.ErrorResponse{Error: "Failed to save totp secret: " + err.Error()})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(types.HTTPSuccessResponse{
+		Message: "Add the secret to an authenticator app and confirm it with the first code",
+		Data:    map[string]any{"secret": secret, "otpauthURI": totp.URI(totpIssuer(), user.Email, secret)},
+	})
+}
+
+func ConfirmTOTP(c *fiber.Ctx, userStore store.UserStore, validator validator.Validate) error {
+	userId, _ := c.Locals("userId").(string)
+
+	var body struct {
+		Code string `json:"code" validate:"required"`
+	}
+
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(types.ErrorResponse{Error: "Invalid request body: " + err.Error()})
+	}
+
+	if err := validator.Struct(&body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(types.ErrorResponse{Error: "Invalid request body: " + err.Error()})
+	}
+
+	user, err := userStore.FindUserByID(userId)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(types.ErrorResponse{Error: "User Not Found: " + err.Error()})
+	}
+
+	if user.TOTPEnabled {
+		return c.Status(fiber.StatusBadRequest).JSON(types.ErrorResponse{Error: "Two factor authentication is already enabled"})
+	}
+	if user.TOTPSecret == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(types.ErrorResponse{Error: "Start the enrollment first"})
+	}
+
+	verified, err := checkTOTPCode(userStore, &user, body.Code)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(types.ErrorResponse{Error: "Something went wrong: " + err.Error()})
+	}
+	if !verified {
+		return c.Status(fiber.StatusBadRequest).JSON(types.ErrorResponse{Error: "Wrong two factor authentication code"})
+	}
+
+	codes, hashes, err := generateRecoveryCodes()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(types.ErrorResponse{Error: "Failed to generate recovery codes"})
+	}
+
+	user.TOTPEnabled = true
+	user.TOTPRecoveryCodes = hashes
+	if err := userStore.UpdateUser(user); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(types.ErrorResponse{Error: "Failed to enable two factor authentication: " + err.Error()})
+	}
+	auditRequest(c, AuditTOTPEnabled, user, true, nil)
+
+	return c.Status(fiber.StatusOK).JSON(types.HTTPSuccessResponse{
+		Message: "Two factor authentication has been enabled, keep the recovery codes somewhere safe they are only shown once",
+		Data:    map[string]any{"recoveryCodes": codes},
+	})
+}
+
+func RegenerateRecoveryCodes(c *fiber.Ctx, userStore store.UserStore, validator validator.Validate) error {
+	userId, _ := c.Locals("userId").(string)
+
+	var body struct {
+		Code string `json:"code" validate:"required"`
+	}
+
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(types.ErrorResponse{Error: "Invalid request body: " + err.Error()})
+	}
+
+	if err := validator.Struct(&body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(types.ErrorResponse{Error: "Invalid request body: " + err.Error()})
+	}
+
+	user, err := userStore.FindUserByID(userId)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(types.ErrorResponse{Error: "User Not Found: " + err.Error()})
+	}
+
+	if !user.TOTPEnabled {
+		return c.Status(fiber.StatusBadRequest).JSON(types.ErrorResponse{Error: "Wrong two factor authentication code"})
+	}
+	verified, err := checkTOTPCode(userStore, &user, body.Code)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(types.ErrorResponse{Error: "Something went wrong: " + err.Error()})
+	}
+	if !verified {
+		return c.Status(fiber.StatusBadRequest).JSON(types.ErrorResponse{Error: "Wrong two factor authentication code"})
+	}
+
+	codes, hashes, err := generateRecoveryCodes()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(types.ErrorResponse{Error: "Failed to generate recovery codes"})
+	}
+
+	user.TOTPRecoveryCodes = hashes
+	if err := userStore.UpdateUser(user); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(types.ErrorResponse{Error: "Failed to save recovery codes: " + err.Error()})
+	}
+	auditRequest(c, AuditRecoveryCodesRenewed, user, true, nil)
+
+	return c.Status(fiber.StatusOK).JSON(types.HTTPSuccessResponse{
+		Message: "New recovery codes have been generated, the old ones do not work anymore",
+		Data:    map[string]any{"recoveryCodes": codes},
+	})
+}
+
+func DisableTOTP(c *fiber.Ctx, userStore store.UserStore, sessionStore store.SessionStore, validator validator.Validate) error {
+	userId, _ := c.Locals("userId").(string)
+
+	var body struct {
+		Password string `json:"password"`
+		Code     string `json:"code" validate:"required"`
+	}
+
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(types.ErrorResponse{Error: "Invalid request body: " + err.Error()})
+	}
+
+	if err := validator.Struct(&body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(types.ErrorResponse{Error: "Invalid request body: " + err.Error()})
+	}
+
+	user, err := userStore.FindUserByID(userId)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(types.ErrorResponse{Error: "User Not Found: " + err.Error()})
+	}
+
+	if !user.TOTPEnabled {
+		return c.Status(fiber.StatusBadRequest).JSON(types.ErrorResponse{Error: "Two factor authentication is not enabled"})
+	}
+
+	confirmed, err := confirmIdentity(c, sessionStore, user, body.Password)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(types.ErrorResponse{Error: "Something went wrong: " + err.Error()})
+	}
+	if !confirmed {
+		return identityNotConfirmed(c, user, "Wrong Password or code")
+	}
+	verified, err := checkTOTPCode(userStore, &user, body.Code)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(types.ErrorResponse{Error: "Something went wrong: " + err.Error()})
+	}
+	if !verified {
+		return c.Status(fiber.StatusBadRequest).JSON(types.ErrorResponse{Error: "Wrong Password or code"})
+	}
+
+	user.TOTPEnabled = false
+	user.TOTPSecret = ""
+	user.TOTPRecoveryCodes = nil
+	user.TOTPLastStep = 0
+	if err := userStore.UpdateUser(user); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(types.ErrorResponse{Error: "Failed to disable two factor authentication: " + err.Error()})
+	}
+	auditRequest(c, AuditTOTPDisabled, user, true, nil)
+
+	return c.Status(fiber.StatusOK).JSON(types.HTTPSuccessResponse{Message: "Two factor authentication has been disabled"})
+}
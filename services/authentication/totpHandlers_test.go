@@ -0,0 +1,92 @@
+package authentication
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/froggy-12/purpurbase/services/authentication/totp"
+	"github.com/froggy-12/purpurbase/store"
+	"github.com/froggy-12/purpurbase/types"
+)
+
+func TestTOTPCodesWorkOnce(t *testing.T) {
+	userStore := store.NewMemoryUserStore()
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	user := types.UserRecord{ID: "user", Email: "user@example.com", UserName: "user", TOTPEnabled: true, TOTPSecret: secret}
+	if err := userStore.CreateUser(user); err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	code, err := totp.Code(secret, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := checkTOTPCode(userStore, &user, code); err != nil || !ok {
+		t.Fatalf("the current code was refused: %v", err)
+	}
+	if user.TOTPLastStep != int(totp.Step(now)) {
+		t.Errorf("TOTPLastStep is %d, want the current step", user.TOTPLastStep)
+	}
+
+	// a second request still holding the old user must not get in with the same code either
+	stale, err := userStore.FindUserByID("user")
+	if err != nil {
+		t.Fatal(err)
+	}
+	stale.TOTPLastStep = 0
+	for name, replaying := range map[string]*types.UserRecord{"same request": &user, "parallel request": &stale} {
+		if ok, err := checkTOTPCode(userStore, replaying, code); err != nil || ok {
+			t.Errorf("%s: a replayed code was accepted, %v", name, err)
+		}
+	}
+
+	// the code of the previous step is within the skew but older than the one already used
+	previous, err := totp.Code(secret, now.Add(-30*time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok, _ := checkTOTPCode(userStore, &user, previous); ok {
+		t.Error("a code older than the used one was accepted")
+	}
+}
+
+func TestRecoveryCodesWorkOnce(t *testing.T) {
+	userStore := store.NewMemoryUserStore()
+	codes, hashes, err := generateRecoveryCodes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(codes) != recoveryCodeCount || len(hashes) != recoveryCodeCount || codes[0] == hashes[0] {
+		t.Fatalf("generated %d codes and %d hashes", len(codes), len(hashes))
+	}
+	user := types.UserRecord{ID: "user", Email: "user@example.com", UserName: "user", TOTPEnabled: true, TOTPRecoveryCodes: hashes}
+	if err := userStore.CreateUser(user); err != nil {
+		t.Fatal(err)
+	}
+	stale := user
+
+	// codes are accepted the way they are shown, in capitals or without the dash too
+	typed := strings.ToUpper(strings.ReplaceAll(codes[0], "-", ""))
+	if ok, err := useRecoveryCode(userStore, &user, typed); err != nil || !ok {
+		t.Fatalf("the recovery code %q was refused: %v", typed, err)
+	}
+	if len(user.TOTPRecoveryCodes) != recoveryCodeCount-1 {
+		t.Errorf("%d recovery codes are left, want %d", len(user.TOTPRecoveryCodes), recoveryCodeCount-1)
+	}
+	for name, replaying := range map[string]*types.UserRecord{"same request": &user, "parallel request": &stale} {
+		if ok, err := useRecoveryCode(userStore, replaying, codes[0]); err != nil || ok {
+			t.Errorf("%s: a used recovery code was accepted, %v", name, err)
+		}
+	}
+	if ok, err := useRecoveryCode(userStore, &user, "aaaa-bbbb"); err != nil || ok {
+		t.Errorf("an unknown recovery code was accepted, %v", err)
+	}
+	if ok, err := useRecoveryCode(userStore, &user, codes[1]); err != nil || !ok {
+		t.Errorf("another recovery code was refused: %v", err)
+	}
+}
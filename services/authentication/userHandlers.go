@@ -0,0 +1,259 @@
+package authentication
+
+import (
+	"github.com/froggy-12/purpurbase/store"
+	"github.com/froggy-12/purpurbase/types"
+	"github.com/froggy-12/purpurbase/utils"
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+)
+
+func GetUser(c *fiber.Ctx, userStore store.UserStore) error {
+	userId, _ := c.Locals("userId").(string)
+
+	user, err := userStore.FindUserByID(userId)
+
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(types.ErrorResponse{Error: "Something Went Wrong maybe user not found: " + err.Error()})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(types.HTTPSuccessResponse{
+		Message: "User has been Found successfully",
+		Data:    map[string]any{"user": user},
+	})
+}
+
+func UpdateUser(c *fiber.Ctx, userStore store.UserStore) error {
+	userId, _ := c.Locals("userId").(string)
+
+	var UpdatedUser struct {
+		FirstName      string         `json:"firstName"`
+		LastName       string         `json:"lastName"`
+		ProfilePicture string         `json:"profilePicture"`
+		RawData        map[string]any `json:"rawData"`
+	}
+
+	if err := c.BodyParser(&UpdatedUser); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(types.ErrorResponse{Error: err.Error()})
+	}
+
+	user, err := userStore.FindUserByID(userId)
+
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(types.ErrorResponse{Error: "User Not Found: " + err.Error()})
+	}
+
+	if UpdatedUser.FirstName != "" {
+		user.FirstName = UpdatedUser.FirstName
+	}
+	if UpdatedUser.LastName != "" {
+		user.LastName = UpdatedUser.LastName
+	}
+	if UpdatedUser.ProfilePicture != "" {
+		user.ProfilePicture = UpdatedUser.ProfilePicture
+	}
+	if len(UpdatedUser.RawData) != 0 {
+		user.RawData = UpdatedUser.RawData
+	}
+
+	err = userStore.UpdateUser(user)
+
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(types.ErrorResponse{Error: "Failed to Update User " + err.Error()})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(types.HTTPSuccessResponse{Message: "User Has been Updated Successfully"})
+}
+
+// UpdateUserName needs the password, or a fresh log in for accounts without one
+func UpdateUserName(c *fiber.Ctx, userStore store.UserStore, sessionStore store.SessionStore, validator validator.Validate) error {
+	var body struct {
+		UserName    string `json:"username" validate:"required"`
+		NewUserName string `json:"newUserName" validate:"required"`
+		Password    string `json:"password"`
+	}
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(types.ErrorResponse{Error: "Invalid Request Body"})
+	}
+
+	if err := validator.Struct(&body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(types.ErrorResponse{Error: "Invalid Request Body: " + err.Error()})
+	}
+
+	user, err := userStore.FindUserByUsername(body.UserName)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(types.ErrorResponse{Error: "User Not Found: " + err.Error()})
+	}
+
+	if user.ID != c.Locals("userId") {
+		return c.Status(fiber.StatusBadRequest).JSON(types.ErrorResponse{Error: "Wrong Password or Username"})
+	}
+	confirmed, err := confirmIdentity(c, sessionStore, user, body.Password)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(types.ErrorResponse{Error: "Something went wrong: " + err.Error()})
+	}
+	if !confirmed {
+		return identityNotConfirmed(c, user, "Wrong Password or Username")
+	}
+
+	user.UserName = body.NewUserName
+	err = userStore.UpdateUser(user)
+	if err == store.ErrUserAlreadyExists {
+		return c.Status(fiber.StatusBadRequest).JSON(types.ErrorResponse{Error: "Username is already taken"})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(types.ErrorResponse{Error: "Something went wrong failed to update username: " + err.Error()})
+	}
+	auditRequest(c, AuditUsernameChanged, user, true, map[string]string{"previous": body.UserName, "new": body.NewUserName})
+
+	return c.Status(fiber.StatusAccepted).JSON(types.HTTPSuccessResponse{Message: "Username Has been Updated"})
+}
+
+func AddRawData(c *fiber.Ctx, userStore store.UserStore) error {
+	userId, _ := c.Locals("userId").(string)
+
+	var body struct {
+		RawData map[string]any `json:"rawData"`
+	}
+
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(types.ErrorResponse{Error: err.Error()})
+	}
+
+	if len(body.RawData) == 0 {
+		return c.Status(fiber.StatusOK).JSON(types.HTTPSuccessResponse{Message: "No data to append 👍🏻"})
+	}
+
+	user, err := userStore.FindUserByID(userId)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(types.ErrorResponse{Error: "User not found: " + err.Error()})
+	}
+
+	for key, value := range body.RawData {
+		user.RawData[key] = value
+	}
+
+	err = userStore.UpdateUser(user)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(types.ErrorResponse{Error: "Failed to update user data: " + err.Error()})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(types.HTTPSuccessResponse{Message: "Data updated successfully", Data: user.RawData})
+}
+
+func ChangePassword(c *fiber.Ctx, userStore store.UserStore, sessionStore store.SessionStore, attempts store.LoginAttemptStore, validator validator.Validate) error {
+	var body struct {
+		Email       string `json:"email" validate:"required,email"`
+		Password    string `json:"password" validate:"required"`
+		NewPassword string `json:"newPassword" validate:"required"`
+	}
+
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(types.ErrorResponse{Error: "Invalid request body: " + err.Error()})
+	}
+
+	if err := validator.Struct(&body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(types.ErrorResponse{Error: "Invalid request body: " + err.Error()})
+	}
+
+	user, err := userStore.FindUserByEmail(body.Email)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(types.ErrorResponse{Error: "User Not Found: " + err.Error()})
+	}
+	if user.ID != c.Locals("userId") {
+		return c.Status(fiber.StatusBadRequest).JSON(types.ErrorResponse{Error: wrongCredentials})
+	}
+
+	// a stolen session must not be a way around the log in lockout to guess the password
+	lockedFor, err := loginLockedFor(attempts, user.Email, c.IP())
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(types.ErrorResponse{Error: "Something went wrong: " + err.Error()})
+	}
+	if lockedFor > 0 {
+		return tooManyAttempts(c, lockedFor)
+	}
+	if !checkPassword(user, body.Password) {
+		auditRequest(c, AuditPasswordChanged, user, false, map[string]string{"via": "change", "reason": "wrong_password"})
+		return loginFailed(c, attempts, userStore, user.Email)
+	}
+
+	if err := setPassword(&user, body.NewPassword); err != nil {
+		return passwordRejected(c, err)
+	}
+
+	err = userStore.UpdateUser(user)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(types.ErrorResponse{Error: "Something went wrong failed to update password: " + err.Error()})
+	}
+	auditRequest(c, AuditPasswordChanged, user, true, map[string]string{"via": "change"})
+	loginSucceeded(attempts, user.Email)
+
+	// every other device has to log in with the new password, this one stays logged in
+	sessions, err := sessionStore.ListUserSessions(user.ID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(types.ErrorResponse{Error: "Password has been updated but failed to revoke sessions: " + err.Error()})
+	}
+	for _, session := range sessions {
+		if session.ID == c.Locals("sessionId") {
+			continue
+		}
+		if err := sessionStore.DeleteSession(session.ID); err != nil && err != store.ErrSessionNotFound {
+			return c.Status(fiber.StatusInternalServerError).JSON(types.ErrorResponse{Error: "Password has been updated but failed to revoke sessions: " + err.Error()})
+		}
+	}
+
+	return c.Status(fiber.StatusAccepted).JSON(types.HTTPSuccessResponse{Message: "Password Has been Updated"})
+}
+
+// DeleteUser deletes the account of the logged in user, removeFiles deletes the uploads of the user first
+func DeleteUser(c *fiber.Ctx, userStore store.UserStore, sessionStore store.SessionStore, removeFiles func(userID string) error, validator validator.Validate) error {
+	userId, _ := c.Locals("userId").(string)
+
+	var body struct {
+		Email    string `json:"email" validate:"required,email"`
+		Password string `json:"password"`
+	}
+
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(types.ErrorResponse{Error: "Invalid request body: " + err.Error()})
+	}
+
+	if err := validator.Struct(&body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(types.ErrorResponse{Error: "Invalid request body: " + err.Error()})
+	}
+
+	user, err := userStore.FindUserByID(userId)
+
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(types.ErrorResponse{Error: "User not found: " + err.Error()})
+	}
+
+	// guests have no password or log in to confirm with
+	if !user.Anonymous {
+		confirmed, err := confirmIdentity(c, sessionStore, user, body.Password)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(types.ErrorResponse{Error: "Something went wrong: " + err.Error()})
+		}
+		if !confirmed {
+			return identityNotConfirmed(c, user, "Wrong Password or email")
+		}
+	}
+
+	if err := removeFiles(user.ID); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(types.ErrorResponse{Error: "Failed to delete your files: " + err.Error()})
+	}
+
+	err = userStore.DeleteUser(user.ID)
+
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(types.ErrorResponse{Error: "Failed to delete user: " + err.Error()})
+	}
+	auditRequest(c, AuditDeleted, user, true, map[string]string{"via": "self"})
+
+	if err := sessionStore.DeleteUserSessions(user.ID); err != nil {
+		utils.DebugLogger("sessions", "failed to revoke sessions of deleted user "+user.ID+": "+err.Error())
+	}
+	utils.ClearJwtHttpCookies(c)
+
+	return c.Status(fiber.StatusAccepted).JSON(types.HTTPSuccessResponse{Message: "User has been deleted successfully"})
+}
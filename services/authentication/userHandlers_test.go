@@ -0,0 +1,114 @@
+package authentication
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/froggy-12/purpurbase/store"
+	"github.com/froggy-12/purpurbase/types"
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestChangePasswordLocksOutAndEndsOtherSessions(t *testing.T) {
+	userStore := store.NewMemoryUserStore()
+	sessionStore := store.NewMemorySessionStore()
+	attempts := store.NewMemoryLoginAttemptStore()
+
+	hash, err := hashPassword("old password 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := userStore.CreateUser(types.UserRecord{ID: "user", Email: "user@example.com", UserName: "user", Password: hash}); err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []string{"this", "other"} {
+		if err := sessionStore.CreateSession(types.Session{ID: id, UserID: "user", CreatedAt: time.Now(), ExpiresAt: time.Now().Add(time.Hour)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	app := fiber.New()
+	app.Use(loggedInAs("user", "this"))
+	app.Put("/change-password", func(c *fiber.Ctx) error {
+		return ChangePassword(c, userStore, sessionStore, attempts, *validator.New())
+	})
+	change := func(current string) int {
+		return send(t, app, "PUT", "/change-password", map[string]string{"email": "user@example.com", "password": current, "newPassword": "new password 1"})
+	}
+
+	for i := 0; i < loginProtection().MaxAccountAttempts-1; i++ {
+		if code := change("guessed"); code != fiber.StatusBadRequest {
+			t.Fatalf("wrong password %d answered %d, want 400", i, code)
+		}
+	}
+	if code := change("guessed"); code != fiber.StatusTooManyRequests {
+		t.Fatalf("the last wrong password answered %d, want 429", code)
+	}
+	if code := change("old password 1"); code != fiber.StatusTooManyRequests {
+		t.Fatalf("the right password of a locked account answered %d, want 429", code)
+	}
+
+	if err := attempts.Unlock(accountAttemptKey("user@example.com")); err != nil {
+		t.Fatal(err)
+	}
+	if code := change("old password 1"); code != fiber.StatusAccepted {
+		t.Fatalf("the right password answered %d, want 202", code)
+	}
+	if _, err := sessionStore.FindSession("this"); err != nil {
+		t.Errorf("the session that changed the password was revoked: %v", err)
+	}
+	if _, err := sessionStore.FindSession("other"); err != store.ErrSessionNotFound {
+		t.Errorf("another session survived the password change: %v", err)
+	}
+}
+
+func TestDeletingUsersRemovesTheirFiles(t *testing.T) {
+	userStore := store.NewMemoryUserStore()
+	sessionStore := store.NewMemorySessionStore()
+	var removed []string
+	removeFiles := func(userID string) error {
+		removed = append(removed, userID)
+		return nil
+	}
+
+	hash, err := hashPassword("the password 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []string{"self", "other"} {
+		if err := userStore.CreateUser(types.UserRecord{ID: id, Email: id + "@example.com", UserName: id, Password: hash}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	app := fiber.New()
+	app.Use(loggedInAs("self", "session"))
+	app.Delete("/delete-user", func(c *fiber.Ctx) error {
+		return DeleteUser(c, userStore, sessionStore, removeFiles, *validator.New())
+	})
+	app.Delete("/users/:id", func(c *fiber.Ctx) error { return AdminDeleteUser(c, userStore, sessionStore, removeFiles) })
+
+	if code := send(t, app, "DELETE", "/delete-user", map[string]string{"email": "self@example.com", "password": "guessed"}); code != fiber.StatusBadRequest {
+		t.Errorf("a wrong password answered %d, want 400", code)
+	}
+	if len(removed) != 0 {
+		t.Fatalf("a refused delete removed the files of %v", removed)
+	}
+	if code := send(t, app, "DELETE", "/delete-user", map[string]string{"email": "self@example.com", "password": "the password 1"}); code != fiber.StatusAccepted {
+		t.Errorf("deleting the account answered %d, want 202", code)
+	}
+	if code := send(t, app, "DELETE", "/users/other", nil); code != fiber.StatusOK {
+		t.Errorf("the admin delete answered %d, want 200", code)
+	}
+
+	if strings.Join(removed, ",") != "self,other" {
+		t.Errorf("removed the files of %v, want self and other", removed)
+	}
+	for _, id := range []string{"self", "other"} {
+		if _, err := userStore.FindUserByID(id); err != store.ErrUserNotFound {
+			t.Errorf("%s was not deleted: %v", id, err)
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package mediaserver
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/froggy-12/purpurbase/services/storage"
+	"github.com/gofiber/fiber/v2"
+)
+
+// notModified checks If-None-Match against the etag and, without it, If-Modified-Since against the modification
+// time. Http dates only carry seconds so the modification time is truncated
+func notModified(c *fiber.Ctx, info storage.ObjectInfo, etag string) bool {
+	if match := c.Get(fiber.HeaderIfNoneMatch); match != "" {
+		return etag != "" && etagMatches(match, etag)
+	}
+
+	since, err := http.ParseTime(c.Get(fiber.HeaderIfModifiedSince))
+	if err != nil || info.ModifiedAt.IsZero() {
+		return false
+	}
+	return !info.ModifiedAt.Truncate(time.Second).After(since)
+}
+
+func etagMatches(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// requestRange reads a single "bytes=" range of the Range header. Without a usable Range header, with several
+// ranges or an If-Range that doesn't match anymore the whole file is sent, satisfiable is false when the range
+// lies outside of the file
+func requestRange(c *fiber.Ctx, info storage.ObjectInfo, etag string) (start, end int64, ranged, satisfiable bool) {
+	header := c.Get(fiber.HeaderRange)
+	spec, ok := strings.CutPrefix(header, "bytes=")
+	if !ok || strings.Contains(spec, ",") || !ifRangeMatches(c.Get(fiber.HeaderIfRange), info, etag) {
+		return 0, 0, false, true
+	}
+
+	first, last, ok := strings.Cut(strings.TrimSpace(spec), "-")
+	if !ok {
+		return 0, 0, false, true
+	}
+
+	if first == "" {
+		// "-500" asks for the last 500 bytes
+		suffix, err := strconv.ParseInt(last, 10, 64)
+		if err != nil || suffix < 0 {
+			return 0, 0, false, true
+		}
+		if suffix == 0 || info.Size == 0 {
+			return 0, 0, false, false
+		}
+		return max(info.Size-suffix, 0), info.Size - 1, true, true
+	}
+
+	start, err := strconv.ParseInt(first, 10, 64)
+	if err != nil || start < 0 {
+		return 0, 0, false, true
+	}
+	if start >= info.Size {
+		return 0, 0, false, false
+	}
+
+	end = info.Size - 1
+	if last != "" {
+		end, err = strconv.ParseInt(last, 10, 64)
+		if err != nil || end < start {
+			return 0, 0, false, true
+		}
+		end = min(end, info.Size-1)
+	}
+	return start, end, true, true
+}
+
+// ifRangeMatches reports if the range still applies, If-Range holds the etag or the modification time the client
+// has the other parts of
+func ifRangeMatches(header string, info storage.ObjectInfo, etag string) bool {
+	if header == "" {
+		return true
+	}
+	if strings.HasPrefix(header, `"`) {
+		return etag != "" && header == etag
+	}
+	date, err := http.ParseTime(header)
+	return err == nil && !info.ModifiedAt.IsZero() && info.ModifiedAt.Truncate(time.Second).Equal(date)
+}
@@ -1,45 +1,191 @@
 package mediaserver
 
 import (
-	"os"
-	"path/filepath"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
 
+	"github.com/froggy-12/purpurbase/config"
+	"github.com/froggy-12/purpurbase/services/authentication"
+	"github.com/froggy-12/purpurbase/services/storage"
+	"github.com/froggy-12/purpurbase/services/upload"
+	"github.com/froggy-12/purpurbase/store"
 	"github.com/froggy-12/purpurbase/types"
 	"github.com/gofiber/fiber/v2"
 )
 
-func ServeFiles(c *fiber.Ctx) error {
-	folder := c.Query("folder")
-	fileName := c.Query("file_name")
-	if folder == "" || fileName == "" {
-		return c.Status(fiber.StatusBadRequest).JSON(types.ErrorResponse{Error: "pass right queries please"})
+// checkAccess applies the access rule of the bucket, without ok the error response has already been sent.
+// The principal is only read for private buckets so a stale cookie never breaks public files, files of owner-only
+// buckets that belong to somebody else are reported as missing
+func checkAccess(c *fiber.Ctx, bucket config.Bucket, file types.FileRecord, sessionStore store.SessionStore, apiKeyStore store.APIKeyStore) (bool, error) {
+	if bucket.Access == "" || bucket.Access == config.PublicRead {
+		return true, nil
+	}
+
+	principal, err := authentication.ReadPrincipal(c, sessionStore, apiKeyStore)
+	if err != nil {
+		return false, c.Status(fiber.StatusUnauthorized).JSON(types.ErrorResponse{Error: err.Error()})
 	}
-	uploadDir := filepath.Join(".", "uploads", folder)
+	authentication.SetPrincipal(c, principal)
 
-	requestedFile := filepath.Join(uploadDir, fileName)
+	if bucket.Access != config.AuthenticatedRead && !upload.CanManageFile(c, file) {
+		return false, c.Status(fiber.StatusNotFound).JSON(types.ErrorResponse{Error: "File not found"})
+	}
+	return true, nil
+}
 
-	_, err := os.Stat(requestedFile)
+// servedInline reports if browsers may show files of the type in the page, only types that can't run script are
+func servedInline(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
 	if err != nil {
-		return c.Status(fiber.StatusNotFound).JSON(types.ErrorResponse{Error: "File not found"})
+		return false
 	}
+	switch {
+	case mediaType == "image/svg+xml":
+		return false
+	case mediaType == "application/pdf", mediaType == "text/plain":
+		return true
+	}
+	return strings.HasPrefix(mediaType, "image/") || strings.HasPrefix(mediaType, "audio/") || strings.HasPrefix(mediaType, "video/")
+}
 
-	return c.SendFile(requestedFile)
+// attachment is the Content-Disposition of a download, the name is quoted or encoded so it can't add parameters
+func attachment(fileName string) string {
+	return mime.FormatMediaType("attachment", map[string]string{"filename": path.Base(fileName)})
 }
 
-func DownloadFile(c *fiber.Ctx) error {
+// findFile looks up the file of ?folder=&file_name= in the storage driver, folder is the name of the bucket and its
+// access rule decides who can read it. Without ok the error response has already been sent
+func findFile(c *fiber.Ctx, fileStore store.FileStore, sessionStore store.SessionStore, apiKeyStore store.APIKeyStore) (string, storage.ObjectInfo, string, bool, error) {
 	folder := c.Query("folder")
 	fileName := c.Query("file_name")
 	if folder == "" || fileName == "" {
-		return c.Status(fiber.StatusBadRequest).JSON(types.ErrorResponse{Error: "pass right queries please"})
+		return "", storage.ObjectInfo{}, "", false, c.Status(fiber.StatusBadRequest).JSON(types.ErrorResponse{Error: "pass right queries please"})
 	}
-	uploadDir := filepath.Join(".", "uploads", folder)
 
-	requestedFile := filepath.Join(uploadDir, fileName)
+	key, err := storage.Resolve(folder, fileName)
+	if err != nil {
+		return "", storage.ObjectInfo{}, "", false, c.Status(fiber.StatusBadRequest).JSON(types.ErrorResponse{Error: "Invalid file: " + err.Error()})
+	}
+	bucket, _ := storage.FindBucket(folder)
+
+	// files uploaded before purpurbase kept metadata have no record, only admins can read them in owner-only buckets
+	record, err := fileStore.FindFileByName(folder, fileName)
+	if err != nil && err != store.ErrFileNotFound {
+		log.Println("Error reading file metadata: ", err.Error())
+		return "", storage.ObjectInfo{}, "", false, c.Status(fiber.StatusInternalServerError).JSON(types.ErrorResponse{Error: "Failed to read file"})
+	}
 
-	_, err := os.Stat(requestedFile)
+	if ok, err := checkAccess(c, bucket, record, sessionStore, apiKeyStore); !ok {
+		return "", storage.ObjectInfo{}, "", false, err
+	}
+
+	info, err := storage.Files.Stat(key)
+	if errors.Is(err, storage.ErrNotFound) || errors.Is(err, storage.ErrOutsideRoot) {
+		return "", info, "", false, c.Status(fiber.StatusNotFound).JSON(types.ErrorResponse{Error: "File not found"})
+	}
 	if err != nil {
+		log.Println("Error reading file from storage: ", err.Error())
+		return "", info, "", false, c.Status(fiber.StatusInternalServerError).JSON(types.ErrorResponse{Error: "Failed to read file"})
+	}
+
+	// the type detected on upload wins over the extension, files uploaded before it was recorded fall back to it
+	if record.ContentType != "" {
+		info.ContentType = record.ContentType
+	}
+	if info.ContentType == "" {
+		info.ContentType = mime.TypeByExtension(path.Ext(fileName))
+	}
+	if info.ContentType != "" {
+		c.Set(fiber.HeaderContentType, info.ContentType)
+	}
+	// browsers must not guess another type than the one detected on upload
+	c.Set(fiber.HeaderXContentTypeOptions, "nosniff")
+	// html, svg and anything else a browser could run would run with the cookies of the api origin, they are only
+	// handed out as downloads and sandboxed in case a browser opens them anyway
+	if !servedInline(info.ContentType) {
+		c.Set(fiber.HeaderContentDisposition, attachment(fileName))
+		c.Set(fiber.HeaderContentSecurityPolicy, "sandbox")
+	}
+	if bucket.Access != "" && bucket.Access != config.PublicRead {
+		// shared caches must not hand private files to other users
+		c.Set(fiber.HeaderCacheControl, "private")
+	}
+
+	var etag string
+	if record.Checksum != "" {
+		etag = `"` + record.Checksum + `"`
+	}
+	return key, info, etag, true, nil
+}
+
+// sendFile streams the file or the byte range the request asks for, answers conditional requests with 304 and
+// unsatisfiable ranges with 416 like c.SendFile does for files on disk
+func sendFile(c *fiber.Ctx, key string, info storage.ObjectInfo, etag string) error {
+	c.Set(fiber.HeaderAcceptRanges, "bytes")
+	if !info.ModifiedAt.IsZero() {
+		c.Set(fiber.HeaderLastModified, info.ModifiedAt.UTC().Format(http.TimeFormat))
+	}
+	if etag != "" {
+		c.Set(fiber.HeaderETag, etag)
+	}
+
+	if notModified(c, info, etag) {
+		return c.SendStatus(fiber.StatusNotModified)
+	}
+
+	var reader io.ReadCloser
+	var err error
+	length := info.Size
+	start, end, ranged, satisfiable := requestRange(c, info, etag)
+	switch {
+	case !satisfiable:
+		c.Set(fiber.HeaderContentRange, "bytes */"+strconv.FormatInt(info.Size, 10))
+		return c.Status(fiber.StatusRequestedRangeNotSatisfiable).JSON(types.ErrorResponse{Error: "Requested range is not satisfiable"})
+	case ranged:
+		length = end - start + 1
+		reader, _, err = storage.Files.GetRange(key, start, length)
+	default:
+		reader, _, err = storage.Files.Get(key)
+	}
+	if errors.Is(err, storage.ErrNotFound) || errors.Is(err, storage.ErrOutsideRoot) {
 		return c.Status(fiber.StatusNotFound).JSON(types.ErrorResponse{Error: "File not found"})
 	}
+	if err != nil {
+		log.Println("Error reading file from storage: ", err.Error())
+		return c.Status(fiber.StatusInternalServerError).JSON(types.ErrorResponse{Error: "Failed to read file"})
+	}
+
+	if ranged {
+		c.Status(fiber.StatusPartialContent)
+		c.Set(fiber.HeaderContentRange, fmt.Sprintf("bytes %d-%d/%d", start, end, info.Size))
+	}
+	// fasthttp closes the reader once it is sent
+	return c.SendStream(reader, int(length))
+}
+
+func ServeFiles(c *fiber.Ctx, fileStore store.FileStore, sessionStore store.SessionStore, apiKeyStore store.APIKeyStore) error {
+	key, info, etag, ok, err := findFile(c, fileStore, sessionStore, apiKeyStore)
+	if !ok {
+		return err
+	}
+
+	return sendFile(c, key, info, etag)
+}
+
+func DownloadFile(c *fiber.Ctx, fileStore store.FileStore, sessionStore store.SessionStore, apiKeyStore store.APIKeyStore) error {
+	key, info, etag, ok, err := findFile(c, fileStore, sessionStore, apiKeyStore)
+	if !ok {
+		return err
+	}
 
-	return c.Download(requestedFile, fileName)
+	// c.Attachment would replace the detected content type with the one of the extension
+	c.Set(fiber.HeaderContentDisposition, attachment(c.Query("file_name")))
+	return sendFile(c, key, info, etag)
 }
@@ -0,0 +1,258 @@
+package mediaserver
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/froggy-12/purpurbase/config"
+	"github.com/froggy-12/purpurbase/services/storage"
+	"github.com/froggy-12/purpurbase/store"
+	"github.com/froggy-12/purpurbase/types"
+	"github.com/gofiber/fiber/v2"
+)
+
+func newApp(t *testing.T) (*fiber.App, string) {
+	t.Helper()
+	base := t.TempDir()
+	root := filepath.Join(base, "uploads")
+	if err := os.MkdirAll(filepath.Join(root, "files"), os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "files", "hello.txt"), []byte("hello"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(base, "secret.txt"), []byte("top secret"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	previous := storage.Files
+	storage.Files = storage.NewLocal(root)
+	t.Cleanup(func() { storage.Files = previous })
+
+	fileStore := store.NewMemoryFileStore()
+	app := fiber.New()
+	app.Get("/api/get_file", func(c *fiber.Ctx) error { return ServeFiles(c, fileStore, nil, nil) })
+	app.Get("/api/download_file", func(c *fiber.Ctx) error { return DownloadFile(c, fileStore, nil, nil) })
+	return app, root
+}
+
+func request(t *testing.T, app *fiber.App, target string) (int, string) {
+	t.Helper()
+	response := requestWithHeaders(t, app, target, nil)
+	body, _ := io.ReadAll(response.Body)
+	return response.StatusCode, string(body)
+}
+
+func requestWithHeaders(t *testing.T, app *fiber.App, target string, headers map[string]string) *http.Response {
+	t.Helper()
+	req := httptest.NewRequest("GET", target, nil)
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+	response, err := app.Test(req, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return response
+}
+
+var maliciousQueries = []string{
+	"folder=..&file_name=secret.txt",
+	"folder=../..&file_name=etc/passwd",
+	"folder=files&file_name=../../secret.txt",
+	"folder=files&file_name=..%2F..%2Fsecret.txt",
+	"folder=files&file_name=%2e%2e%2f%2e%2e%2fsecret.txt",
+	"folder=files%2F..%2F..&file_name=secret.txt",
+	"folder=files&file_name=%2Fetc%2Fpasswd",
+	"folder=files&file_name=..%5C..%5Csecret.txt",
+	"folder=files&file_name=hello.txt%00.png",
+	"folder=files&file_name=.upload-1",
+	"folder=%2Fetc&file_name=passwd",
+	"folder=uploads&file_name=secret.txt",
+	"folder=.&file_name=secret.txt",
+	"folder=files&file_name=..",
+	"folder=files&file_name=.",
+}
+
+func TestServeFilesRejectsTraversal(t *testing.T) {
+	app, _ := newApp(t)
+
+	for _, route := range []string{"/api/get_file", "/api/download_file"} {
+		for _, query := range maliciousQueries {
+			code, body := request(t, app, route+"?"+query)
+			if code != fiber.StatusBadRequest && code != fiber.StatusNotFound {
+				t.Errorf("%s?%s answered %d", route, query, code)
+			}
+			if strings.Contains(body, "top secret") || strings.Contains(body, "root:") {
+				t.Errorf("%s?%s leaked %q", route, query, body)
+			}
+		}
+	}
+}
+
+func TestServeFilesRejectsSymlinksOutOfRoot(t *testing.T) {
+	app, root := newApp(t)
+	if err := os.Symlink(filepath.Join(filepath.Dir(root), "secret.txt"), filepath.Join(root, "files", "link.txt")); err != nil {
+		t.Skip("symlinks are not supported here: " + err.Error())
+	}
+	if err := os.Symlink(filepath.Dir(root), filepath.Join(root, "images")); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, query := range []string{"folder=files&file_name=link.txt", "folder=images&file_name=secret.txt"} {
+		code, body := request(t, app, "/api/get_file?"+query)
+		if code != fiber.StatusNotFound || strings.Contains(body, "top secret") {
+			t.Errorf("get_file?%s answered %d %q", query, code, body)
+		}
+	}
+}
+
+func TestServeFilesServesKnownFolders(t *testing.T) {
+	app, _ := newApp(t)
+
+	code, body := request(t, app, "/api/get_file?folder=files&file_name=hello.txt")
+	if code != fiber.StatusOK || body != "hello" {
+		t.Errorf("get_file answered %d %q", code, body)
+	}
+
+	code, body = request(t, app, "/api/download_file?folder=files&file_name=hello.txt")
+	if code != fiber.StatusOK || body != "hello" {
+		t.Errorf("download_file answered %d %q", code, body)
+	}
+
+	if code, _ := request(t, app, "/api/get_file?folder=files&file_name=missing.txt"); code != fiber.StatusNotFound {
+		t.Errorf("missing file answered %d", code)
+	}
+}
+
+func TestServeFilesOnlyShowsSafeTypesInline(t *testing.T) {
+	app, root := newApp(t)
+	files := map[string]bool{
+		"hello.txt":   true,
+		"photo.png":   true,
+		"clip.mp4":    true,
+		"paper.pdf":   true,
+		"page.html":   false,
+		"drawing.svg": false,
+		"script.js":   false,
+		"data.xml":    false,
+		"unknown":     false,
+	}
+	for name := range files {
+		if err := os.WriteFile(filepath.Join(root, "files", name), []byte("<script>alert(1)</script>"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for name, inline := range files {
+		response := requestWithHeaders(t, app, "/api/get_file?folder=files&file_name="+name, nil)
+		disposition := response.Header.Get(fiber.HeaderContentDisposition)
+		policy := response.Header.Get(fiber.HeaderContentSecurityPolicy)
+		if inline && (disposition != "" || policy != "") {
+			t.Errorf("%s was sent with %q and %q, want it inline", name, disposition, policy)
+		}
+		if !inline && (!strings.HasPrefix(disposition, "attachment") || policy != "sandbox") {
+			t.Errorf("%s was sent with %q and %q, want a sandboxed attachment", name, disposition, policy)
+		}
+	}
+}
+
+func TestServeFilesAppliesBucketAccess(t *testing.T) {
+	app, _ := newApp(t)
+	defer func() { config.Configs.Buckets = nil }()
+
+	for _, access := range []string{config.AuthenticatedRead, config.OwnerOnly} {
+		config.Configs.Buckets = map[string]config.Bucket{"files": {Access: access}}
+		for _, route := range []string{"/api/get_file", "/api/download_file"} {
+			code, body := request(t, app, route+"?folder=files&file_name=hello.txt")
+			if code != fiber.StatusUnauthorized || strings.Contains(body, "hello") {
+				t.Errorf("%s bucket answered %s without a log in with %d %q", access, route, code, body)
+			}
+		}
+	}
+
+	config.Configs.Buckets = map[string]config.Bucket{"files": {Access: config.PublicRead}}
+	if code, body := request(t, app, "/api/get_file?folder=files&file_name=hello.txt"); code != fiber.StatusOK || body != "hello" {
+		t.Errorf("public-read bucket answered %d %q", code, body)
+	}
+}
+
+func TestServeFilesAnswersRanges(t *testing.T) {
+	app, _ := newApp(t)
+
+	tests := []struct {
+		rangeHeader, body, contentRange string
+		code                            int
+	}{
+		{"bytes=1-3", "ell", "bytes 1-3/5", fiber.StatusPartialContent},
+		{"bytes=2-", "llo", "bytes 2-4/5", fiber.StatusPartialContent},
+		{"bytes=-2", "lo", "bytes 3-4/5", fiber.StatusPartialContent},
+		{"bytes=3-100", "lo", "bytes 3-4/5", fiber.StatusPartialContent},
+		{"bytes=0-0,2-3", "hello", "", fiber.StatusOK}, // several ranges get the whole file
+		{"items=0-1", "hello", "", fiber.StatusOK},
+		{"bytes=5-", "", "bytes */5", fiber.StatusRequestedRangeNotSatisfiable},
+	}
+
+	for _, route := range []string{"/api/get_file", "/api/download_file"} {
+		for _, test := range tests {
+			response := requestWithHeaders(t, app, route+"?folder=files&file_name=hello.txt", map[string]string{"Range": test.rangeHeader})
+			body, _ := io.ReadAll(response.Body)
+			if response.StatusCode != test.code || response.Header.Get("Content-Range") != test.contentRange {
+				t.Errorf("%s with %s answered %d %q, want %d %q", route, test.rangeHeader, response.StatusCode, response.Header.Get("Content-Range"), test.code, test.contentRange)
+			}
+			if test.code != fiber.StatusRequestedRangeNotSatisfiable && string(body) != test.body {
+				t.Errorf("%s with %s sent %q, want %q", route, test.rangeHeader, body, test.body)
+			}
+		}
+	}
+}
+
+func TestServeFilesAnswersConditionalRequests(t *testing.T) {
+	app, _ := newApp(t)
+	target := "/api/get_file?folder=files&file_name=hello.txt"
+
+	response := requestWithHeaders(t, app, target, nil)
+	lastModified := response.Header.Get("Last-Modified")
+	if lastModified == "" || response.Header.Get("Accept-Ranges") != "bytes" {
+		t.Fatalf("missing Last-Modified or Accept-Ranges in %v", response.Header)
+	}
+
+	if response := requestWithHeaders(t, app, target, map[string]string{"If-Modified-Since": lastModified}); response.StatusCode != fiber.StatusNotModified {
+		t.Errorf("unchanged file answered %d, want 304", response.StatusCode)
+	}
+	if response := requestWithHeaders(t, app, target, map[string]string{"If-Modified-Since": "Mon, 01 Jan 2001 00:00:00 GMT"}); response.StatusCode != fiber.StatusOK {
+		t.Errorf("changed file answered %d, want 200", response.StatusCode)
+	}
+
+	// a range for an older version of the file gets the whole file
+	response = requestWithHeaders(t, app, target, map[string]string{"Range": "bytes=0-1", "If-Range": "Mon, 01 Jan 2001 00:00:00 GMT"})
+	if body, _ := io.ReadAll(response.Body); response.StatusCode != fiber.StatusOK || string(body) != "hello" {
+		t.Errorf("outdated If-Range answered %d %q, want the whole file", response.StatusCode, body)
+	}
+}
+
+func TestDownloadFileKeepsTheDetectedType(t *testing.T) {
+	_, root := newApp(t)
+	if err := os.WriteFile(filepath.Join(root, "files", `page".png`), []byte("<script>alert(1)</script>"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	fileStore := store.NewMemoryFileStore()
+	if err := fileStore.CreateFile(types.FileRecord{ID: "1", Folder: "files", FileName: `page".png`, ContentType: "text/html; charset=utf-8"}); err != nil {
+		t.Fatal(err)
+	}
+	app := fiber.New()
+	app.Get("/api/download_file", func(c *fiber.Ctx) error { return DownloadFile(c, fileStore, nil, nil) })
+
+	response := requestWithHeaders(t, app, "/api/download_file?folder=files&file_name=page%22.png", nil)
+	if contentType := response.Header.Get(fiber.HeaderContentType); contentType != "text/html; charset=utf-8" {
+		t.Errorf("the download was sent as %q, want the detected type", contentType)
+	}
+	if disposition := response.Header.Get(fiber.HeaderContentDisposition); disposition != `attachment; filename="page\".png"` {
+		t.Errorf("the download was sent with %q", disposition)
+	}
+}
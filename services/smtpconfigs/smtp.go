@@ -4,14 +4,79 @@ import (
 	"bytes"
 	"html/template"
 	"net/smtp"
+	"time"
 
 	"github.com/froggy-12/purpurbase/config"
 )
 
 func SendVerificationEmail(emailTo, token string) error {
-	type EmailData struct {
-		Token string
-	}
+	return sendTokenEmail(emailTo, "Email verification (Purpurbase)", EmailData{
+		Title:   "Lets get your verified 🐱",
+		Heading: "Your verification token is:",
+		Token:   token,
+	})
+}
+
+func SendPasswordResetEmail(emailTo, token string, validFor time.Duration) error {
+	return sendTokenEmail(emailTo, "Password reset (Purpurbase)", EmailData{
+		Title:   "Lets get you back in 🐱",
+		Heading: "Your password reset token is:",
+		Token:   token,
+		Note:    "It is valid for " + validFor.String() + " and can only be used once. If you did not ask for it you can ignore this email.",
+	})
+}
+
+func SendMagicLinkEmail(emailTo, link, code string, validFor time.Duration) error {
+	return sendTokenEmail(emailTo, "Your log in link (Purpurbase)", EmailData{
+		Title:    "Lets get you logged in 🐱",
+		Heading:  "Your log in code is:",
+		Token:    code,
+		Link:     link,
+		LinkText: "Or click here to log in",
+		Note:     "The link and the code are valid for " + validFor.String() + " and can only be used once. If you did not ask for them you can ignore this email.",
+	})
+}
+
+func SendAccountLockedEmail(emailTo, link string, lockedFor time.Duration) error {
+	return sendTokenEmail(emailTo, "Your account has been locked (Purpurbase)", EmailData{
+		Title:    "Too many failed log ins 🐱",
+		Heading:  "Your account has been locked for " + lockedFor.String() + " after too many wrong passwords.",
+		Link:     link,
+		LinkText: "It was you? Click here to unlock it",
+		Note:     "If it wasn't you someone may be guessing your password, the account unlocks on its own and you can ignore this email.",
+	})
+}
+
+func SendEmailChangeConfirmationEmail(emailTo, link string, validFor time.Duration) error {
+	return sendTokenEmail(emailTo, "Confirm your new email (Purpurbase)", EmailData{
+		Title:    "Lets confirm your new email 🐱",
+		Heading:  "Your account asked to use this email from now on.",
+		Link:     link,
+		LinkText: "Click here to confirm the change",
+		Note:     "The link is valid for " + validFor.String() + ". If you did not ask for it you can ignore this email.",
+	})
+}
+
+func SendEmailChangeNoticeEmail(emailTo, newEmail, link string, validFor time.Duration) error {
+	return sendTokenEmail(emailTo, "Your email is being changed (Purpurbase)", EmailData{
+		Title:    "Your email is being changed 🐱",
+		Heading:  "Your account asked to change its email to " + newEmail + ".",
+		Link:     link,
+		LinkText: "It wasn't you? Click here to keep this email",
+		Note:     "The link is valid for " + validFor.String() + " and also undoes the change after it has been confirmed, it logs out every device.",
+	})
+}
+
+type EmailData struct {
+	Title    string
+	Heading  string
+	Token    string
+	Link     string
+	LinkText string
+	Note     string
+}
+
+func sendTokenEmail(emailTo, subject string, data EmailData) error {
 
 	tmpl := template.Must(template.New("email").Parse(`<!DOCTYPE html>
 <html lang="en">
@@ -20,7 +85,7 @@ func SendVerificationEmail(emailTo, token string) error {
   <meta charset="UTF-8">
   <meta http-equiv="X-UA-Compatible" content="IE=edge">
   <meta name="viewport" content="width=device-width, initial-scale=1.0">
-  <title>Lets get your verified 🐱</title>
+  <title>{{ .Title }}</title>
   <style>
     * {
       font-family: "Arial", sans-serif;
@@ -46,23 +111,24 @@ func SendVerificationEmail(emailTo, token string) error {
 <body>
   <div class="container">
     <h1>
-      Your verification token is:
+      {{ .Heading }}
     </h1>
-    <p>{{ .Token }}</p>
+    {{ if .Token }}<p>{{ .Token }}</p>{{ end }}
+    {{ if .Link }}<p><a href="{{ .Link }}">{{ .LinkText }}</a></p>{{ end }}
+    {{ if .Note }}<p>{{ .Note }}</p>{{ end }}
   </div>
 </body>
 
 </html>`))
 
 	var buffer bytes.Buffer
-	data := EmailData{Token: token}    // Create an instance of EmailData
-	err := tmpl.Execute(&buffer, data) // Pass the data instance to tmpl.Execute
+	err := tmpl.Execute(&buffer, data)
 	if err != nil {
 		return err
 	}
 
 	msg := "To: " + emailTo + "\r\n" +
-		"Subject: " + "Email verification (Purpurbase)" + "\r\n" +
+		"Subject: " + subject + "\r\n" +
 		"Content-Type: text/html; charset=UTF-8\r\n" +
 		"\r\n" +
 		buffer.String()
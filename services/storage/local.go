@@ -0,0 +1,245 @@
+package storage
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"mime"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Local keeps the files in folders below root, like purpurbase always did with ./uploads
+type Local struct {
+	root string
+}
+
+func NewLocal(root string) *Local {
+	return &Local{root: root}
+}
+
+// ErrOutsideRoot is returned for keys that lead out of the root of the local driver, through ".." or a symlink
+var ErrOutsideRoot = errors.New("file is outside of the storage root")
+
+func (s *Local) path(key string) (string, error) {
+	name := filepath.FromSlash(key)
+	if !filepath.IsLocal(name) {
+		return "", ErrOutsideRoot
+	}
+	return filepath.Join(s.root, name), nil
+}
+
+// contained reports if the existing file or folder stays below the root once every symlink is followed
+func (s *Local) contained(name string) (bool, error) {
+	root, err := filepath.EvalSymlinks(s.root)
+	if err != nil {
+		return false, err
+	}
+	resolved, err := filepath.EvalSymlinks(name)
+	if err != nil {
+		return false, err
+	}
+	relative, err := filepath.Rel(root, resolved)
+	return err == nil && (relative == "." || filepath.IsLocal(relative)), nil
+}
+
+// existing resolves the key of a file that has to exist already, missing files and files behind symlinks leading
+// out of the root are reported as not found
+func (s *Local) existing(key string) (string, error) {
+	name, err := s.path(key)
+	if err != nil {
+		return "", err
+	}
+	ok, err := s.contained(name)
+	if errors.Is(err, fs.ErrNotExist) {
+		return "", ErrNotFound
+	}
+	if err != nil {
+		return "", err
+	}
+	if !ok {
+		return "", ErrNotFound
+	}
+	return name, nil
+}
+
+func localInfo(key string, info fs.FileInfo) ObjectInfo {
+	return ObjectInfo{
+		Key:         key,
+		Size:        info.Size(),
+		ContentType: mime.TypeByExtension(path.Ext(key)),
+		ModifiedAt:  info.ModTime(),
+	}
+}
+
+// Put writes into a temporary file next to the target and renames it, readers never see half written files
+func (s *Local) Put(key string, body io.Reader, _ int64, _ string) error {
+	target, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(target), os.ModePerm); err != nil {
+		return err
+	}
+	// the folder could be a symlink pointing anywhere, the file itself is safe as the rename replaces a symlink
+	// instead of following it
+	if ok, err := s.contained(filepath.Dir(target)); err != nil || !ok {
+		if err == nil {
+			err = ErrOutsideRoot
+		}
+		return err
+	}
+
+	temp, err := os.CreateTemp(filepath.Dir(target), ".upload-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(temp.Name())
+
+	if _, err := io.Copy(temp, body); err != nil {
+		temp.Close()
+		return err
+	}
+	if err := temp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(temp.Name(), target)
+}
+
+func (s *Local) Get(key string) (io.ReadCloser, ObjectInfo, error) {
+	file, info, err := s.open(key)
+	if err != nil {
+		return nil, info, err
+	}
+	return file, info, nil
+}
+
+func (s *Local) open(key string) (*os.File, ObjectInfo, error) {
+	name, err := s.existing(key)
+	if err != nil {
+		return nil, ObjectInfo{}, err
+	}
+
+	file, err := os.Open(name)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, ObjectInfo{}, ErrNotFound
+	}
+	if err != nil {
+		return nil, ObjectInfo{}, err
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, ObjectInfo{}, err
+	}
+	if info.IsDir() {
+		file.Close()
+		return nil, ObjectInfo{}, ErrNotFound
+	}
+
+	return file, localInfo(key, info), nil
+}
+
+func (s *Local) GetRange(key string, offset, length int64) (io.ReadCloser, ObjectInfo, error) {
+	file, info, err := s.open(key)
+	if err != nil {
+		return nil, info, err
+	}
+
+	if _, err := file.Seek(offset, io.SeekStart); err != nil {
+		file.Close()
+		return nil, ObjectInfo{}, err
+	}
+	return limitedFile{Reader: io.LimitReader(file, length), Closer: file}, info, nil
+}
+
+func (s *Local) Stat(key string) (ObjectInfo, error) {
+	name, err := s.existing(key)
+	if err != nil {
+		return ObjectInfo{}, err
+	}
+
+	info, err := os.Stat(name)
+	if errors.Is(err, fs.ErrNotExist) || (err == nil && info.IsDir()) {
+		return ObjectInfo{}, ErrNotFound
+	}
+	if err != nil {
+		return ObjectInfo{}, err
+	}
+	return localInfo(key, info), nil
+}
+
+// Delete removes the file, symlinks leading out of the root are left alone
+func (s *Local) Delete(key string) error {
+	name, err := s.existing(key)
+	if err != nil {
+		return err
+	}
+
+	info, err := os.Lstat(name)
+	if err != nil {
+		return err
+	}
+	if info.IsDir() {
+		return ErrNotFound
+	}
+
+	err = os.Remove(name)
+	if errors.Is(err, fs.ErrNotExist) {
+		return ErrNotFound
+	}
+	return err
+}
+
+func (s *Local) List(prefix string) ([]ObjectInfo, error) {
+	if prefix != "" && !filepath.IsLocal(filepath.FromSlash(strings.TrimSuffix(prefix, "/"))) {
+		return nil, ErrOutsideRoot
+	}
+
+	// only the folder of the prefix has to be walked
+	dir := prefix
+	if !strings.HasSuffix(prefix, "/") {
+		dir = path.Dir(prefix)
+	}
+
+	start, err := s.path(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	// WalkDir never follows symlinks, they are skipped so nothing outside of the root gets listed
+	objects := []ObjectInfo{}
+	err = filepath.WalkDir(start, func(name string, entry fs.DirEntry, err error) error {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() || entry.Type()&fs.ModeSymlink != 0 || strings.HasPrefix(entry.Name(), ".upload-") {
+			return nil
+		}
+
+		relative, err := filepath.Rel(s.root, name)
+		if err != nil {
+			return err
+		}
+		key := filepath.ToSlash(relative)
+		if !strings.HasPrefix(key, prefix) {
+			return nil
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		objects = append(objects, localInfo(key, info))
+		return nil
+	})
+
+	return objects, err
+}
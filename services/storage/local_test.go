@@ -0,0 +1,208 @@
+package storage
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// newSandbox returns a local driver rooted in a temporary folder next to a secret file that must never be reachable
+func newSandbox(t *testing.T) (*Local, string, string) {
+	t.Helper()
+	base := t.TempDir()
+	root := filepath.Join(base, "uploads")
+	if err := os.MkdirAll(filepath.Join(root, "files"), os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+	secret := filepath.Join(base, "secret.txt")
+	if err := os.WriteFile(secret, []byte("top secret"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return NewLocal(root), root, secret
+}
+
+func symlink(t *testing.T, target, name string) {
+	t.Helper()
+	if err := os.Symlink(target, name); err != nil {
+		t.Skip("symlinks are not supported here: " + err.Error())
+	}
+}
+
+func assertSecretUntouched(t *testing.T, secret string) {
+	t.Helper()
+	data, err := os.ReadFile(secret)
+	if err != nil {
+		t.Fatalf("secret file is gone: %v", err)
+	}
+	if string(data) != "top secret" {
+		t.Fatalf("secret file was overwritten with %q", data)
+	}
+}
+
+var traversalKeys = []string{
+	"../secret.txt",
+	"files/../../secret.txt",
+	"files/../../../../../../secret.txt",
+	"/secret.txt",
+	"..",
+	"",
+}
+
+func TestLocalRejectsTraversalKeys(t *testing.T) {
+	storage, _, secret := newSandbox(t)
+
+	for _, key := range traversalKeys {
+		if _, _, err := storage.Get(key); err == nil {
+			t.Errorf("Get(%q) succeeded", key)
+		}
+		if _, err := storage.Stat(key); err == nil {
+			t.Errorf("Stat(%q) succeeded", key)
+		}
+		if err := storage.Delete(key); err == nil {
+			t.Errorf("Delete(%q) succeeded", key)
+		}
+		if err := storage.Put(key, strings.NewReader("overwritten"), 11, ""); err == nil {
+			t.Errorf("Put(%q) succeeded", key)
+		}
+		if _, err := storage.List(key); key != "" && err == nil {
+			t.Errorf("List(%q) succeeded", key)
+		}
+	}
+
+	assertSecretUntouched(t, secret)
+}
+
+func TestLocalRejectsFileSymlinksOutOfRoot(t *testing.T) {
+	storage, root, secret := newSandbox(t)
+	symlink(t, secret, filepath.Join(root, "files", "link.txt"))
+
+	if _, _, err := storage.Get("files/link.txt"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get through symlink = %v, want ErrNotFound", err)
+	}
+	if _, err := storage.Stat("files/link.txt"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Stat through symlink = %v, want ErrNotFound", err)
+	}
+	if err := storage.Delete("files/link.txt"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Delete through symlink = %v, want ErrNotFound", err)
+	}
+
+	objects, err := storage.List("files/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(objects) != 0 {
+		t.Errorf("List returned the symlink: %v", objects)
+	}
+
+	// writing replaces the link itself instead of following it
+	if err := storage.Put("files/link.txt", strings.NewReader("new"), 3, ""); err != nil {
+		t.Fatal(err)
+	}
+	assertSecretUntouched(t, secret)
+}
+
+func TestLocalRejectsFolderSymlinksOutOfRoot(t *testing.T) {
+	storage, root, secret := newSandbox(t)
+	symlink(t, filepath.Dir(secret), filepath.Join(root, "images"))
+
+	if _, _, err := storage.Get("images/secret.txt"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get through symlinked folder = %v, want ErrNotFound", err)
+	}
+	if err := storage.Delete("images/secret.txt"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Delete through symlinked folder = %v, want ErrNotFound", err)
+	}
+	if err := storage.Put("images/secret.txt", strings.NewReader("overwritten"), 11, ""); !errors.Is(err, ErrOutsideRoot) {
+		t.Errorf("Put through symlinked folder = %v, want ErrOutsideRoot", err)
+	}
+	if err := storage.Put("images/planted.txt", strings.NewReader("planted"), 7, ""); !errors.Is(err, ErrOutsideRoot) {
+		t.Errorf("Put of a new file through symlinked folder = %v, want ErrOutsideRoot", err)
+	}
+	if _, err := os.Stat(filepath.Join(filepath.Dir(secret), "planted.txt")); err == nil {
+		t.Error("a file was planted outside of the root")
+	}
+
+	objects, err := storage.List("images/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(objects) != 0 {
+		t.Errorf("List followed the symlinked folder: %v", objects)
+	}
+
+	assertSecretUntouched(t, secret)
+}
+
+func TestLocalAllowsSymlinksInsideRoot(t *testing.T) {
+	storage, root, _ := newSandbox(t)
+	if err := storage.Put("files/real.txt", strings.NewReader("hello"), 5, ""); err != nil {
+		t.Fatal(err)
+	}
+	symlink(t, filepath.Join(root, "files", "real.txt"), filepath.Join(root, "files", "alias.txt"))
+
+	reader, info, err := storage.Get("files/alias.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reader.Close()
+	data, _ := io.ReadAll(reader)
+	if string(data) != "hello" || info.Size != 5 {
+		t.Errorf("got %q of size %d", data, info.Size)
+	}
+}
+
+func TestLocalRootMayBeASymlink(t *testing.T) {
+	base := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(base, "real"), os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+	symlink(t, filepath.Join(base, "real"), filepath.Join(base, "uploads"))
+	storage := NewLocal(filepath.Join(base, "uploads"))
+
+	if err := storage.Put("files/a.txt", strings.NewReader("a"), 1, ""); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := storage.Stat("files/a.txt"); err != nil {
+		t.Fatal(err)
+	}
+	if err := storage.Delete("files/a.txt"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLocalDoesNotDeleteFolders(t *testing.T) {
+	storage, root, _ := newSandbox(t)
+
+	if err := storage.Delete("files"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Delete of a folder = %v, want ErrNotFound", err)
+	}
+	if _, err := os.Stat(filepath.Join(root, "files")); err != nil {
+		t.Error("the folder was deleted")
+	}
+}
+
+func TestLocalGetRange(t *testing.T) {
+	local, _, _ := newSandbox(t)
+	if err := local.Put("files/digits.txt", strings.NewReader("0123456789"), 10, "text/plain"); err != nil {
+		t.Fatal(err)
+	}
+
+	reader, info, err := local.GetRange("files/digits.txt", 3, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := io.ReadAll(reader)
+	reader.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "3456" || info.Size != 10 {
+		t.Errorf("got %q of a %d byte file, want \"3456\" of 10 bytes", data, info.Size)
+	}
+
+	if _, _, err := local.GetRange("files/missing.txt", 0, 1); !errors.Is(err, ErrNotFound) {
+		t.Errorf("range of a missing file returned %v, want ErrNotFound", err)
+	}
+}
@@ -0,0 +1,64 @@
+package storage
+
+import (
+	"errors"
+	"path"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/froggy-12/purpurbase/config"
+)
+
+var (
+	ErrUnknownBucket   = errors.New("unknown bucket")
+	ErrInvalidFileName = errors.New("invalid file name")
+)
+
+// maxFileNameLength is the longest name most file systems accept
+const maxFileNameLength = 255
+
+// FindBucket returns the bucket of the name out of buckets in the configuration, or out of config.DefaultBuckets
+// when none are configured
+func FindBucket(name string) (config.Bucket, bool) {
+	buckets := config.Configs.Buckets
+	if len(buckets) == 0 {
+		buckets = config.DefaultBuckets()
+	}
+	bucket, ok := buckets[name]
+	return bucket, ok
+}
+
+// Resolve turns the bucket and file name of a request into a storage key. Only known buckets and plain file names
+// are accepted, anything that could climb out of its folder like "..", slashes or hidden files is refused
+func Resolve(bucketName, fileName string) (string, error) {
+	bucket, ok := FindBucket(bucketName)
+	if !ok {
+		return "", ErrUnknownBucket
+	}
+	if err := checkFileName(fileName); err != nil {
+		return "", err
+	}
+
+	folder := bucketName
+	if bucket.Folder != "" {
+		folder = bucket.Folder
+	}
+	return path.Join(folder, fileName), nil
+}
+
+func checkFileName(fileName string) error {
+	if fileName == "" || len(fileName) > maxFileNameLength || !utf8.ValidString(fileName) {
+		return ErrInvalidFileName
+	}
+	// a leading dot covers "." and ".." as well as hidden files like the temporary files of the local driver
+	if strings.HasPrefix(fileName, ".") || strings.ContainsAny(fileName, `/\:`) {
+		return ErrInvalidFileName
+	}
+	for _, r := range fileName {
+		if unicode.IsControl(r) {
+			return ErrInvalidFileName
+		}
+	}
+	return nil
+}
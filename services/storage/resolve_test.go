@@ -0,0 +1,133 @@
+package storage
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/froggy-12/purpurbase/config"
+)
+
+func TestResolveAcceptsPlainNames(t *testing.T) {
+	tests := []struct {
+		bucket, fileName, key string
+	}{
+		{"images", "0b3c6a1e-5d4f-4a8e-9c61-2f1d7e8a9b0c.png", "images/0b3c6a1e-5d4f-4a8e-9c61-2f1d7e8a9b0c.png"},
+		{"musics", "song.mp3", "musics/song.mp3"},
+		{"videos", "clip", "videos/clip"},
+		{"files", "report..pdf", "files/report..pdf"},
+		{"files", "name with spaces.txt", "files/name with spaces.txt"},
+		{"files", "ünïcödé.txt", "files/ünïcödé.txt"},
+		{"files", "%2e%2e", "files/%2e%2e"}, // query values are decoded once, what is left is a literal name
+		{"files", strings.Repeat("a", maxFileNameLength), "files/" + strings.Repeat("a", maxFileNameLength)},
+	}
+
+	for _, test := range tests {
+		key, err := Resolve(test.bucket, test.fileName)
+		if err != nil {
+			t.Errorf("Resolve(%q, %q) failed: %v", test.bucket, test.fileName, err)
+			continue
+		}
+		if key != test.key {
+			t.Errorf("Resolve(%q, %q) = %q, want %q", test.bucket, test.fileName, key, test.key)
+		}
+	}
+}
+
+func TestResolveRejectsUnknownBuckets(t *testing.T) {
+	buckets := []string{
+		"",
+		".",
+		"..",
+		"../..",
+		"../uploads",
+		"images/..",
+		"images/../..",
+		"/etc",
+		"/",
+		"images/",
+		"./images",
+		"Images",
+		"images\x00",
+		`..\..`,
+		"%2e%2e",
+		"uploads",
+		"temp-123",
+	}
+
+	for _, bucket := range buckets {
+		if _, err := Resolve(bucket, "file.txt"); !errors.Is(err, ErrUnknownBucket) {
+			t.Errorf("Resolve(%q, \"file.txt\") = %v, want ErrUnknownBucket", bucket, err)
+		}
+	}
+}
+
+func TestResolveRejectsMaliciousFileNames(t *testing.T) {
+	fileNames := []string{
+		"",
+		".",
+		"..",
+		"../secret",
+		"../../etc/passwd",
+		"../../../../../../../../etc/shadow",
+		"./file.txt",
+		"/etc/passwd",
+		"a/../../b",
+		"sub/file.txt",
+		"file.txt/",
+		`..\..\windows\win.ini`,
+		`sub\file.txt`,
+		`C:\boot.ini`,
+		"C:file.txt",
+		"file.txt\x00.png",
+		"\x00",
+		"file\n.txt",
+		"file\r\n.txt",
+		"file\t.txt",
+		"file\x7f.txt",
+		"file\u0085.txt",
+		".hidden",
+		".upload-123456",
+		".htaccess",
+		"...",
+		"\xff\xfe.txt",
+		strings.Repeat("a", maxFileNameLength+1),
+	}
+
+	for _, fileName := range fileNames {
+		if _, err := Resolve("files", fileName); !errors.Is(err, ErrInvalidFileName) {
+			t.Errorf("Resolve(\"files\", %q) = %v, want ErrInvalidFileName", fileName, err)
+		}
+	}
+}
+
+func TestResolveUsesBucketFolders(t *testing.T) {
+	config.Configs.Buckets = map[string]config.Bucket{"avatars": {Folder: "images/avatars"}}
+	defer func() { config.Configs.Buckets = nil }()
+
+	key, err := Resolve("avatars", "me.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if key != "images/avatars/me.png" {
+		t.Errorf("got key %q, want images/avatars/me.png", key)
+	}
+}
+
+func TestResolveOnlyKnowsConfiguredBuckets(t *testing.T) {
+	config.Configs.Buckets = map[string]config.Bucket{"private": {Access: config.OwnerOnly}}
+	defer func() { config.Configs.Buckets = nil }()
+
+	key, err := Resolve("private", "notes.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if key != "private/notes.txt" {
+		t.Errorf("got key %q, want private/notes.txt", key)
+	}
+
+	// configured buckets replace the default ones
+	if _, err := Resolve("images", "me.png"); !errors.Is(err, ErrUnknownBucket) {
+		t.Errorf("Resolve(\"images\", \"me.png\") = %v, want ErrUnknownBucket", err)
+	}
+}
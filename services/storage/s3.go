@@ -0,0 +1,312 @@
+package storage
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/froggy-12/purpurbase/config"
+)
+
+// emptyPayloadHash is the sha256 of an empty body, every request without one is signed with it
+const emptyPayloadHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+// S3 talks to aws s3 or anything speaking its api like minio, requests are signed with aws signature version 4
+type S3 struct {
+	endpoint  *url.URL
+	region    string
+	bucket    string
+	accessKey string
+	secretKey string
+	pathStyle bool
+	client    *http.Client
+}
+
+func NewS3(configs config.S3Storage) (*S3, error) {
+	if configs.Bucket == "" {
+		return nil, errors.New("no bucket provided for s3 storage")
+	}
+	if configs.AccessKeyID == "" || configs.SecretAccessKey == "" {
+		return nil, errors.New("no access key provided for s3 storage")
+	}
+
+	endpoint, err := url.Parse(configs.Endpoint)
+	if err != nil || endpoint.Host == "" {
+		return nil, errors.New("invalid s3 endpoint " + configs.Endpoint)
+	}
+
+	region := configs.Region
+	if region == "" {
+		region = "us-east-1"
+	}
+
+	return &S3{
+		endpoint:  endpoint,
+		region:    region,
+		bucket:    configs.Bucket,
+		accessKey: configs.AccessKeyID,
+		secretKey: configs.SecretAccessKey,
+		pathStyle: configs.PathStyle,
+		client:    &http.Client{Timeout: 10 * time.Minute},
+	}, nil
+}
+
+// s3Error is the xml body of a failed request
+type s3Error struct {
+	Code    string `xml:"Code"`
+	Message string `xml:"Message"`
+}
+
+func (s *S3) objectURL(key string, query url.Values) *url.URL {
+	target := *s.endpoint
+	objectPath := "/" + key
+	if s.pathStyle {
+		objectPath = "/" + s.bucket + objectPath
+	} else {
+		target.Host = s.bucket + "." + target.Host
+	}
+
+	target.Path = strings.TrimSuffix(target.Path, "/") + objectPath
+	target.RawPath = strings.TrimSuffix(s.endpoint.EscapedPath(), "/") + uriEncode(objectPath, false)
+	target.RawQuery = canonicalQuery(query)
+	return &target
+}
+
+func (s *S3) do(method, key string, query url.Values, header http.Header, body io.Reader, size int64, contentType string) (*http.Response, error) {
+	request, err := http.NewRequest(method, s.objectURL(key, query).String(), body)
+	if err != nil {
+		return nil, err
+	}
+	for name, values := range header {
+		request.Header[name] = values
+	}
+
+	payloadHash := emptyPayloadHash
+	if body != nil {
+		// the body is streamed so it can't be hashed up front, s3 accepts that over the signed headers
+		payloadHash = "UNSIGNED-PAYLOAD"
+		request.ContentLength = size
+		if contentType != "" {
+			request.Header.Set("Content-Type", contentType)
+		}
+	}
+	s.sign(request, payloadHash, time.Now().UTC())
+
+	response, err := s.client.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	if response.StatusCode == http.StatusNotFound {
+		response.Body.Close()
+		return nil, ErrNotFound
+	}
+	if response.StatusCode >= 300 {
+		defer response.Body.Close()
+		var failure s3Error
+		xml.NewDecoder(io.LimitReader(response.Body, 64*1024)).Decode(&failure)
+		return nil, fmt.Errorf("s3 %s %s failed with %d %s: %s", method, key, response.StatusCode, failure.Code, failure.Message)
+	}
+
+	return response, nil
+}
+
+func responseInfo(key string, response *http.Response) ObjectInfo {
+	modifiedAt, _ := http.ParseTime(response.Header.Get("Last-Modified"))
+	return ObjectInfo{
+		Key:         key,
+		Size:        response.ContentLength,
+		ContentType: response.Header.Get("Content-Type"),
+		ModifiedAt:  modifiedAt,
+	}
+}
+
+func (s *S3) Put(key string, body io.Reader, size int64, contentType string) error {
+	response, err := s.do(http.MethodPut, key, nil, nil, body, size, contentType)
+	if err != nil {
+		return err
+	}
+	return response.Body.Close()
+}
+
+func (s *S3) Get(key string) (io.ReadCloser, ObjectInfo, error) {
+	response, err := s.do(http.MethodGet, key, nil, nil, nil, 0, "")
+	if err != nil {
+		return nil, ObjectInfo{}, err
+	}
+	return response.Body, responseInfo(key, response), nil
+}
+
+// GetRange asks s3 for the bytes only, the size of the whole file is read from Content-Range
+func (s *S3) GetRange(key string, offset, length int64) (io.ReadCloser, ObjectInfo, error) {
+	header := http.Header{"Range": {fmt.Sprintf("bytes=%d-%d", offset, offset+length-1)}}
+	response, err := s.do(http.MethodGet, key, nil, header, nil, 0, "")
+	if err != nil {
+		return nil, ObjectInfo{}, err
+	}
+
+	info := responseInfo(key, response)
+	if _, total, ok := strings.Cut(response.Header.Get("Content-Range"), "/"); ok {
+		if size, err := strconv.ParseInt(total, 10, 64); err == nil {
+			info.Size = size
+		}
+	}
+	if response.StatusCode != http.StatusPartialContent && offset > 0 {
+		// the range was ignored, the bytes before it are skipped
+		if _, err := io.CopyN(io.Discard, response.Body, offset); err != nil {
+			response.Body.Close()
+			return nil, ObjectInfo{}, err
+		}
+	}
+	return limitedFile{Reader: io.LimitReader(response.Body, length), Closer: response.Body}, info, nil
+}
+
+func (s *S3) Stat(key string) (ObjectInfo, error) {
+	response, err := s.do(http.MethodHead, key, nil, nil, nil, 0, "")
+	if err != nil {
+		return ObjectInfo{}, err
+	}
+	response.Body.Close()
+	return responseInfo(key, response), nil
+}
+
+// Delete checks the file first, s3 answers deletes of missing keys with success
+func (s *S3) Delete(key string) error {
+	if _, err := s.Stat(key); err != nil {
+		return err
+	}
+
+	response, err := s.do(http.MethodDelete, key, nil, nil, nil, 0, "")
+	if err != nil {
+		return err
+	}
+	return response.Body.Close()
+}
+
+type listBucketResult struct {
+	Contents []struct {
+		Key          string    `xml:"Key"`
+		Size         int64     `xml:"Size"`
+		LastModified time.Time `xml:"LastModified"`
+	} `xml:"Contents"`
+	IsTruncated           bool   `xml:"IsTruncated"`
+	NextContinuationToken string `xml:"NextContinuationToken"`
+}
+
+func (s *S3) List(prefix string) ([]ObjectInfo, error) {
+	objects := []ObjectInfo{}
+	query := url.Values{"list-type": {"2"}, "prefix": {prefix}}
+
+	for {
+		response, err := s.do(http.MethodGet, "", query, nil, nil, 0, "")
+		if err != nil {
+			return nil, err
+		}
+
+		var page listBucketResult
+		err = xml.NewDecoder(response.Body).Decode(&page)
+		response.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+
+		for _, object := range page.Contents {
+			objects = append(objects, ObjectInfo{Key: object.Key, Size: object.Size, ModifiedAt: object.LastModified})
+		}
+		if !page.IsTruncated || page.NextContinuationToken == "" {
+			return objects, nil
+		}
+		query.Set("continuation-token", page.NextContinuationToken)
+	}
+}
+
+// sign adds the aws signature version 4 headers, see
+// https://docs.aws.amazon.com/IAM/latest/UserGuide/reference_sigv-create-signed-request.html
+func (s *S3) sign(request *http.Request, payloadHash string, now time.Time) {
+	amzDate := now.Format("20060102T150405Z")
+	day := now.Format("20060102")
+
+	request.Header.Set("X-Amz-Date", amzDate)
+	request.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	// headers are listed in alphabetical order, a range is signed too so it can't be swapped on the way
+	signedHeaders := "host;x-amz-content-sha256;x-amz-date"
+	canonicalHeaders := "host:" + request.URL.Host + "\n"
+	if byteRange := request.Header.Get("Range"); byteRange != "" {
+		signedHeaders = "host;range;x-amz-content-sha256;x-amz-date"
+		canonicalHeaders += "range:" + strings.TrimSpace(byteRange) + "\n"
+	}
+	canonicalHeaders += "x-amz-content-sha256:" + payloadHash + "\n" +
+		"x-amz-date:" + amzDate + "\n"
+
+	canonicalRequest := strings.Join([]string{
+		request.Method,
+		request.URL.EscapedPath(),
+		request.URL.RawQuery,
+		canonicalHeaders,
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := day + "/" + s.region + "/s3/aws4_request"
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(requestHash[:])
+
+	key := hmacSHA256([]byte("AWS4"+s.secretKey), day)
+	key = hmacSHA256(key, s.region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	request.Header.Set("Authorization", "AWS4-HMAC-SHA256 Credential="+s.accessKey+"/"+scope+", SignedHeaders="+signedHeaders+", Signature="+signature)
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// canonicalQuery sorts and encodes the query the way signature version 4 expects it
+func canonicalQuery(query url.Values) string {
+	keys := make([]string, 0, len(query))
+	for key := range query {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var parts []string
+	for _, key := range keys {
+		values := append([]string(nil), query[key]...)
+		sort.Strings(values)
+		for _, value := range values {
+			parts = append(parts, uriEncode(key, true)+"="+uriEncode(value, true))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+// uriEncode escapes everything but the unreserved characters, slashes stay unless encodeSlash is set
+func uriEncode(value string, encodeSlash bool) string {
+	var encoded strings.Builder
+	for _, b := range []byte(value) {
+		switch {
+		case 'A' <= b && b <= 'Z', 'a' <= b && b <= 'z', '0' <= b && b <= '9', b == '-', b == '.', b == '_', b == '~':
+			encoded.WriteByte(b)
+		case b == '/' && !encodeSlash:
+			encoded.WriteByte(b)
+		default:
+			fmt.Fprintf(&encoded, "%%%02X", b)
+		}
+	}
+	return encoded.String()
+}
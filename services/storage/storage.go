@@ -0,0 +1,58 @@
+package storage
+
+import (
+	"errors"
+	"io"
+	"time"
+
+	"github.com/froggy-12/purpurbase/config"
+)
+
+var ErrNotFound = errors.New("file not found")
+
+// Storage keeps the uploaded files, keys look like "<folder>/<file name>" with forward slashes on every driver
+type Storage interface {
+	// Put stores the body under the key, size is -1 when it isn't known
+	Put(key string, body io.Reader, size int64, contentType string) error
+	// Get streams the file, the caller closes it
+	Get(key string) (io.ReadCloser, ObjectInfo, error)
+	// GetRange streams length bytes of the file starting at offset, the caller closes it. The info describes the
+	// whole file
+	GetRange(key string, offset, length int64) (io.ReadCloser, ObjectInfo, error)
+	Stat(key string) (ObjectInfo, error)
+	Delete(key string) error
+	// List returns every file whose key starts with the prefix
+	List(prefix string) ([]ObjectInfo, error)
+}
+
+type ObjectInfo struct {
+	Key         string
+	Size        int64
+	ContentType string
+	ModifiedAt  time.Time
+}
+
+// limitedFile closes the file or response behind the limited reader of a range
+type limitedFile struct {
+	io.Reader
+	io.Closer
+}
+
+// Files is where uploads go, it is replaced on start up by the driver of storageConfigs
+var Files Storage = NewLocal("./uploads")
+
+// New returns the driver of the configuration, local disk unless s3 is chosen
+func New(configs config.StorageConfigurations) (Storage, error) {
+	switch configs.Driver {
+	case "", "local":
+		root := configs.LocalPath
+		if root == "" {
+			root = "./uploads"
+		}
+		return NewLocal(root), nil
+	case "s3":
+		return NewS3(configs.S3)
+	default:
+		return nil, errors.New("unsupported storage driver " + configs.Driver)
+	}
+}
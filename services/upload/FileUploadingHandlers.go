@@ -1,136 +1,71 @@
 package upload
 
 import (
-	"fmt"
-	"log"
-	"os"
-	"path/filepath"
+	"errors"
+	"mime/multipart"
 
+	"github.com/froggy-12/purpurbase/config"
+	"github.com/froggy-12/purpurbase/services/authentication"
+	"github.com/froggy-12/purpurbase/services/storage"
+	"github.com/froggy-12/purpurbase/store"
 	"github.com/froggy-12/purpurbase/types"
 	"github.com/froggy-12/purpurbase/utils"
 	"github.com/gofiber/fiber/v2"
-	"github.com/google/uuid"
 )
 
-func HandleUploadImageFile(c *fiber.Ctx) error {
-	form, err := c.MultipartForm()
-	if err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(types.ErrorResponse{Error: "Invalid request it should be multipart form"})
-	}
-	file := form.File["file"][0]
-
-	if file == nil {
-		return c.Status(fiber.StatusBadRequest).JSON(types.ErrorResponse{Error: "fo files found"})
-	}
-
-	if !utils.IsImage(file.Filename) {
-		return c.Status(fiber.StatusBadRequest).JSON(types.ErrorResponse{Error: "Only Image files can be accepted on this route"})
+// uploadBucket finds the bucket files are uploaded into, without ok the error response has already been sent
+func uploadBucket(c *fiber.Ctx, bucketName string) (config.Bucket, bool, error) {
+	bucket, ok := storage.FindBucket(bucketName)
+	if !ok {
+		return bucket, false, c.Status(fiber.StatusNotFound).JSON(types.ErrorResponse{Error: "No bucket named " + bucketName})
 	}
 
-	uploadsDir := filepath.Join(".", "uploads/images")
-	err = os.MkdirAll(uploadsDir, os.ModePerm)
-	if err != nil {
-		log.Println("Error creating folder: ", err.Error())
-		return c.Status(fiber.StatusInternalServerError).JSON(types.ErrorResponse{Error: "Internal server error"})
+	// nobody could read the files of a private bucket back when they had no uploader
+	if bucket.Access != "" && bucket.Access != config.PublicRead {
+		if _, ok := authentication.CurrentPrincipal(c); !ok {
+			return bucket, false, c.Status(fiber.StatusUnauthorized).JSON(types.ErrorResponse{Error: "Log in to upload into the " + bucketName + " bucket"})
+		}
 	}
 
-	// Generate a UUID for the filename
-	uuidFilename := uuid.New().String() + filepath.Ext(file.Filename)
+	return bucket, true, nil
+}
 
-	err = c.SaveFile(file, filepath.Join(uploadsDir, uuidFilename))
-	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(types.ErrorResponse{Error: "Failed to upload file"})
+// uploadSingleFile stores the "file" field of the form in the bucket after checking it against the rules of the bucket
+func uploadSingleFile(c *fiber.Ctx, fileStore store.FileStore, bucketName string) error {
+	bucket, ok, err := uploadBucket(c, bucketName)
+	if !ok {
+		return err
 	}
 
-	return c.JSON(types.SingleFileUploadedSuccessResponse{FileName: uuidFilename, Message: "File Upload Successfull"})
-}
-
-func HandleUploadMultipleImageFile(c *fiber.Ctx) error {
 	form, err := c.MultipartForm()
 	if err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(types.ErrorResponse{Error: "Invalid request it should be multipart form"})
 	}
-	files := form.File["file"]
-
-	if len(files) == 0 {
-		return c.Status(fiber.StatusBadRequest).JSON(types.ErrorResponse{Error: "fo files found"})
+	if len(form.File["file"]) == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(types.ErrorResponse{Error: "no files found"})
 	}
+	file := form.File["file"][0]
 
-	var uploadedFiles []string
-	var tempFiles []string
-
-	uploadsDir := filepath.Join(".", "uploads/images")
-	err = os.MkdirAll(uploadsDir, os.ModePerm)
-	if err != nil {
-		log.Println("Error creating folder: ", err.Error())
-		return c.Status(fiber.StatusInternalServerError).JSON(types.ErrorResponse{Error: "Internal server error"})
+	contentTypes, ok, err := checkFiles(c, []*multipart.FileHeader{file}, bucket.UploadRule)
+	if !ok {
+		return err
 	}
 
-	// Create a temporary folder for storing files
-	tempDir, err := os.MkdirTemp(filepath.Dir(uploadsDir), "temp-")
+	record, err := storeFile(c, fileStore, file, bucketName, contentTypes[0])
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(types.ErrorResponse{Error: "Failed to create temporary folder"})
-	}
-	defer os.RemoveAll(tempDir)
-
-	for _, file := range files {
-		if !utils.IsImage(file.Filename) {
-			for _, tempFile := range tempFiles {
-				os.Remove(tempFile)
-			}
-			return c.Status(fiber.StatusBadRequest).JSON(types.ErrorResponse{Error: "Only Image files can be accepted on this route"})
-		}
-
-		uuidFilename := uuid.New().String() + filepath.Ext(file.Filename)
-		tempFile := filepath.Join(tempDir, uuidFilename)
-		err = c.SaveFile(file, tempFile)
-		if err != nil {
-			for _, tempFile := range tempFiles {
-				os.Remove(tempFile)
-			}
-			return c.Status(fiber.StatusInternalServerError).JSON(types.ErrorResponse{Error: "Failed to upload image files"})
-		}
-
-		tempFiles = append(tempFiles, tempFile)
-		uploadedFiles = append(uploadedFiles, uuidFilename)
-	}
-
-	// Move files from temporary folder to final storage location
-	for _, tempFile := range tempFiles {
-		err = os.Rename(tempFile, filepath.Join(uploadsDir, filepath.Base(tempFile)))
-		if err != nil {
-			log.Println("Error moving file to final storage location: ", err.Error())
-			return c.Status(500).JSON(types.ErrorResponse{Error: "Failed to upload image files"})
-		}
+		return c.Status(fiber.StatusInternalServerError).JSON(types.ErrorResponse{Error: "Failed to upload file"})
 	}
 
-	return c.JSON(types.MultipleFileUploadedSuccessResponse{FileNames: uploadedFiles, Message: "Image Files Upload Successfull"})
+	return c.JSON(types.SingleFileUploadedSuccessResponse{FileName: record.FileName, Message: "File Upload Successfull", File: &record})
 }
 
-func HandleUploadSingleMusicFile(c *fiber.Ctx) error {
-	form, err := c.MultipartForm()
-	if err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(types.ErrorResponse{Error: "Invalid request it should be multipart form"})
-	}
-	file := form.File["file"][0]
-
-	if file == nil {
-		return c.Status(fiber.StatusBadRequest).JSON(types.ErrorResponse{Error: "fo files found"})
-	}
-
-	if !utils.IsMusic(file.Filename) {
-		return c.Status(fiber.StatusBadRequest).JSON(types.ErrorResponse{Error: "Only music files can be accepted on this route"})
-	}
-
-	uuidFilename, err := utils.UploadFile(c, file, "musics")
-	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(types.ErrorResponse{Error: "Failed to upload music file"})
+// uploadMultipleFiles stores every "file" field of the form in the bucket, or none of them when one breaks its rules
+func uploadMultipleFiles(c *fiber.Ctx, fileStore store.FileStore, bucketName string) error {
+	bucket, ok, err := uploadBucket(c, bucketName)
+	if !ok {
+		return err
 	}
 
-	return c.JSON(types.SingleFileUploadedSuccessResponse{FileName: uuidFilename, Message: "Music File Upload Successfull"})
-}
-
-func HandleUploadMultipleMusicFile(c *fiber.Ctx) error {
 	form, err := c.MultipartForm()
 	if err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(types.ErrorResponse{Error: "Invalid request it should be multipart form"})
@@ -138,154 +73,98 @@ func HandleUploadMultipleMusicFile(c *fiber.Ctx) error {
 	files := form.File["file"]
 
 	if len(files) == 0 {
-		return c.Status(fiber.StatusBadRequest).JSON(types.ErrorResponse{Error: "fo files found"})
+		return c.Status(fiber.StatusBadRequest).JSON(types.ErrorResponse{Error: "no files found"})
 	}
 
-	var uploadedFiles []string
-	var tempFiles []string
-
-	for _, file := range files {
-		if !utils.IsMusic(file.Filename) {
-			for _, tempFile := range tempFiles {
-				os.Remove(tempFile)
-			}
-			return c.Status(fiber.StatusBadRequest).JSON(types.ErrorResponse{Error: "Only music files can be accepted on this route"})
-		}
-
-		uuidFilename, err := utils.UploadFile(c, file, "musics")
-		if err != nil {
-			for _, tempFile := range tempFiles {
-				os.Remove(tempFile)
-			}
-			return c.Status(fiber.StatusInternalServerError).JSON(types.ErrorResponse{Error: "Failed to upload music files"})
-		}
-
-		tempFiles = append(tempFiles, filepath.Join("uploads/musics", uuidFilename))
-		uploadedFiles = append(uploadedFiles, uuidFilename)
+	contentTypes, ok, err := checkFiles(c, files, bucket.UploadRule)
+	if !ok {
+		return err
 	}
 
-	return c.JSON(types.MultipleFileUploadedSuccessResponse{FileNames: uploadedFiles, Message: "Music Files Upload Successfull"})
-}
-
-func HandleUploadSingleVideoFile(c *fiber.Ctx) error {
-	form, err := c.MultipartForm()
+	uploadedFiles, err := uploadFiles(c, fileStore, files, bucketName, contentTypes)
 	if err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(types.ErrorResponse{Error: "Invalid request it should be multipart form"})
+		return c.Status(fiber.StatusInternalServerError).JSON(types.ErrorResponse{Error: "Failed to upload files"})
 	}
-	file := form.File["file"][0]
 
-	if file == nil {
-		return c.Status(fiber.StatusBadRequest).JSON(types.ErrorResponse{Error: "fo files found"})
-	}
+	return c.JSON(types.MultipleFileUploadedSuccessResponse{FileNames: fileNames(uploadedFiles), Message: "Files Upload Successfull", Files: uploadedFiles})
+}
 
-	if !utils.IsVideo(file.Filename) {
-		return c.Status(fiber.StatusBadRequest).JSON(types.ErrorResponse{Error: "Only video files can be accepted on this route"})
-	}
+// HandleUploadBucketFile uploads a file into the bucket of the :bucket param
+func HandleUploadBucketFile(c *fiber.Ctx, fileStore store.FileStore) error {
+	return uploadSingleFile(c, fileStore, c.Params("bucket"))
+}
 
-	uuidFilename, err := utils.UploadFile(c, file, "videos")
-	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(types.ErrorResponse{Error: "Failed to upload video file"})
-	}
+// HandleUploadMultipleBucketFiles uploads files into the bucket of the :bucket param
+func HandleUploadMultipleBucketFiles(c *fiber.Ctx, fileStore store.FileStore) error {
+	return uploadMultipleFiles(c, fileStore, c.Params("bucket"))
+}
 
-	return c.JSON(types.SingleFileUploadedSuccessResponse{FileName: uuidFilename, Message: "Video File Upload Successfull"})
+func HandleUploadImageFile(c *fiber.Ctx, fileStore store.FileStore) error {
+	return uploadSingleFile(c, fileStore, "images")
 }
 
-func HandleUploadMultiVideoFile(c *fiber.Ctx) error {
-	form, err := c.MultipartForm()
-	if err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(types.ErrorResponse{Error: "Invalid request it should be multipart form"})
-	}
-	files := form.File["file"]
+func HandleUploadMultipleImageFile(c *fiber.Ctx, fileStore store.FileStore) error {
+	return uploadMultipleFiles(c, fileStore, "images")
+}
 
-	if len(files) == 0 {
-		return c.Status(fiber.StatusBadRequest).JSON(types.ErrorResponse{Error: "fo files found"})
-	}
+func HandleUploadSingleMusicFile(c *fiber.Ctx, fileStore store.FileStore) error {
+	return uploadSingleFile(c, fileStore, "musics")
+}
 
-	var uploadedFiles []string
-	var tempFiles []string
+func HandleUploadMultipleMusicFile(c *fiber.Ctx, fileStore store.FileStore) error {
+	return uploadMultipleFiles(c, fileStore, "musics")
+}
 
-	for _, file := range files {
-		if !utils.IsVideo(file.Filename) {
-			for _, tempFile := range tempFiles {
-				os.Remove(tempFile)
-			}
-			return c.Status(fiber.StatusBadRequest).JSON(types.ErrorResponse{Error: "Only video files can be accepted on this route"})
-		}
+func HandleUploadSingleVideoFile(c *fiber.Ctx, fileStore store.FileStore) error {
+	return uploadSingleFile(c, fileStore, "videos")
+}
 
-		uuidFilename, err := utils.UploadFile(c, file, "videos")
-		if err != nil {
-			for _, tempFile := range tempFiles {
-				os.Remove(tempFile)
-			}
-			return c.Status(fiber.StatusInternalServerError).JSON(types.ErrorResponse{Error: "Failed to upload video files"})
-		}
+func HandleUploadMultiVideoFile(c *fiber.Ctx, fileStore store.FileStore) error {
+	return uploadMultipleFiles(c, fileStore, "videos")
+}
 
-		tempFiles = append(tempFiles, filepath.Join("uploads/videos", uuidFilename))
-		uploadedFiles = append(uploadedFiles, uuidFilename)
-	}
+func HandleAnyFormatSingleFile(c *fiber.Ctx, fileStore store.FileStore) error {
+	return uploadSingleFile(c, fileStore, "files")
+}
 
-	return c.JSON(types.MultipleFileUploadedSuccessResponse{FileNames: uploadedFiles, Message: "Video Files Upload Successfull"})
+func HandleAnyFormatMultiFile(c *fiber.Ctx, fileStore store.FileStore) error {
+	return uploadMultipleFiles(c, fileStore, "files")
 }
 
-func HandleAnyFormatSingleFile(c *fiber.Ctx) error {
-	form, err := c.MultipartForm()
-	if err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(types.ErrorResponse{Error: "Invalid request it should be multipart form"})
-	}
-	file := form.File["file"][0]
+// HandleDeleteFile deletes the file of ?folder=&filename=, only its uploader and admins can delete it.
+// Files uploaded anonymously or before purpurbase kept metadata can only be deleted by admins
+func HandleDeleteFile(c *fiber.Ctx, fileStore store.FileStore) error {
+	filename := c.Query("filename")
+	folder := c.Query("folder")
 
-	if file == nil {
-		return c.Status(fiber.StatusBadRequest).JSON(types.ErrorResponse{Error: "fo files found"})
+	if filename == "" || folder == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(types.ErrorResponse{Error: "filename and folder are required"})
 	}
 
-	uuid := uuid.New()
-	uuidFilename := fmt.Sprintf("%s%s", uuid, filepath.Ext(file.Filename))
-
-	err = utils.UploadAnyFile(c, file, "files", uuidFilename)
-	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(types.ErrorResponse{Error: "Failed to upload file"})
+	if _, err := storage.Resolve(folder, filename); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(types.ErrorResponse{Error: "Invalid file: " + err.Error()})
 	}
 
-	return c.JSON(types.SingleFileUploadedSuccessResponse{FileName: uuidFilename, Message: "File Upload Successfull"})
-}
-
-func HandleAnyFormatMultiFile(c *fiber.Ctx) error {
-	form, err := c.MultipartForm()
-	if err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(types.ErrorResponse{Error: "Invalid request it should be multipart form"})
+	if _, ok := authentication.CurrentPrincipal(c); !ok {
+		return c.Status(fiber.StatusUnauthorized).JSON(types.ErrorResponse{Error: authentication.ErrNotAuthenticated.Error()})
 	}
-	files := form.File["file"]
 
-	if len(files) == 0 {
-		return c.Status(fiber.StatusBadRequest).JSON(types.ErrorResponse{Error: "no files found"})
+	file, err := fileStore.FindFileByName(folder, filename)
+	if err != nil && err != store.ErrFileNotFound {
+		return c.Status(fiber.StatusInternalServerError).JSON(types.ErrorResponse{Error: "Something went wrong: " + err.Error()})
 	}
-
-	var uploadedFiles []string
-
-	for _, file := range files {
-		uuid := uuid.New()
-		uuidFilename := fmt.Sprintf("%s%s", uuid, filepath.Ext(file.Filename))
-
-		err = utils.UploadAnyFile(c, file, "files", uuidFilename)
-		if err != nil {
-			return c.Status(fiber.StatusInternalServerError).JSON(types.ErrorResponse{Error: "Failed to upload files"})
-		}
-
-		uploadedFiles = append(uploadedFiles, uuidFilename)
+	if !CanManageFile(c, file) {
+		return c.Status(fiber.StatusForbidden).JSON(types.ErrorResponse{Error: "Only the uploader of the file or an admin can delete it"})
 	}
 
-	return c.JSON(types.MultipleFileUploadedSuccessResponse{FileNames: uploadedFiles, Message: "Files Upload Successfull"})
-}
-
-func HandleDeleteFile(c *fiber.Ctx) error {
-	filename := c.Query("filename")
-	folder := c.Query("folder")
-
-	if filename == "" || folder == "" {
-		return c.Status(fiber.StatusBadRequest).JSON(types.ErrorResponse{Error: "filename and folder are required"})
+	if err == store.ErrFileNotFound {
+		err = utils.DeleteFile(filename, folder)
+	} else {
+		err = removeFile(fileStore, file)
+	}
+	if errors.Is(err, storage.ErrNotFound) || errors.Is(err, storage.ErrOutsideRoot) {
+		return c.Status(fiber.StatusNotFound).JSON(types.ErrorResponse{Error: "File not found"})
 	}
-
-	err := utils.DeleteFile(filename, folder)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(types.ErrorResponse{Error: "Failed to delete specified file. file might not existed"})
 	}
@@ -0,0 +1,69 @@
+package upload
+
+import (
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/froggy-12/purpurbase/services/authentication"
+	"github.com/froggy-12/purpurbase/services/storage"
+	"github.com/froggy-12/purpurbase/store"
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestHandleDeleteFileRejectsTraversal(t *testing.T) {
+	base := t.TempDir()
+	root := filepath.Join(base, "uploads")
+	if err := os.MkdirAll(filepath.Join(root, "files"), os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+	secret := filepath.Join(base, "secret.txt")
+	if err := os.WriteFile(secret, []byte("top secret"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	symlinked := os.Symlink(secret, filepath.Join(root, "files", "link.txt")) == nil
+
+	previous := storage.Files
+	storage.Files = storage.NewLocal(root)
+	t.Cleanup(func() { storage.Files = previous })
+
+	// an admin may delete any file, it still can't reach outside of the root
+	fileStore := store.NewMemoryFileStore()
+	app := fiber.New()
+	app.Delete("/api/deletefile", func(c *fiber.Ctx) error {
+		authentication.SetPrincipal(c, authentication.Principal{UserID: "admin", Roles: []string{"admin"}})
+		return HandleDeleteFile(c, fileStore)
+	})
+
+	queries := []string{
+		"folder=..&filename=secret.txt",
+		"folder=files&filename=../../secret.txt",
+		"folder=files&filename=..%2F..%2Fsecret.txt",
+		"folder=files%2F..%2F..&filename=secret.txt",
+		"folder=files&filename=%2F" + filepath.ToSlash(secret[1:]),
+		"folder=files&filename=..",
+		"folder=files&filename=.",
+		"folder=.&filename=files",
+	}
+	if symlinked {
+		queries = append(queries, "folder=files&filename=link.txt")
+	}
+
+	for _, query := range queries {
+		response, err := app.Test(httptest.NewRequest("DELETE", "/api/deletefile?"+query, nil), -1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if response.StatusCode != fiber.StatusBadRequest && response.StatusCode != fiber.StatusNotFound {
+			t.Errorf("deletefile?%s answered %d", query, response.StatusCode)
+		}
+	}
+
+	if _, err := os.Stat(secret); err != nil {
+		t.Fatalf("the secret file was deleted: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(root, "files")); err != nil {
+		t.Fatalf("the files folder was deleted: %v", err)
+	}
+}
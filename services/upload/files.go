@@ -0,0 +1,204 @@
+package upload
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"log"
+	"mime/multipart"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/froggy-12/purpurbase/services/authentication"
+	"github.com/froggy-12/purpurbase/services/storage"
+	"github.com/froggy-12/purpurbase/store"
+	"github.com/froggy-12/purpurbase/types"
+	"github.com/gabriel-vasile/mimetype"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+const (
+	filesDefaultPageSize = 50
+	filesMaxPageSize     = 500
+)
+
+// fileOwner is the user of the request or "apikey:<id>" for server api keys, empty for anonymous requests
+func fileOwner(c *fiber.Ctx) string {
+	principal, ok := authentication.CurrentPrincipal(c)
+	if !ok {
+		return ""
+	}
+	if principal.IsService() {
+		return "apikey:" + principal.APIKeyID
+	}
+	return principal.UserID
+}
+
+// CanManageFile reports if the request may see or delete the file, only admins can touch anonymous uploads.
+// The media server asks it for the files of owner-only buckets
+func CanManageFile(c *fiber.Ctx, file types.FileRecord) bool {
+	principal, ok := authentication.CurrentPrincipal(c)
+	if !ok {
+		return false
+	}
+	if principal.IsAdmin() {
+		return true
+	}
+	return file.UploaderID != "" && file.UploaderID == fileOwner(c)
+}
+
+// storeFile puts the upload into the storage driver under a generated name and records its metadata,
+// the content type is the one checkFiles detected
+func storeFile(c *fiber.Ctx, fileStore store.FileStore, file *multipart.FileHeader, folder, contentType string) (types.FileRecord, error) {
+	// the extension follows the detected type, the uploaded name could make an html file look like an image
+	var extension string
+	essence, _, _ := strings.Cut(contentType, ";")
+	if detected := mimetype.Lookup(essence); detected != nil {
+		extension = detected.Extension()
+	}
+
+	record := types.FileRecord{
+		ID:           uuid.New().String(),
+		Folder:       folder,
+		FileName:     uuid.New().String() + extension,
+		OriginalName: file.Filename,
+		Size:         file.Size,
+		ContentType:  contentType,
+		UploaderID:   fileOwner(c),
+		CreatedAt:    time.Now(),
+	}
+
+	key, err := storage.Resolve(folder, record.FileName)
+	if err != nil {
+		return record, err
+	}
+
+	fileStream, err := file.Open()
+	if err != nil {
+		return record, err
+	}
+	defer fileStream.Close()
+
+	// the checksum is taken while the file streams into the storage so it is only read once
+	checksum := sha256.New()
+	if err := storage.Files.Put(key, io.TeeReader(fileStream, checksum), file.Size, record.ContentType); err != nil {
+		return record, err
+	}
+	record.Checksum = hex.EncodeToString(checksum.Sum(nil))
+
+	if err := fileStore.CreateFile(record); err != nil {
+		if err := storage.Files.Delete(key); err != nil {
+			log.Println("Error removing file without metadata: ", err.Error())
+		}
+		return record, err
+	}
+
+	return record, nil
+}
+
+// removeFile deletes the file and its metadata
+func removeFile(fileStore store.FileStore, file types.FileRecord) error {
+	key, err := storage.Resolve(file.Folder, file.FileName)
+	if err != nil {
+		return err
+	}
+	if err := storage.Files.Delete(key); err != nil && !errors.Is(err, storage.ErrNotFound) {
+		return err
+	}
+	return fileStore.DeleteFile(file.ID)
+}
+
+// RemoveUserFiles deletes every file the user uploaded, it runs before a user or a purged guest is deleted
+func RemoveUserFiles(fileStore store.FileStore, userID string) error {
+	// an empty uploader would match every file
+	if userID == "" {
+		return nil
+	}
+	for {
+		files, _, err := fileStore.ListFiles(store.FileQuery{UploaderID: userID, Limit: filesMaxPageSize})
+		if err != nil || len(files) == 0 {
+			return err
+		}
+		for _, file := range files {
+			if err := removeFile(fileStore, file); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// uploadFiles stores every file of a multi upload or none of them, the stored ones are deleted again when one fails
+func uploadFiles(c *fiber.Ctx, fileStore store.FileStore, files []*multipart.FileHeader, folder string, contentTypes []string) ([]types.FileRecord, error) {
+	var uploadedFiles []types.FileRecord
+
+	for i, file := range files {
+		record, err := storeFile(c, fileStore, file, folder, contentTypes[i])
+		if err != nil {
+			for _, uploadedFile := range uploadedFiles {
+				if err := removeFile(fileStore, uploadedFile); err != nil {
+					log.Println("Error removing file of a failed upload: ", err.Error())
+				}
+			}
+			return nil, err
+		}
+
+		uploadedFiles = append(uploadedFiles, record)
+	}
+
+	return uploadedFiles, nil
+}
+
+func fileNames(files []types.FileRecord) []string {
+	names := make([]string, len(files))
+	for i, file := range files {
+		names[i] = file.FileName
+	}
+	return names
+}
+
+// ListFiles lists the files of the logged in user, admins see every file and can filter with ?uploaderId=.
+// Supports ?folder=&limit=&offset=, newest files first
+func ListFiles(c *fiber.Ctx, fileStore store.FileStore) error {
+	query := store.FileQuery{
+		UploaderID: fileOwner(c),
+		Folder:     c.Query("folder"),
+		Limit:      c.QueryInt("limit", filesDefaultPageSize),
+		Offset:     c.QueryInt("offset", 0),
+	}
+	if principal, _ := authentication.CurrentPrincipal(c); principal.IsAdmin() {
+		query.UploaderID = c.Query("uploaderId")
+	}
+
+	if query.Limit <= 0 || query.Limit > filesMaxPageSize {
+		return c.Status(fiber.StatusBadRequest).JSON(types.ErrorResponse{Error: "limit should be between 1 and " + strconv.Itoa(filesMaxPageSize)})
+	}
+	if query.Offset < 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(types.ErrorResponse{Error: "offset can not be negative"})
+	}
+
+	files, total, err := fileStore.ListFiles(query)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(types.ErrorResponse{Error: "Failed to list files: " + err.Error()})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(types.HTTPSuccessResponse{
+		Message: "Files have been found successfully",
+		Data:    map[string]any{"files": files, "total": total, "limit": query.Limit, "offset": query.Offset},
+	})
+}
+
+// GetFile returns the metadata of the :id param, files of other users are reported as not found
+func GetFile(c *fiber.Ctx, fileStore store.FileStore) error {
+	file, err := fileStore.FindFile(c.Params("id"))
+	if err == store.ErrFileNotFound || (err == nil && !CanManageFile(c, file)) {
+		return c.Status(fiber.StatusNotFound).JSON(types.ErrorResponse{Error: "File not found"})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(types.ErrorResponse{Error: "Something went wrong: " + err.Error()})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(types.HTTPSuccessResponse{Message: "File has been found", Data: map[string]any{"file": file}})
+}
@@ -0,0 +1,120 @@
+package upload
+
+import (
+	"bytes"
+	"io"
+	"mime/multipart"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/froggy-12/purpurbase/config"
+	"github.com/froggy-12/purpurbase/services/storage"
+	"github.com/froggy-12/purpurbase/store"
+	"github.com/froggy-12/purpurbase/types"
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestRemoveUserFiles(t *testing.T) {
+	root := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(root, "files"), os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+	previous := storage.Files
+	storage.Files = storage.NewLocal(root)
+	t.Cleanup(func() { storage.Files = previous })
+
+	fileStore := store.NewMemoryFileStore()
+	files := []types.FileRecord{
+		{ID: "1", Folder: "files", FileName: "guest-1.txt", UploaderID: "guest"},
+		{ID: "2", Folder: "files", FileName: "guest-2.txt", UploaderID: "guest"},
+		{ID: "3", Folder: "files", FileName: "other.txt", UploaderID: "other"},
+		{ID: "4", Folder: "files", FileName: "anonymous.txt"},
+	}
+	for _, file := range files {
+		if err := os.WriteFile(filepath.Join(root, file.Folder, file.FileName), []byte(file.ID), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if err := fileStore.CreateFile(file); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := RemoveUserFiles(fileStore, "guest"); err != nil {
+		t.Fatal(err)
+	}
+	// without an uploader nothing is removed, it would match every file
+	if err := RemoveUserFiles(fileStore, ""); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, file := range files {
+		_, statErr := os.Stat(filepath.Join(root, file.Folder, file.FileName))
+		_, findErr := fileStore.FindFile(file.ID)
+		removed := file.UploaderID == "guest"
+		if removed != os.IsNotExist(statErr) || removed != (findErr == store.ErrFileNotFound) {
+			t.Errorf("%s of %q: stat %v, record %v, want removed %v", file.FileName, file.UploaderID, statErr, findErr, removed)
+		}
+	}
+}
+
+func TestStoreFileNamesFilesByTheirType(t *testing.T) {
+	root := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(root, "files"), os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+	previous := storage.Files
+	storage.Files = storage.NewLocal(root)
+	t.Cleanup(func() { storage.Files = previous })
+
+	tests := []struct {
+		name, content string
+		extension     string
+	}{
+		{"a.png", string(htmlFile), ".html"},
+		{"a.html", string(pngFile), ".png"},
+		{"a.txt", string(textFile), ".txt"},
+		{"a.png", "\x00\x01\x02", ""},
+	}
+
+	for _, test := range tests {
+		fileStore := store.NewMemoryFileStore()
+		app := fiber.New()
+		app.Post("/", func(c *fiber.Ctx) error {
+			file, err := c.FormFile("file")
+			if err != nil {
+				return err
+			}
+			contentTypes, ok, err := checkFiles(c, []*multipart.FileHeader{file}, config.UploadRule{})
+			if !ok {
+				return err
+			}
+			record, err := storeFile(c, fileStore, file, "files", contentTypes[0])
+			if err != nil {
+				return err
+			}
+			return c.SendString(record.FileName)
+		})
+
+		var body bytes.Buffer
+		writer := multipart.NewWriter(&body)
+		part, err := writer.CreateFormFile("file", test.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		part.Write([]byte(test.content))
+		writer.Close()
+
+		req := httptest.NewRequest("POST", "/", &body)
+		req.Header.Set(fiber.HeaderContentType, writer.FormDataContentType())
+		response, err := app.Test(req, -1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		stored, _ := io.ReadAll(response.Body)
+		if response.StatusCode != fiber.StatusOK || filepath.Ext(string(stored)) != test.extension {
+			t.Errorf("%s was stored as %q with %d, want the extension %q", test.name, stored, response.StatusCode, test.extension)
+		}
+	}
+}
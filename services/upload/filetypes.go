@@ -0,0 +1,76 @@
+package upload
+
+import (
+	"mime/multipart"
+	"strconv"
+	"strings"
+
+	"github.com/froggy-12/purpurbase/config"
+	"github.com/froggy-12/purpurbase/types"
+	"github.com/gabriel-vasile/mimetype"
+	"github.com/gofiber/fiber/v2"
+)
+
+// typeAllowed matches the detected type and its aliases, "image/*" matches every image and "*" everything, an empty
+// list allows every type. Parent types are not matched, html would pass as text/plain otherwise
+func typeAllowed(detected *mimetype.MIME, allowed []string) bool {
+	if len(allowed) == 0 {
+		return true
+	}
+	for _, allowedType := range allowed {
+		if allowedType == "*" || detected.Is(allowedType) {
+			return true
+		}
+		if prefix, ok := strings.CutSuffix(allowedType, "*"); ok && strings.HasPrefix(detected.String(), prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// maxSize returns the limit in bytes of the most specific entry for the type, 0 when there is none
+func maxSize(detected *mimetype.MIME, sizes map[string]int) int64 {
+	mainType, _, _ := strings.Cut(detected.String(), "/")
+	essence, _, _ := strings.Cut(detected.String(), ";")
+	for _, key := range []string{essence, mainType + "/*", "*"} {
+		if megabytes, ok := sizes[key]; ok {
+			return int64(megabytes) * 1024 * 1024
+		}
+	}
+	return 0
+}
+
+func detectContentType(file *multipart.FileHeader) (*mimetype.MIME, error) {
+	fileStream, err := file.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer fileStream.Close()
+
+	return mimetype.DetectReader(fileStream)
+}
+
+// checkFiles sniffs the content of every file and holds it against the rule of the bucket before anything is stored.
+// It returns the detected content types in the order of the files, without ok the error response has already been sent
+func checkFiles(c *fiber.Ctx, files []*multipart.FileHeader, rule config.UploadRule) ([]string, bool, error) {
+	contentTypes := make([]string, len(files))
+
+	for i, file := range files {
+		detected, err := detectContentType(file)
+		if err != nil {
+			return nil, false, c.Status(fiber.StatusBadRequest).JSON(types.ErrorResponse{Error: "Failed to read " + file.Filename})
+		}
+
+		if !typeAllowed(detected, rule.AllowedTypes) {
+			return nil, false, c.Status(fiber.StatusBadRequest).JSON(types.ErrorResponse{Error: "Only " + strings.Join(rule.AllowedTypes, ", ") + " files can be accepted in this bucket, " + file.Filename + " is " + detected.String()})
+		}
+
+		if limit := maxSize(detected, rule.MaxSizes); limit > 0 && file.Size > limit {
+			return nil, false, c.Status(fiber.StatusRequestEntityTooLarge).JSON(types.ErrorResponse{Error: file.Filename + " is larger than the " + strconv.FormatInt(limit/1024/1024, 10) + " MB allowed for " + detected.String() + " files"})
+		}
+
+		contentTypes[i] = detected.String()
+	}
+
+	return contentTypes, true, nil
+}
@@ -0,0 +1,135 @@
+package upload
+
+import (
+	"bytes"
+	"encoding/json"
+	"mime/multipart"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/froggy-12/purpurbase/config"
+	"github.com/gabriel-vasile/mimetype"
+	"github.com/gofiber/fiber/v2"
+)
+
+var (
+	pngFile  = []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR\x00\x00\x00\x01\x00\x00\x00\x01\x08\x06\x00\x00\x00")
+	htmlFile = []byte("<!DOCTYPE html><html><body><script>alert(1)</script></body></html>")
+	textFile = []byte("just some text")
+)
+
+func TestTypeAllowed(t *testing.T) {
+	tests := []struct {
+		detected string
+		allowed  []string
+		want     bool
+	}{
+		{"image/png", nil, true},
+		{"image/png", []string{"*"}, true},
+		{"image/png", []string{"image/png"}, true},
+		{"image/png", []string{"image/*"}, true},
+		{"image/png", []string{"image/jpeg", "application/pdf"}, false},
+		{"image/png", []string{"video/*"}, false},
+		{"text/html", []string{"text/plain"}, false}, // parents don't match, html would pass as text otherwise
+		{"text/html", []string{"text/*"}, true},
+		{"text/plain", []string{"text/plain"}, true},
+		{"application/pdf", []string{"image/*", "application/pdf"}, true},
+	}
+
+	for _, test := range tests {
+		if got := typeAllowed(mimetype.Lookup(test.detected), test.allowed); got != test.want {
+			t.Errorf("typeAllowed(%s, %v) = %v, want %v", test.detected, test.allowed, got, test.want)
+		}
+	}
+}
+
+func TestMaxSize(t *testing.T) {
+	const megabyte = 1024 * 1024
+	sizes := map[string]int{"image/png": 1, "image/*": 2, "*": 3}
+
+	tests := []struct {
+		detected string
+		sizes    map[string]int
+		want     int64
+	}{
+		{"image/png", sizes, 1 * megabyte},
+		{"image/jpeg", sizes, 2 * megabyte},
+		{"text/html", sizes, 3 * megabyte}, // the charset of the detected type is ignored
+		{"video/mp4", map[string]int{"image/*": 2}, 0},
+		{"image/png", nil, 0},
+	}
+
+	for _, test := range tests {
+		if got := maxSize(mimetype.Lookup(test.detected), test.sizes); got != test.want {
+			t.Errorf("maxSize(%s, %v) = %d, want %d", test.detected, test.sizes, got, test.want)
+		}
+	}
+}
+
+func TestCheckFiles(t *testing.T) {
+	bigPNG := append(append([]byte{}, pngFile...), make([]byte, 1024*1024)...)
+
+	tests := []struct {
+		name  string
+		files map[string][]byte
+		rule  config.UploadRule
+		code  int
+		types []string
+	}{
+		{"every type without a rule", map[string][]byte{"a.png": pngFile}, config.UploadRule{}, fiber.StatusOK, []string{"image/png"}},
+		{"allowed image", map[string][]byte{"a.png": pngFile}, config.UploadRule{AllowedTypes: []string{"image/*"}}, fiber.StatusOK, []string{"image/png"}},
+		{"html renamed to an image", map[string][]byte{"a.png": htmlFile}, config.UploadRule{AllowedTypes: []string{"image/*"}}, fiber.StatusBadRequest, nil},
+		{"one bad file fails all", map[string][]byte{"a.png": pngFile, "b.txt": textFile}, config.UploadRule{AllowedTypes: []string{"image/png"}}, fiber.StatusBadRequest, nil},
+		{"too large", map[string][]byte{"a.png": bigPNG}, config.UploadRule{MaxSizes: map[string]int{"image/*": 1}}, fiber.StatusRequestEntityTooLarge, nil},
+		{"limit of another type", map[string][]byte{"a.png": bigPNG}, config.UploadRule{MaxSizes: map[string]int{"video/*": 1}}, fiber.StatusOK, []string{"image/png"}},
+	}
+
+	for _, test := range tests {
+		rule := test.rule
+		app := fiber.New()
+		app.Post("/", func(c *fiber.Ctx) error {
+			form, err := c.MultipartForm()
+			if err != nil {
+				return err
+			}
+			contentTypes, ok, err := checkFiles(c, form.File["files"], rule)
+			if !ok {
+				return err
+			}
+			return c.JSON(contentTypes)
+		})
+
+		var body bytes.Buffer
+		writer := multipart.NewWriter(&body)
+		for name, content := range test.files {
+			part, err := writer.CreateFormFile("files", name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			part.Write(content)
+		}
+		writer.Close()
+
+		req := httptest.NewRequest("POST", "/", &body)
+		req.Header.Set(fiber.HeaderContentType, writer.FormDataContentType())
+		response, err := app.Test(req, -1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if response.StatusCode != test.code {
+			t.Errorf("%s: answered %d, want %d", test.name, response.StatusCode, test.code)
+			continue
+		}
+		if test.types == nil {
+			continue
+		}
+		var contentTypes []string
+		if err := json.NewDecoder(response.Body).Decode(&contentTypes); err != nil {
+			t.Fatal(err)
+		}
+		if strings.Join(contentTypes, ",") != strings.Join(test.types, ",") {
+			t.Errorf("%s: detected %v, want %v", test.name, contentTypes, test.types)
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package store
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/froggy-12/purpurbase/config"
+	"github.com/froggy-12/purpurbase/types"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var ErrAPIKeyNotFound = errors.New("api key not found")
+
+// APIKeyStore keeps the server api keys, revoked keys are deleted
+type APIKeyStore interface {
+	CreateAPIKey(key types.APIKey) error
+	FindAPIKey(id string) (types.APIKey, error)
+	ListAPIKeys() ([]types.APIKey, error)
+	// RotateAPIKey replaces the hash so the old key stops working right away
+	RotateAPIKey(id, keyHash string) error
+	TouchAPIKey(id string) error
+	DeleteAPIKey(id string) error
+}
+
+func NewAPIKeyStore(mongoClient *mongo.Client, sqlClient *sql.DB) APIKeyStore {
+	switch config.Configs.DatabaseConfigurations.DatabaseName {
+	case "mongodb":
+		return NewMongoAPIKeyStore(mongoClient)
+	case "mysql":
+		return NewMySQLAPIKeyStore(sqlClient)
+	case "postgresql":
+		return NewPostgresAPIKeyStore(sqlClient)
+	default:
+		log.Fatal("Unsupported database")
+		return nil
+	}
+}
+
+// apiKeyUsable reports if the key hasn't expired yet
+func apiKeyUsable(key types.APIKey) bool {
+	return key.ExpiresAt.IsZero() || time.Now().Before(key.ExpiresAt)
+}
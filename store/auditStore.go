@@ -0,0 +1,45 @@
+package store
+
+import (
+	"database/sql"
+	"log"
+	"time"
+
+	"github.com/froggy-12/purpurbase/config"
+	"github.com/froggy-12/purpurbase/types"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// AuditStore keeps the audit log, events can only be appended and are never changed or deleted
+type AuditStore interface {
+	AppendAuditEvent(event types.AuditEvent) error
+	// ListAuditEvents returns a page of the matching events, newest first, and how many match in total
+	ListAuditEvents(query AuditQuery) ([]types.AuditEvent, int, error)
+}
+
+type AuditQuery struct {
+	Types   []string // any of these event types
+	UserID  string
+	ActorID string
+	Email   string // case insensitive
+	IP      string
+	Success *bool
+	Since   time.Time // inclusive
+	Until   time.Time // exclusive
+	Limit   int
+	Offset  int
+}
+
+func NewAuditStore(mongoClient *mongo.Client, sqlClient *sql.DB) AuditStore {
+	switch config.Configs.DatabaseConfigurations.DatabaseName {
+	case "mongodb":
+		return NewMongoAuditStore(mongoClient)
+	case "mysql":
+		return NewMySQLAuditStore(sqlClient)
+	case "postgresql":
+		return NewPostgresAuditStore(sqlClient)
+	default:
+		log.Fatal("Unsupported database")
+		return nil
+	}
+}
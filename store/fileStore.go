@@ -0,0 +1,45 @@
+package store
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+
+	"github.com/froggy-12/purpurbase/config"
+	"github.com/froggy-12/purpurbase/types"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var ErrFileNotFound = errors.New("file not found")
+
+// FileStore keeps the metadata of the uploaded files, the content lives in the storage driver
+type FileStore interface {
+	CreateFile(file types.FileRecord) error
+	FindFile(id string) (types.FileRecord, error)
+	// FindFileByName finds the file stored under <folder>/<fileName>
+	FindFileByName(folder, fileName string) (types.FileRecord, error)
+	// ListFiles returns a page of the matching files, newest first, and how many match in total
+	ListFiles(query FileQuery) ([]types.FileRecord, int, error)
+	DeleteFile(id string) error
+}
+
+type FileQuery struct {
+	UploaderID string
+	Folder     string
+	Limit      int
+	Offset     int
+}
+
+func NewFileStore(mongoClient *mongo.Client, sqlClient *sql.DB) FileStore {
+	switch config.Configs.DatabaseConfigurations.DatabaseName {
+	case "mongodb":
+		return NewMongoFileStore(mongoClient)
+	case "mysql":
+		return NewMySQLFileStore(sqlClient)
+	case "postgresql":
+		return NewPostgresFileStore(sqlClient)
+	default:
+		log.Fatal("Unsupported database")
+		return nil
+	}
+}
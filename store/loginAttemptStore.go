@@ -0,0 +1,38 @@
+package store
+
+import (
+	"errors"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+var ErrUnlockTokenNotFound = errors.New("unlock token not found")
+
+// LoginAttemptStore counts failed log ins and keeps lockouts, keys are like "account:<email>" or "ip:<ip>"
+type LoginAttemptStore interface {
+	// RecordFailure counts a failed attempt and returns the count, the count starts over window after the first failure
+	RecordFailure(key string, window time.Duration) (int, error)
+	// Lock blocks the key for the duration and returns how often it has been locked within the last day
+	Lock(key string, duration time.Duration) (int, error)
+	// LockedFor is how long the key stays locked, 0 when it isn't
+	LockedFor(key string) (time.Duration, error)
+	// Lockouts is how often the key has been locked within the last day
+	Lockouts(key string) (int, error)
+	// Unlock removes the lock, the failures and the lockout history of the key
+	Unlock(key string) error
+	SaveUnlockToken(tokenHash, key string, ttl time.Duration) error
+	// TakeUnlockToken returns the key of the token and deletes it so it can only be used once
+	TakeUnlockToken(tokenHash string) (string, error)
+}
+
+// lockoutMemory is how long a lockout counts towards the backoff of the next one
+const lockoutMemory = 24 * time.Hour
+
+// NewLoginAttemptStore uses redis when there is a client so every instance shares the counters, memory otherwise
+func NewLoginAttemptStore(redisClient *redis.Client) LoginAttemptStore {
+	if redisClient != nil {
+		return NewRedisLoginAttemptStore(redisClient)
+	}
+	return NewMemoryLoginAttemptStore()
+}
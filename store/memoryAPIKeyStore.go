@@ -0,0 +1,87 @@
+package store
+
+import (
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/froggy-12/purpurbase/types"
+)
+
+type MemoryAPIKeyStore struct {
+	mu   sync.RWMutex
+	keys map[string]types.APIKey
+}
+
+func NewMemoryAPIKeyStore() *MemoryAPIKeyStore {
+	return &MemoryAPIKeyStore{keys: map[string]types.APIKey{}}
+}
+
+func copyAPIKey(key types.APIKey) types.APIKey {
+	key.Scopes = slices.Clone(key.Scopes)
+	return key
+}
+
+func (s *MemoryAPIKeyStore) CreateAPIKey(key types.APIKey) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.keys[key.ID] = copyAPIKey(key)
+	return nil
+}
+
+func (s *MemoryAPIKeyStore) FindAPIKey(id string) (types.APIKey, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	key, ok := s.keys[id]
+	if !ok || !apiKeyUsable(key) {
+		return types.APIKey{}, ErrAPIKeyNotFound
+	}
+	return copyAPIKey(key), nil
+}
+
+func (s *MemoryAPIKeyStore) ListAPIKeys() ([]types.APIKey, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	keys := []types.APIKey{}
+	for _, key := range s.keys {
+		keys = append(keys, copyAPIKey(key))
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].CreatedAt.Before(keys[j].CreatedAt) })
+	return keys, nil
+}
+
+func (s *MemoryAPIKeyStore) RotateAPIKey(id, keyHash string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key, ok := s.keys[id]
+	if !ok {
+		return ErrAPIKeyNotFound
+	}
+	key.KeyHash = keyHash
+	key.RotatedAt = time.Now()
+	s.keys[id] = key
+	return nil
+}
+
+func (s *MemoryAPIKeyStore) TouchAPIKey(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key, ok := s.keys[id]
+	if !ok {
+		return ErrAPIKeyNotFound
+	}
+	key.LastUsedAt = time.Now()
+	s.keys[id] = key
+	return nil
+}
+
+func (s *MemoryAPIKeyStore) DeleteAPIKey(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.keys[id]; !ok {
+		return ErrAPIKeyNotFound
+	}
+	delete(s.keys, id)
+	return nil
+}
@@ -0,0 +1,63 @@
+package store
+
+import (
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/froggy-12/purpurbase/types"
+)
+
+// MemoryAuditStore keeps the events in the order they were appended, the log is lost on restart
+type MemoryAuditStore struct {
+	mu     sync.RWMutex
+	events []types.AuditEvent
+}
+
+func NewMemoryAuditStore() *MemoryAuditStore {
+	return &MemoryAuditStore{}
+}
+
+func (q AuditQuery) matches(event types.AuditEvent) bool {
+	switch {
+	case len(q.Types) > 0 && !slices.Contains(q.Types, event.Type),
+		q.UserID != "" && event.UserID != q.UserID,
+		q.ActorID != "" && event.ActorID != q.ActorID,
+		q.Email != "" && !strings.EqualFold(event.Email, q.Email),
+		q.IP != "" && event.IP != q.IP,
+		q.Success != nil && event.Success != *q.Success,
+		!q.Since.IsZero() && event.CreatedAt.Before(q.Since),
+		!q.Until.IsZero() && !event.CreatedAt.Before(q.Until):
+		return false
+	}
+	return true
+}
+
+func (s *MemoryAuditStore) AppendAuditEvent(event types.AuditEvent) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	event.Details = maps.Clone(event.Details)
+	s.events = append(s.events, event)
+	return nil
+}
+
+func (s *MemoryAuditStore) ListAuditEvents(query AuditQuery) ([]types.AuditEvent, int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	events := []types.AuditEvent{}
+	total := 0
+	for i := len(s.events) - 1; i >= 0; i-- {
+		event := s.events[i]
+		if !query.matches(event) {
+			continue
+		}
+		if total >= query.Offset && (query.Limit <= 0 || len(events) < query.Limit) {
+			event.Details = maps.Clone(event.Details)
+			events = append(events, event)
+		}
+		total++
+	}
+	return events, total, nil
+}
@@ -0,0 +1,80 @@
+package store
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/froggy-12/purpurbase/types"
+)
+
+type MemoryFileStore struct {
+	mu    sync.RWMutex
+	files map[string]types.FileRecord
+}
+
+func NewMemoryFileStore() *MemoryFileStore {
+	return &MemoryFileStore{files: map[string]types.FileRecord{}}
+}
+
+func (q FileQuery) matches(file types.FileRecord) bool {
+	return (q.UploaderID == "" || file.UploaderID == q.UploaderID) && (q.Folder == "" || file.Folder == q.Folder)
+}
+
+func (s *MemoryFileStore) CreateFile(file types.FileRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.files[file.ID] = file
+	return nil
+}
+
+func (s *MemoryFileStore) FindFile(id string) (types.FileRecord, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	file, ok := s.files[id]
+	if !ok {
+		return types.FileRecord{}, ErrFileNotFound
+	}
+	return file, nil
+}
+
+func (s *MemoryFileStore) FindFileByName(folder, fileName string) (types.FileRecord, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, file := range s.files {
+		if file.Folder == folder && file.FileName == fileName {
+			return file, nil
+		}
+	}
+	return types.FileRecord{}, ErrFileNotFound
+}
+
+func (s *MemoryFileStore) ListFiles(query FileQuery) ([]types.FileRecord, int, error) {
+	s.mu.RLock()
+	matching := []types.FileRecord{}
+	for _, file := range s.files {
+		if query.matches(file) {
+			matching = append(matching, file)
+		}
+	}
+	s.mu.RUnlock()
+
+	sort.Slice(matching, func(i, j int) bool { return matching[i].CreatedAt.After(matching[j].CreatedAt) })
+
+	total := len(matching)
+	start := min(query.Offset, total)
+	end := total
+	if query.Limit > 0 {
+		end = min(start+query.Limit, total)
+	}
+	return matching[start:end], total, nil
+}
+
+func (s *MemoryFileStore) DeleteFile(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.files[id]; !ok {
+		return ErrFileNotFound
+	}
+	delete(s.files, id)
+	return nil
+}
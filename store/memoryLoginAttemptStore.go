@@ -0,0 +1,113 @@
+package store
+
+import (
+	"sync"
+	"time"
+)
+
+type counter struct {
+	count     int
+	expiresAt time.Time
+}
+
+func (c counter) live() bool {
+	return time.Now().Before(c.expiresAt)
+}
+
+type unlockToken struct {
+	key       string
+	expiresAt time.Time
+}
+
+// MemoryLoginAttemptStore only works for a single instance, expired entries are dropped when they are read
+type MemoryLoginAttemptStore struct {
+	mu       sync.Mutex
+	failures map[string]counter
+	locks    map[string]time.Time
+	lockouts map[string]counter
+	tokens   map[string]unlockToken
+}
+
+func NewMemoryLoginAttemptStore() *MemoryLoginAttemptStore {
+	return &MemoryLoginAttemptStore{
+		failures: map[string]counter{},
+		locks:    map[string]time.Time{},
+		lockouts: map[string]counter{},
+		tokens:   map[string]unlockToken{},
+	}
+}
+
+func increment(counters map[string]counter, key string, ttl time.Duration) int {
+	c, ok := counters[key]
+	if !ok || !c.live() {
+		c = counter{expiresAt: time.Now().Add(ttl)}
+	}
+	c.count++
+	counters[key] = c
+	return c.count
+}
+
+func (s *MemoryLoginAttemptStore) RecordFailure(key string, window time.Duration) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return increment(s.failures, key, window), nil
+}
+
+func (s *MemoryLoginAttemptStore) Lock(key string, duration time.Duration) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.locks[key] = time.Now().Add(duration)
+	delete(s.failures, key)
+	return increment(s.lockouts, key, lockoutMemory), nil
+}
+
+func (s *MemoryLoginAttemptStore) LockedFor(key string) (time.Duration, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	until, ok := s.locks[key]
+	if !ok {
+		return 0, nil
+	}
+	if remaining := time.Until(until); remaining > 0 {
+		return remaining, nil
+	}
+	delete(s.locks, key)
+	return 0, nil
+}
+
+func (s *MemoryLoginAttemptStore) Lockouts(key string) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c, ok := s.lockouts[key]
+	if !ok || !c.live() {
+		return 0, nil
+	}
+	return c.count, nil
+}
+
+func (s *MemoryLoginAttemptStore) Unlock(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.locks, key)
+	delete(s.failures, key)
+	delete(s.lockouts, key)
+	return nil
+}
+
+func (s *MemoryLoginAttemptStore) SaveUnlockToken(tokenHash, key string, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tokens[tokenHash] = unlockToken{key: key, expiresAt: time.Now().Add(ttl)}
+	return nil
+}
+
+func (s *MemoryLoginAttemptStore) TakeUnlockToken(tokenHash string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	token, ok := s.tokens[tokenHash]
+	delete(s.tokens, tokenHash)
+	if !ok || time.Now().After(token.expiresAt) {
+		return "", ErrUnlockTokenNotFound
+	}
+	return token.key, nil
+}
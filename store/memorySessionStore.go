@@ -0,0 +1,84 @@
+package store
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/froggy-12/purpurbase/types"
+)
+
+type MemorySessionStore struct {
+	mu       sync.RWMutex
+	sessions map[string]types.Session
+}
+
+func NewMemorySessionStore() *MemorySessionStore {
+	return &MemorySessionStore{sessions: map[string]types.Session{}}
+}
+
+func (s *MemorySessionStore) CreateSession(session types.Session) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sessions[session.ID] = session
+	return nil
+}
+
+func (s *MemorySessionStore) FindSession(id string) (types.Session, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	session, ok := s.sessions[id]
+	if !ok || time.Now().After(session.ExpiresAt) {
+		return types.Session{}, ErrSessionNotFound
+	}
+	return session, nil
+}
+
+func (s *MemorySessionStore) ListUserSessions(userID string) ([]types.Session, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	sessions := []types.Session{}
+	for _, session := range s.sessions {
+		if session.UserID == userID && time.Now().Before(session.ExpiresAt) {
+			sessions = append(sessions, session)
+		}
+	}
+	sort.Slice(sessions, func(i, j int) bool { return sessions[i].LastUsedAt.After(sessions[j].LastUsedAt) })
+	return sessions, nil
+}
+
+func (s *MemorySessionStore) RotateRefreshToken(id, oldHash, newHash string, expiresAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	session, ok := s.sessions[id]
+	if !ok || session.RefreshTokenHash != oldHash || time.Now().After(session.ExpiresAt) {
+		return ErrSessionNotFound
+	}
+	session.PreviousRefreshTokenHash = oldHash
+	session.RefreshTokenHash = newHash
+	session.LastUsedAt = time.Now()
+	session.ExpiresAt = expiresAt
+	s.sessions[id] = session
+	return nil
+}
+
+func (s *MemorySessionStore) DeleteSession(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.sessions[id]; !ok {
+		return ErrSessionNotFound
+	}
+	delete(s.sessions, id)
+	return nil
+}
+
+func (s *MemorySessionStore) DeleteUserSessions(userID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for id, session := range s.sessions {
+		if session.UserID == userID {
+			delete(s.sessions, id)
+		}
+	}
+	return nil
+}
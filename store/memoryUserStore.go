@@ -0,0 +1,243 @@
+package store
+
+import (
+	"maps"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/froggy-12/purpurbase/types"
+)
+
+// MemoryUserStore keeps users in a map, it is meant for tests and trying things out without a database
+type MemoryUserStore struct {
+	mu    sync.RWMutex
+	users map[string]types.UserRecord
+}
+
+func NewMemoryUserStore() *MemoryUserStore {
+	return &MemoryUserStore{users: map[string]types.UserRecord{}}
+}
+
+// copyUser makes sure callers never share the RawData map with the store
+func copyUser(user types.UserRecord) types.UserRecord {
+	user.RawData = maps.Clone(user.RawData)
+	if user.RawData == nil {
+		user.RawData = map[string]any{}
+	}
+	return user
+}
+
+func (s *MemoryUserStore) find(match func(types.UserRecord) bool) (types.UserRecord, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, user := range s.users {
+		if match(user) {
+			return copyUser(user), nil
+		}
+	}
+	return types.UserRecord{}, ErrUserNotFound
+}
+
+func (s *MemoryUserStore) FindUserByID(id string) (types.UserRecord, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	user, ok := s.users[id]
+	if !ok {
+		return types.UserRecord{}, ErrUserNotFound
+	}
+	return copyUser(user), nil
+}
+
+func (s *MemoryUserStore) FindUserByEmail(email string) (types.UserRecord, error) {
+	return s.find(func(user types.UserRecord) bool { return user.Email == email })
+}
+
+func (s *MemoryUserStore) FindUserByUsername(username string) (types.UserRecord, error) {
+	return s.find(func(user types.UserRecord) bool { return user.UserName == username })
+}
+
+// conflicts reports if another user already uses the email or username, caller holds the lock
+func (s *MemoryUserStore) conflicts(user types.UserRecord) bool {
+	for _, existing := range s.users {
+		if existing.ID != user.ID && (existing.Email == user.Email || existing.UserName == user.UserName) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *MemoryUserStore) FindUserByIdentity(provider, subject string) (types.UserRecord, error) {
+	return s.find(func(user types.UserRecord) bool { return hasIdentity(user, provider, subject) })
+}
+
+func (s *MemoryUserStore) CreateUser(user types.UserRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.users[user.ID]; ok || s.conflicts(user) {
+		return ErrUserAlreadyExists
+	}
+	s.users[user.ID] = copyUser(user)
+	return nil
+}
+
+func (s *MemoryUserStore) UpdateUser(user types.UserRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.users[user.ID]; !ok {
+		return ErrUserNotFound
+	}
+	if s.conflicts(user) {
+		return ErrUserAlreadyExists
+	}
+	user.UpdatedAt = time.Now()
+	s.users[user.ID] = copyUser(user)
+	return nil
+}
+
+func (s *MemoryUserStore) DeleteUser(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.users[id]; !ok {
+		return ErrUserNotFound
+	}
+	delete(s.users, id)
+	return nil
+}
+
+func (s *MemoryUserStore) update(id string, change func(*types.UserRecord)) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	user, ok := s.users[id]
+	if !ok {
+		return ErrUserNotFound
+	}
+	change(&user)
+	s.users[id] = user
+	return nil
+}
+
+func (s *MemoryUserStore) SetVerified(id string, verified bool) error {
+	return s.update(id, func(user *types.UserRecord) {
+		user.Verified = verified
+		user.UpdatedAt = time.Now()
+	})
+}
+
+func (s *MemoryUserStore) TouchLastLogin(id string) error {
+	return s.update(id, func(user *types.UserRecord) {
+		user.LastLoggedIn = time.Now()
+	})
+}
+
+func (s *MemoryUserStore) SetMagicLink(id, tokenHash, codeHash string, expiresAt time.Time) error {
+	return s.update(id, func(user *types.UserRecord) {
+		user.MagicLinkToken = tokenHash
+		user.MagicLinkCode = codeHash
+		user.MagicLinkExpiresAt = expiresAt
+	})
+}
+
+func (s *MemoryUserStore) CountMagicLinkAttempt(id string, limit int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	user, ok := s.users[id]
+	if !ok {
+		return ErrUserNotFound
+	}
+	if user.MagicLinkAttempts >= limit {
+		return ErrTooManyAttempts
+	}
+	user.MagicLinkAttempts++
+	s.users[id] = user
+	return nil
+}
+
+func (s *MemoryUserStore) UseTOTPStep(id string, step int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	user, ok := s.users[id]
+	if !ok {
+		return ErrUserNotFound
+	}
+	if user.TOTPLastStep >= step {
+		return ErrCodeAlreadyUsed
+	}
+	user.TOTPLastStep = step
+	s.users[id] = user
+	return nil
+}
+
+func (s *MemoryUserStore) UseRecoveryCode(id, hash string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	user, ok := s.users[id]
+	if !ok {
+		return ErrUserNotFound
+	}
+	index := slices.Index(user.TOTPRecoveryCodes, hash)
+	if index < 0 {
+		return ErrCodeAlreadyUsed
+	}
+	user.TOTPRecoveryCodes = slices.Delete(slices.Clone(user.TOTPRecoveryCodes), index, index+1)
+	s.users[id] = user
+	return nil
+}
+
+func (s *MemoryUserStore) ListUsers(query UserQuery) ([]types.UserRecord, int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	search := strings.ToLower(query.Search)
+	users := []types.UserRecord{}
+	for _, user := range s.users {
+		if search != "" && !strings.Contains(strings.ToLower(user.Email), search) && !strings.Contains(strings.ToLower(user.UserName), search) {
+			continue
+		}
+		if query.Role != "" && !slices.Contains(user.Roles, query.Role) {
+			continue
+		}
+		if query.Verified != nil && user.Verified != *query.Verified {
+			continue
+		}
+		if query.Disabled != nil && user.Disabled != *query.Disabled {
+			continue
+		}
+		if query.Anonymous != nil && user.Anonymous != *query.Anonymous {
+			continue
+		}
+		if !query.LastLoggedInBefore.IsZero() && !user.LastLoggedIn.Before(query.LastLoggedInBefore) {
+			continue
+		}
+		users = append(users, copyUser(user))
+	}
+
+	sort.SliceStable(users, func(i, j int) bool {
+		a, b := users[i], users[j]
+		if query.Desc {
+			a, b = b, a
+		}
+		switch query.sortField() {
+		case "updatedAt":
+			return a.UpdatedAt.Before(b.UpdatedAt)
+		case "lastLoggedIn":
+			return a.LastLoggedIn.Before(b.LastLoggedIn)
+		case "email":
+			return a.Email < b.Email
+		case "username":
+			return a.UserName < b.UserName
+		default:
+			return a.CreatedAt.Before(b.CreatedAt)
+		}
+	})
+
+	total := len(users)
+	start := min(query.Offset, total)
+	end := total
+	if query.Limit > 0 {
+		end = min(start+query.Limit, total)
+	}
+	return users[start:end], total, nil
+}
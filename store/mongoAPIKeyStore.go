@@ -0,0 +1,76 @@
+package store
+
+import (
+	"context"
+	"time"
+
+	"github.com/froggy-12/purpurbase/types"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type MongoAPIKeyStore struct {
+	coll *mongo.Collection
+}
+
+func NewMongoAPIKeyStore(mongoClient *mongo.Client) *MongoAPIKeyStore {
+	return &MongoAPIKeyStore{coll: mongoClient.Database("purpurbase").Collection("api_keys")}
+}
+
+func (s *MongoAPIKeyStore) CreateAPIKey(key types.APIKey) error {
+	_, err := s.coll.InsertOne(context.Background(), key)
+	return err
+}
+
+func (s *MongoAPIKeyStore) FindAPIKey(id string) (types.APIKey, error) {
+	key := types.APIKey{}
+	err := s.coll.FindOne(context.Background(), bson.M{"id": id}).Decode(&key)
+	if err == mongo.ErrNoDocuments || (err == nil && !apiKeyUsable(key)) {
+		return types.APIKey{}, ErrAPIKeyNotFound
+	}
+	return key, err
+}
+
+func (s *MongoAPIKeyStore) ListAPIKeys() ([]types.APIKey, error) {
+	cursor, err := s.coll.Find(context.Background(), bson.M{}, options.Find().SetSort(bson.M{"createdAt": 1}))
+	if err != nil {
+		return nil, err
+	}
+
+	keys := []types.APIKey{}
+	if err := cursor.All(context.Background(), &keys); err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+func (s *MongoAPIKeyStore) setFields(id string, fields bson.M) error {
+	res, err := s.coll.UpdateOne(context.Background(), bson.M{"id": id}, bson.M{"$set": fields})
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return ErrAPIKeyNotFound
+	}
+	return nil
+}
+
+func (s *MongoAPIKeyStore) RotateAPIKey(id, keyHash string) error {
+	return s.setFields(id, bson.M{"keyHash": keyHash, "rotatedAt": time.Now()})
+}
+
+func (s *MongoAPIKeyStore) TouchAPIKey(id string) error {
+	return s.setFields(id, bson.M{"lastUsedAt": time.Now()})
+}
+
+func (s *MongoAPIKeyStore) DeleteAPIKey(id string) error {
+	res, err := s.coll.DeleteOne(context.Background(), bson.M{"id": id})
+	if err != nil {
+		return err
+	}
+	if res.DeletedCount == 0 {
+		return ErrAPIKeyNotFound
+	}
+	return nil
+}
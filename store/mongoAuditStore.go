@@ -0,0 +1,79 @@
+package store
+
+import (
+	"context"
+	"regexp"
+
+	"github.com/froggy-12/purpurbase/types"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type MongoAuditStore struct {
+	coll *mongo.Collection
+}
+
+func NewMongoAuditStore(mongoClient *mongo.Client) *MongoAuditStore {
+	return &MongoAuditStore{coll: mongoClient.Database("purpurbase").Collection("audit_log")}
+}
+
+func (s *MongoAuditStore) AppendAuditEvent(event types.AuditEvent) error {
+	_, err := s.coll.InsertOne(context.Background(), event)
+	return err
+}
+
+func (s *MongoAuditStore) ListAuditEvents(query AuditQuery) ([]types.AuditEvent, int, error) {
+	filter := bson.M{}
+	if len(query.Types) > 0 {
+		filter["type"] = bson.M{"$in": query.Types}
+	}
+	if query.UserID != "" {
+		filter["userId"] = query.UserID
+	}
+	if query.ActorID != "" {
+		filter["actorId"] = query.ActorID
+	}
+	if query.Email != "" {
+		filter["email"] = primitive.Regex{Pattern: "^" + regexp.QuoteMeta(query.Email) + "$", Options: "i"}
+	}
+	if query.IP != "" {
+		filter["ip"] = query.IP
+	}
+	if query.Success != nil {
+		filter["success"] = *query.Success
+	}
+	createdAt := bson.M{}
+	if !query.Since.IsZero() {
+		createdAt["$gte"] = query.Since
+	}
+	if !query.Until.IsZero() {
+		createdAt["$lt"] = query.Until
+	}
+	if len(createdAt) > 0 {
+		filter["createdAt"] = createdAt
+	}
+
+	total, err := s.coll.CountDocuments(context.Background(), filter)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	// _id breaks ties between events of the same instant in insertion order
+	opts := options.Find().SetSort(bson.D{{Key: "createdAt", Value: -1}, {Key: "_id", Value: -1}}).SetSkip(int64(query.Offset))
+	if query.Limit > 0 {
+		opts.SetLimit(int64(query.Limit))
+	}
+
+	cursor, err := s.coll.Find(context.Background(), filter, opts)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	events := []types.AuditEvent{}
+	if err := cursor.All(context.Background(), &events); err != nil {
+		return nil, 0, err
+	}
+	return events, int(total), nil
+}
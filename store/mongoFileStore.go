@@ -0,0 +1,82 @@
+package store
+
+import (
+	"context"
+
+	"github.com/froggy-12/purpurbase/types"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type MongoFileStore struct {
+	coll *mongo.Collection
+}
+
+func NewMongoFileStore(mongoClient *mongo.Client) *MongoFileStore {
+	return &MongoFileStore{coll: mongoClient.Database("purpurbase").Collection("files")}
+}
+
+func (s *MongoFileStore) CreateFile(file types.FileRecord) error {
+	_, err := s.coll.InsertOne(context.Background(), file)
+	return err
+}
+
+func (s *MongoFileStore) findOne(filter bson.M) (types.FileRecord, error) {
+	file := types.FileRecord{}
+	err := s.coll.FindOne(context.Background(), filter).Decode(&file)
+	if err == mongo.ErrNoDocuments {
+		return types.FileRecord{}, ErrFileNotFound
+	}
+	return file, err
+}
+
+func (s *MongoFileStore) FindFile(id string) (types.FileRecord, error) {
+	return s.findOne(bson.M{"id": id})
+}
+
+func (s *MongoFileStore) FindFileByName(folder, fileName string) (types.FileRecord, error) {
+	return s.findOne(bson.M{"folder": folder, "fileName": fileName})
+}
+
+func (s *MongoFileStore) ListFiles(query FileQuery) ([]types.FileRecord, int, error) {
+	filter := bson.M{}
+	if query.UploaderID != "" {
+		filter["uploaderId"] = query.UploaderID
+	}
+	if query.Folder != "" {
+		filter["folder"] = query.Folder
+	}
+
+	total, err := s.coll.CountDocuments(context.Background(), filter)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	opts := options.Find().SetSort(bson.D{{Key: "createdAt", Value: -1}, {Key: "_id", Value: -1}}).SetSkip(int64(query.Offset))
+	if query.Limit > 0 {
+		opts.SetLimit(int64(query.Limit))
+	}
+
+	cursor, err := s.coll.Find(context.Background(), filter, opts)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	files := []types.FileRecord{}
+	if err := cursor.All(context.Background(), &files); err != nil {
+		return nil, 0, err
+	}
+	return files, int(total), nil
+}
+
+func (s *MongoFileStore) DeleteFile(id string) error {
+	res, err := s.coll.DeleteOne(context.Background(), bson.M{"id": id})
+	if err != nil {
+		return err
+	}
+	if res.DeletedCount == 0 {
+		return ErrFileNotFound
+	}
+	return nil
+}
@@ -0,0 +1,80 @@
+package store
+
+import (
+	"context"
+	"time"
+
+	"github.com/froggy-12/purpurbase/types"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// MongoSessionStore uses the sessions collection, a ttl index on expiresAt cleans it up
+type MongoSessionStore struct {
+	coll *mongo.Collection
+}
+
+func NewMongoSessionStore(mongoClient *mongo.Client) *MongoSessionStore {
+	return &MongoSessionStore{coll: mongoClient.Database("purpurbase").Collection("sessions")}
+}
+
+func (s *MongoSessionStore) CreateSession(session types.Session) error {
+	_, err := s.coll.InsertOne(context.Background(), session)
+	return err
+}
+
+func (s *MongoSessionStore) FindSession(id string) (types.Session, error) {
+	session := types.Session{}
+	err := s.coll.FindOne(context.Background(), bson.M{"id": id, "expiresAt": bson.M{"$gt": time.Now()}}).Decode(&session)
+	if err == mongo.ErrNoDocuments {
+		return session, ErrSessionNotFound
+	}
+	return session, err
+}
+
+func (s *MongoSessionStore) ListUserSessions(userID string) ([]types.Session, error) {
+	cursor, err := s.coll.Find(context.Background(),
+		bson.M{"userId": userID, "expiresAt": bson.M{"$gt": time.Now()}},
+		options.Find().SetSort(bson.M{"lastUsedAt": -1}),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	sessions := []types.Session{}
+	if err := cursor.All(context.Background(), &sessions); err != nil {
+		return nil, err
+	}
+	return sessions, nil
+}
+
+func (s *MongoSessionStore) RotateRefreshToken(id, oldHash, newHash string, expiresAt time.Time) error {
+	res, err := s.coll.UpdateOne(context.Background(),
+		bson.M{"id": id, "refreshTokenHash": oldHash, "expiresAt": bson.M{"$gt": time.Now()}},
+		bson.M{"$set": bson.M{"refreshTokenHash": newHash, "previousRefreshTokenHash": oldHash, "lastUsedAt": time.Now(), "expiresAt": expiresAt}},
+	)
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return ErrSessionNotFound
+	}
+	return nil
+}
+
+func (s *MongoSessionStore) DeleteSession(id string) error {
+	res, err := s.coll.DeleteOne(context.Background(), bson.M{"id": id})
+	if err != nil {
+		return err
+	}
+	if res.DeletedCount == 0 {
+		return ErrSessionNotFound
+	}
+	return nil
+}
+
+func (s *MongoSessionStore) DeleteUserSessions(userID string) error {
+	_, err := s.coll.DeleteMany(context.Background(), bson.M{"userId": userID})
+	return err
+}
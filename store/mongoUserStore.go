@@ -0,0 +1,212 @@
+package store
+
+import (
+	"context"
+	"regexp"
+	"time"
+
+	"github.com/froggy-12/purpurbase/types"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type MongoUserStore struct {
+	coll *mongo.Collection
+}
+
+func NewMongoUserStore(mongoClient *mongo.Client) *MongoUserStore {
+	return &MongoUserStore{coll: mongoClient.Database("purpurbase").Collection("users")}
+}
+
+func (s *MongoUserStore) findOne(filter bson.M) (types.UserRecord, error) {
+	user := types.UserRecord{}
+	err := s.coll.FindOne(context.Background(), filter).Decode(&user)
+	if err == mongo.ErrNoDocuments {
+		return user, ErrUserNotFound
+	}
+	if user.RawData == nil {
+		user.RawData = map[string]any{}
+	}
+	return user, err
+}
+
+func (s *MongoUserStore) FindUserByID(id string) (types.UserRecord, error) {
+	return s.findOne(bson.M{"id": id})
+}
+
+func (s *MongoUserStore) FindUserByEmail(email string) (types.UserRecord, error) {
+	return s.findOne(bson.M{"email": email})
+}
+
+func (s *MongoUserStore) FindUserByUsername(username string) (types.UserRecord, error) {
+	return s.findOne(bson.M{"username": username})
+}
+
+func (s *MongoUserStore) FindUserByIdentity(provider, subject string) (types.UserRecord, error) {
+	return s.findOne(bson.M{"identities": bson.M{"$elemMatch": bson.M{"provider": provider, "subject": subject}}})
+}
+
+func (s *MongoUserStore) CreateUser(user types.UserRecord) error {
+	_, err := s.coll.InsertOne(context.Background(), user)
+	if mongo.IsDuplicateKeyError(err) {
+		return ErrUserAlreadyExists
+	}
+	return err
+}
+
+func (s *MongoUserStore) UpdateUser(user types.UserRecord) error {
+	user.UpdatedAt = time.Now()
+	res, err := s.coll.ReplaceOne(context.Background(), bson.M{"id": user.ID}, user)
+	if mongo.IsDuplicateKeyError(err) {
+		return ErrUserAlreadyExists
+	}
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return ErrUserNotFound
+	}
+	return nil
+}
+
+func (s *MongoUserStore) DeleteUser(id string) error {
+	res, err := s.coll.DeleteOne(context.Background(), bson.M{"id": id})
+	if err != nil {
+		return err
+	}
+	if res.DeletedCount == 0 {
+		return ErrUserNotFound
+	}
+	return nil
+}
+
+func (s *MongoUserStore) setFields(id string, fields bson.M) error {
+	res, err := s.coll.UpdateOne(context.Background(), bson.M{"id": id}, bson.M{"$set": fields})
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return ErrUserNotFound
+	}
+	return nil
+}
+
+func (s *MongoUserStore) SetVerified(id string, verified bool) error {
+	return s.setFields(id, bson.M{"verified": verified, "updatedAt": time.Now()})
+}
+
+func (s *MongoUserStore) TouchLastLogin(id string) error {
+	return s.setFields(id, bson.M{"lastLoggedIn": time.Now()})
+}
+
+func (s *MongoUserStore) SetMagicLink(id, tokenHash, codeHash string, expiresAt time.Time) error {
+	return s.setFields(id, bson.M{"magicLinkToken": tokenHash, "magicLinkCode": codeHash, "magicLinkExpiresAt": expiresAt})
+}
+
+// CountMagicLinkAttempt only matches while attempts are left so parallel requests can't pass the limit together
+func (s *MongoUserStore) CountMagicLinkAttempt(id string, limit int) error {
+	res, err := s.coll.UpdateOne(context.Background(), bson.M{"id": id, "magicLinkAttempts": bson.M{"$lt": limit}}, bson.M{"$inc": bson.M{"magicLinkAttempts": 1}})
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		if _, err := s.FindUserByID(id); err != nil {
+			return err
+		}
+		return ErrTooManyAttempts
+	}
+	return nil
+}
+
+// UseTOTPStep only matches an older step so the same code can't be accepted twice in parallel
+func (s *MongoUserStore) UseTOTPStep(id string, step int) error {
+	res, err := s.coll.UpdateOne(context.Background(), bson.M{"id": id, "totpLastStep": bson.M{"$lt": step}}, bson.M{"$set": bson.M{"totpLastStep": step}})
+	if err != nil {
+		return err
+	}
+	return s.codeUsed(id, res.MatchedCount)
+}
+
+// UseRecoveryCode only matches while the code is still stored so it can't be used twice in parallel
+func (s *MongoUserStore) UseRecoveryCode(id, hash string) error {
+	res, err := s.coll.UpdateOne(context.Background(), bson.M{"id": id, "totpRecoveryCodes": hash}, bson.M{"$pull": bson.M{"totpRecoveryCodes": hash}})
+	if err != nil {
+		return err
+	}
+	return s.codeUsed(id, res.MatchedCount)
+}
+
+// codeUsed tells a missing user apart from a code that was used already when a conditional update matched nothing
+func (s *MongoUserStore) codeUsed(id string, matched int64) error {
+	if matched > 0 {
+		return nil
+	}
+	if _, err := s.FindUserByID(id); err != nil {
+		return err
+	}
+	return ErrCodeAlreadyUsed
+}
+
+func (s *MongoUserStore) ListUsers(query UserQuery) ([]types.UserRecord, int, error) {
+	filter := bson.M{}
+	if query.Search != "" {
+		pattern := primitive.Regex{Pattern: regexp.QuoteMeta(query.Search), Options: "i"}
+		filter["$or"] = bson.A{bson.M{"email": pattern}, bson.M{"username": pattern}}
+	}
+	if query.Role != "" {
+		filter["roles"] = query.Role
+	}
+	if query.Verified != nil {
+		filter["verified"] = *query.Verified
+	}
+	if query.Disabled != nil {
+		// users created before the field existed don't have it at all
+		if *query.Disabled {
+			filter["disabled"] = true
+		} else {
+			filter["disabled"] = bson.M{"$ne": true}
+		}
+	}
+	if query.Anonymous != nil {
+		if *query.Anonymous {
+			filter["anonymous"] = true
+		} else {
+			filter["anonymous"] = bson.M{"$ne": true}
+		}
+	}
+	if !query.LastLoggedInBefore.IsZero() {
+		filter["lastLoggedIn"] = bson.M{"$lt": query.LastLoggedInBefore}
+	}
+
+	total, err := s.coll.CountDocuments(context.Background(), filter)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	direction := 1
+	if query.Desc {
+		direction = -1
+	}
+	opts := options.Find().SetSort(bson.D{{Key: query.sortField(), Value: direction}}).SetSkip(int64(query.Offset))
+	if query.Limit > 0 {
+		opts.SetLimit(int64(query.Limit))
+	}
+
+	cursor, err := s.coll.Find(context.Background(), filter, opts)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	users := []types.UserRecord{}
+	if err := cursor.All(context.Background(), &users); err != nil {
+		return nil, 0, err
+	}
+	for i := range users {
+		if users[i].RawData == nil {
+			users[i].RawData = map[string]any{}
+		}
+	}
+	return users, int(total), nil
+}
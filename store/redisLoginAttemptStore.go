@@ -0,0 +1,102 @@
+package store
+
+import (
+	"context"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// RedisLoginAttemptStore shares the counters between every instance, all keys expire on their own
+type RedisLoginAttemptStore struct {
+	client *redis.Client
+}
+
+func NewRedisLoginAttemptStore(client *redis.Client) *RedisLoginAttemptStore {
+	return &RedisLoginAttemptStore{client: client}
+}
+
+func loginFailuresKey(key string) string {
+	return "purpurbase:login-failures:" + key
+}
+
+func loginLockKey(key string) string {
+	return "purpurbase:login-lock:" + key
+}
+
+func loginLockoutsKey(key string) string {
+	return "purpurbase:login-lockouts:" + key
+}
+
+func unlockTokenKey(tokenHash string) string {
+	return "purpurbase:unlock-token:" + tokenHash
+}
+
+// incrementScript starts the ttl with the first increment so the window doesn't move with every failure
+var incrementScript = redis.NewScript(`
+local count = redis.call("INCR", KEYS[1])
+if count == 1 then
+	redis.call("PEXPIRE", KEYS[1], ARGV[1])
+end
+return count
+`)
+
+func (s *RedisLoginAttemptStore) incrementWithTTL(key string, ttl time.Duration) (int, error) {
+	return incrementScript.Run(context.Background(), s.client, []string{key}, ttl.Milliseconds()).Int()
+}
+
+func (s *RedisLoginAttemptStore) RecordFailure(key string, window time.Duration) (int, error) {
+	return s.incrementWithTTL(loginFailuresKey(key), window)
+}
+
+func (s *RedisLoginAttemptStore) Lock(key string, duration time.Duration) (int, error) {
+	ctx := context.Background()
+	pipe := s.client.TxPipeline()
+	pipe.Set(ctx, loginLockKey(key), "1", duration)
+	pipe.Del(ctx, loginFailuresKey(key))
+	if _, err := pipe.Exec(ctx); err != nil {
+		return 0, err
+	}
+	return s.incrementWithTTL(loginLockoutsKey(key), lockoutMemory)
+}
+
+func (s *RedisLoginAttemptStore) LockedFor(key string) (time.Duration, error) {
+	ttl, err := s.client.PTTL(context.Background(), loginLockKey(key)).Result()
+	if err != nil {
+		return 0, err
+	}
+	// -2 means the key doesn't exist, -1 that it has no ttl which never happens for locks
+	if ttl < 0 {
+		return 0, nil
+	}
+	return ttl, nil
+}
+
+func (s *RedisLoginAttemptStore) Lockouts(key string) (int, error) {
+	count, err := s.client.Get(context.Background(), loginLockoutsKey(key)).Int()
+	if err == redis.Nil {
+		return 0, nil
+	}
+	return count, err
+}
+
+func (s *RedisLoginAttemptStore) Unlock(key string) error {
+	return s.client.Del(context.Background(), loginLockKey(key), loginFailuresKey(key), loginLockoutsKey(key)).Err()
+}
+
+func (s *RedisLoginAttemptStore) SaveUnlockToken(tokenHash, key string, ttl time.Duration) error {
+	return s.client.Set(context.Background(), unlockTokenKey(tokenHash), key, ttl).Err()
+}
+
+func (s *RedisLoginAttemptStore) TakeUnlockToken(tokenHash string) (string, error) {
+	ctx := context.Background()
+	pipe := s.client.TxPipeline()
+	key := pipe.Get(ctx, unlockTokenKey(tokenHash))
+	pipe.Del(ctx, unlockTokenKey(tokenHash))
+	if _, err := pipe.Exec(ctx); err == redis.Nil {
+		return "", ErrUnlockTokenNotFound
+	} else if err != nil {
+		return "", err
+	}
+	return key.Val(), nil
+}
@@ -0,0 +1,163 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/froggy-12/purpurbase/types"
+	"github.com/redis/go-redis/v9"
+)
+
+// RedisSessionStore keeps every session in a hash that expires with the session,
+// a set per user holds the ids so sessions can be listed and revoked together
+type RedisSessionStore struct {
+	client *redis.Client
+}
+
+func NewRedisSessionStore(client *redis.Client) *RedisSessionStore {
+	return &RedisSessionStore{client: client}
+}
+
+func sessionKey(id string) string {
+	return "purpurbase:session:" + id
+}
+
+func userSessionsKey(userID string) string {
+	return "purpurbase:user-sessions:" + userID
+}
+
+func sessionToHash(session types.Session) map[string]any {
+	return map[string]any{
+		"id":                       session.ID,
+		"userId":                   session.UserID,
+		"refreshTokenHash":         session.RefreshTokenHash,
+		"previousRefreshTokenHash": session.PreviousRefreshTokenHash,
+		"userAgent":                session.UserAgent,
+		"ip":                       session.IP,
+		"createdAt":                session.CreatedAt.Format(time.RFC3339Nano),
+		"lastUsedAt":               session.LastUsedAt.Format(time.RFC3339Nano),
+		"expiresAt":                session.ExpiresAt.Format(time.RFC3339Nano),
+	}
+}
+
+func sessionFromHash(values map[string]string) types.Session {
+	parse := func(value string) time.Time {
+		t, _ := time.Parse(time.RFC3339Nano, value)
+		return t
+	}
+	return types.Session{
+		ID:                       values["id"],
+		UserID:                   values["userId"],
+		RefreshTokenHash:         values["refreshTokenHash"],
+		PreviousRefreshTokenHash: values["previousRefreshTokenHash"],
+		UserAgent:                values["userAgent"],
+		IP:                       values["ip"],
+		CreatedAt:                parse(values["createdAt"]),
+		LastUsedAt:               parse(values["lastUsedAt"]),
+		ExpiresAt:                parse(values["expiresAt"]),
+	}
+}
+
+func (s *RedisSessionStore) CreateSession(session types.Session) error {
+	ctx := context.Background()
+	_, err := s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(ctx, sessionKey(session.ID), sessionToHash(session))
+		pipe.ExpireAt(ctx, sessionKey(session.ID), session.ExpiresAt)
+		pipe.SAdd(ctx, userSessionsKey(session.UserID), session.ID)
+		return nil
+	})
+	return err
+}
+
+func (s *RedisSessionStore) FindSession(id string) (types.Session, error) {
+	values, err := s.client.HGetAll(context.Background(), sessionKey(id)).Result()
+	if err != nil {
+		return types.Session{}, err
+	}
+	if len(values) == 0 {
+		return types.Session{}, ErrSessionNotFound
+	}
+	return sessionFromHash(values), nil
+}
+
+func (s *RedisSessionStore) ListUserSessions(userID string) ([]types.Session, error) {
+	ctx := context.Background()
+	ids, err := s.client.SMembers(ctx, userSessionsKey(userID)).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	sessions := []types.Session{}
+	for _, id := range ids {
+		session, err := s.FindSession(id)
+		if err == ErrSessionNotFound {
+			// the hash expired, forget the id as well
+			s.client.SRem(ctx, userSessionsKey(userID), id)
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, session)
+	}
+	sort.Slice(sessions, func(i, j int) bool { return sessions[i].LastUsedAt.After(sessions[j].LastUsedAt) })
+	return sessions, nil
+}
+
+func (s *RedisSessionStore) RotateRefreshToken(id, oldHash, newHash string, expiresAt time.Time) error {
+	ctx := context.Background()
+	key := sessionKey(id)
+
+	err := s.client.Watch(ctx, func(tx *redis.Tx) error {
+		current, err := tx.HGet(ctx, key, "refreshTokenHash").Result()
+		if err == redis.Nil || (err == nil && current != oldHash) {
+			return ErrSessionNotFound
+		}
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.HSet(ctx, key, "refreshTokenHash", newHash, "previousRefreshTokenHash", oldHash, "lastUsedAt", time.Now().Format(time.RFC3339Nano), "expiresAt", expiresAt.Format(time.RFC3339Nano))
+			pipe.ExpireAt(ctx, key, expiresAt)
+			return nil
+		})
+		return err
+	}, key)
+
+	// somebody else rotated the token in between, only one of them can win
+	if errors.Is(err, redis.TxFailedErr) {
+		return ErrSessionNotFound
+	}
+	return err
+}
+
+func (s *RedisSessionStore) DeleteSession(id string) error {
+	ctx := context.Background()
+	session, err := s.FindSession(id)
+	if err != nil {
+		return err
+	}
+	_, err = s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Del(ctx, sessionKey(id))
+		pipe.SRem(ctx, userSessionsKey(session.UserID), id)
+		return nil
+	})
+	return err
+}
+
+func (s *RedisSessionStore) DeleteUserSessions(userID string) error {
+	ctx := context.Background()
+	ids, err := s.client.SMembers(ctx, userSessionsKey(userID)).Result()
+	if err != nil {
+		return err
+	}
+
+	keys := []string{userSessionsKey(userID)}
+	for _, id := range ids {
+		keys = append(keys, sessionKey(id))
+	}
+	return s.client.Del(ctx, keys...).Err()
+}
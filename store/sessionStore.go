@@ -0,0 +1,46 @@
+package store
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/froggy-12/purpurbase/config"
+	"github.com/froggy-12/purpurbase/types"
+	"github.com/redis/go-redis/v9"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var ErrSessionNotFound = errors.New("session not found")
+
+// SessionStore keeps the refresh token sessions, expired sessions are never returned
+type SessionStore interface {
+	CreateSession(session types.Session) error
+	FindSession(id string) (types.Session, error)
+	ListUserSessions(userID string) ([]types.Session, error)
+	// RotateRefreshToken swaps the token hash only if oldHash is still the current one and keeps oldHash as the
+	// previous one, otherwise it returns ErrSessionNotFound so reused tokens can be detected
+	RotateRefreshToken(id, oldHash, newHash string, expiresAt time.Time) error
+	DeleteSession(id string) error
+	DeleteUserSessions(userID string) error
+}
+
+// NewSessionStore uses redis when sessionStore is set to "redis" and the primary database otherwise
+func NewSessionStore(mongoClient *mongo.Client, sqlClient *sql.DB, redisClient *redis.Client) SessionStore {
+	if config.Configs.AuthenticationConfigurations.SessionStore == "redis" {
+		return NewRedisSessionStore(redisClient)
+	}
+
+	switch config.Configs.DatabaseConfigurations.DatabaseName {
+	case "mongodb":
+		return NewMongoSessionStore(mongoClient)
+	case "mysql":
+		return NewMySQLSessionStore(sqlClient)
+	case "postgresql":
+		return NewPostgresSessionStore(sqlClient)
+	default:
+		log.Fatal("Unsupported database")
+		return nil
+	}
+}
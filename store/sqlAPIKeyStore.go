@@ -0,0 +1,86 @@
+package store
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/froggy-12/purpurbase/types"
+)
+
+// SQLAPIKeyStore uses purpurbase.api_keys
+type SQLAPIKeyStore struct {
+	db       *sql.DB
+	postgres bool
+}
+
+func NewMySQLAPIKeyStore(db *sql.DB) *SQLAPIKeyStore {
+	return &SQLAPIKeyStore{db: db}
+}
+
+func NewPostgresAPIKeyStore(db *sql.DB) *SQLAPIKeyStore {
+	return &SQLAPIKeyStore{db: db, postgres: true}
+}
+
+func apiKeyFields(key *types.APIKey) []sqlField {
+	return []sqlField{
+		{"ID", &key.ID},
+		{"Name", &key.Name},
+		{"KeyHash", &key.KeyHash},
+		{"Scopes", &key.Scopes},
+		{"CreatedBy", &key.CreatedBy},
+		{"CreatedAt", &key.CreatedAt},
+		{"RotatedAt", &key.RotatedAt},
+		{"LastUsedAt", &key.LastUsedAt},
+		{"ExpiresAt", &key.ExpiresAt},
+	}
+}
+
+var apiKeyColumns = columnNames(apiKeyFields(&types.APIKey{}))
+
+func (s *SQLAPIKeyStore) CreateAPIKey(key types.APIKey) error {
+	_, err := s.db.Exec(rebind(s.postgres, "INSERT INTO purpurbase.api_keys ("+apiKeyColumns+") VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)"), scanTargets(apiKeyFields(&key))...)
+	return err
+}
+
+func (s *SQLAPIKeyStore) FindAPIKey(id string) (types.APIKey, error) {
+	var key types.APIKey
+	err := s.db.QueryRow(rebind(s.postgres, "SELECT "+apiKeyColumns+" FROM purpurbase.api_keys WHERE ID = ?"), id).
+		Scan(scanTargets(apiKeyFields(&key))...)
+	if err == sql.ErrNoRows || (err == nil && !apiKeyUsable(key)) {
+		return types.APIKey{}, ErrAPIKeyNotFound
+	}
+	return key, err
+}
+
+func (s *SQLAPIKeyStore) ListAPIKeys() ([]types.APIKey, error) {
+	rows, err := s.db.Query("SELECT " + apiKeyColumns + " FROM purpurbase.api_keys ORDER BY CreatedAt")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := []types.APIKey{}
+	for rows.Next() {
+		var key types.APIKey
+		if err := rows.Scan(scanTargets(apiKeyFields(&key))...); err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return keys, rows.Err()
+}
+
+func (s *SQLAPIKeyStore) RotateAPIKey(id, keyHash string) error {
+	res, err := s.db.Exec(rebind(s.postgres, "UPDATE purpurbase.api_keys SET KeyHash = ?, RotatedAt = ? WHERE ID = ?"), keyHash, time.Now(), id)
+	return checkAffected(res, err, ErrAPIKeyNotFound)
+}
+
+func (s *SQLAPIKeyStore) TouchAPIKey(id string) error {
+	res, err := s.db.Exec(rebind(s.postgres, "UPDATE purpurbase.api_keys SET LastUsedAt = ? WHERE ID = ?"), time.Now(), id)
+	return checkAffected(res, err, ErrAPIKeyNotFound)
+}
+
+func (s *SQLAPIKeyStore) DeleteAPIKey(id string) error {
+	res, err := s.db.Exec(rebind(s.postgres, "DELETE FROM purpurbase.api_keys WHERE ID = ?"), id)
+	return checkAffected(res, err, ErrAPIKeyNotFound)
+}
@@ -0,0 +1,117 @@
+package store
+
+import (
+	"database/sql"
+	"strings"
+
+	"github.com/froggy-12/purpurbase/types"
+)
+
+// SQLAuditStore uses purpurbase.audit_log, the auto increment Seq column keeps the order of events
+// appended within the same instant
+type SQLAuditStore struct {
+	db       *sql.DB
+	postgres bool
+}
+
+func NewMySQLAuditStore(db *sql.DB) *SQLAuditStore {
+	return &SQLAuditStore{db: db}
+}
+
+func NewPostgresAuditStore(db *sql.DB) *SQLAuditStore {
+	return &SQLAuditStore{db: db, postgres: true}
+}
+
+func auditEventFields(event *types.AuditEvent) []sqlField {
+	return []sqlField{
+		{"ID", &event.ID},
+		{"Type", &event.Type},
+		{"UserID", &event.UserID},
+		{"ActorID", &event.ActorID},
+		{"Email", &event.Email},
+		{"Success", &event.Success},
+		{"IP", &event.IP},
+		{"UserAgent", &event.UserAgent},
+		{"Details", &event.Details},
+		{"CreatedAt", &event.CreatedAt},
+	}
+}
+
+var auditEventColumns = columnNames(auditEventFields(&types.AuditEvent{}))
+
+func (s *SQLAuditStore) AppendAuditEvent(event types.AuditEvent) error {
+	_, err := s.db.Exec(rebind(s.postgres, "INSERT INTO purpurbase.audit_log ("+auditEventColumns+") VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)"), scanTargets(auditEventFields(&event))...)
+	return err
+}
+
+func (s *SQLAuditStore) ListAuditEvents(query AuditQuery) ([]types.AuditEvent, int, error) {
+	var conditions []string
+	var args []any
+
+	if len(query.Types) > 0 {
+		conditions = append(conditions, "Type IN (?"+strings.Repeat(", ?", len(query.Types)-1)+")")
+		for _, eventType := range query.Types {
+			args = append(args, eventType)
+		}
+	}
+	if query.UserID != "" {
+		conditions = append(conditions, "UserID = ?")
+		args = append(args, query.UserID)
+	}
+	if query.ActorID != "" {
+		conditions = append(conditions, "ActorID = ?")
+		args = append(args, query.ActorID)
+	}
+	if query.Email != "" {
+		conditions = append(conditions, "LOWER(Email) = ?")
+		args = append(args, strings.ToLower(query.Email))
+	}
+	if query.IP != "" {
+		conditions = append(conditions, "IP = ?")
+		args = append(args, query.IP)
+	}
+	if query.Success != nil {
+		conditions = append(conditions, "Success = ?")
+		args = append(args, *query.Success)
+	}
+	if !query.Since.IsZero() {
+		conditions = append(conditions, "CreatedAt >= ?")
+		args = append(args, query.Since)
+	}
+	if !query.Until.IsZero() {
+		conditions = append(conditions, "CreatedAt < ?")
+		args = append(args, query.Until)
+	}
+
+	where := ""
+	if len(conditions) > 0 {
+		where = " WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	var total int
+	if err := s.db.QueryRow(rebind(s.postgres, "SELECT COUNT(*) FROM purpurbase.audit_log"+where), args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	order := " ORDER BY Seq DESC"
+	if query.Limit > 0 {
+		order += " LIMIT ? OFFSET ?"
+		args = append(args, query.Limit, query.Offset)
+	}
+
+	rows, err := s.db.Query(rebind(s.postgres, "SELECT "+auditEventColumns+" FROM purpurbase.audit_log"+where+order), args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	events := []types.AuditEvent{}
+	for rows.Next() {
+		var event types.AuditEvent
+		if err := rows.Scan(scanTargets(auditEventFields(&event))...); err != nil {
+			return nil, 0, err
+		}
+		events = append(events, event)
+	}
+	return events, total, rows.Err()
+}
@@ -0,0 +1,149 @@
+package store
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// sqlField binds a struct field to its column, it is both the scan target and the query argument
+// so NULLs, mysql tinyint booleans and json columns are handled in one place
+type sqlField struct {
+	column string
+	ptr    any
+}
+
+func columnNames(fields []sqlField) string {
+	names := make([]string, len(fields))
+	for i, field := range fields {
+		names[i] = field.column
+	}
+	return strings.Join(names, ", ")
+}
+
+func scanTargets(fields []sqlField) []any {
+	targets := make([]any, len(fields))
+	for i, field := range fields {
+		targets[i] = field
+	}
+	return targets
+}
+
+func (f sqlField) Scan(src any) error {
+	if b, ok := src.([]byte); ok {
+		src = string(b)
+	}
+
+	switch ptr := f.ptr.(type) {
+	case *string:
+		switch v := src.(type) {
+		case nil:
+			*ptr = ""
+		case string:
+			*ptr = v
+		default:
+			*ptr = fmt.Sprint(v)
+		}
+	case *bool:
+		switch v := src.(type) {
+		case nil:
+			*ptr = false
+		case bool:
+			*ptr = v
+		case int64:
+			*ptr = v != 0
+		case string:
+			*ptr = v == "1" || strings.EqualFold(v, "true")
+		default:
+			return fmt.Errorf("column %s: cannot scan %T into bool", f.column, src)
+		}
+	case *int:
+		switch v := src.(type) {
+		case nil:
+			*ptr = 0
+		case int64:
+			*ptr = int(v)
+		case string:
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				return fmt.Errorf("column %s: %w", f.column, err)
+			}
+			*ptr = n
+		default:
+			return fmt.Errorf("column %s: cannot scan %T into int", f.column, src)
+		}
+	case *int64:
+		switch v := src.(type) {
+		case nil:
+			*ptr = 0
+		case int64:
+			*ptr = v
+		case string:
+			n, err := strconv.ParseInt(v, 10, 64)
+			if err != nil {
+				return fmt.Errorf("column %s: %w", f.column, err)
+			}
+			*ptr = n
+		default:
+			return fmt.Errorf("column %s: cannot scan %T into int64", f.column, src)
+		}
+	case *time.Time:
+		switch v := src.(type) {
+		case nil:
+			*ptr = time.Time{}
+		case time.Time:
+			*ptr = v
+		default:
+			return fmt.Errorf("column %s: cannot scan %T into time", f.column, src)
+		}
+	default:
+		// everything else is stored as json
+		switch v := src.(type) {
+		case nil:
+			return nil
+		case string:
+			if v == "" {
+				return nil
+			}
+			return json.Unmarshal([]byte(v), f.ptr)
+		default:
+			return fmt.Errorf("column %s: cannot scan %T into json", f.column, src)
+		}
+	}
+	return nil
+}
+
+func (f sqlField) Value() (driver.Value, error) {
+	switch ptr := f.ptr.(type) {
+	case *string:
+		return *ptr, nil
+	case *bool:
+		return *ptr, nil
+	case *int:
+		return int64(*ptr), nil
+	case *int64:
+		return *ptr, nil
+	case *time.Time:
+		if ptr.IsZero() {
+			return nil, nil
+		}
+		return *ptr, nil
+	case *map[string]any:
+		if *ptr == nil {
+			return "{}", nil
+		}
+	case *[]string:
+		if *ptr == nil {
+			return "[]", nil
+		}
+	}
+
+	data, err := json.Marshal(f.ptr)
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
@@ -0,0 +1,112 @@
+package store
+
+import (
+	"database/sql"
+	"strings"
+
+	"github.com/froggy-12/purpurbase/types"
+)
+
+// SQLFileStore uses purpurbase.files
+type SQLFileStore struct {
+	db       *sql.DB
+	postgres bool
+}
+
+func NewMySQLFileStore(db *sql.DB) *SQLFileStore {
+	return &SQLFileStore{db: db}
+}
+
+func NewPostgresFileStore(db *sql.DB) *SQLFileStore {
+	return &SQLFileStore{db: db, postgres: true}
+}
+
+func fileFields(file *types.FileRecord) []sqlField {
+	return []sqlField{
+		{"ID", &file.ID},
+		{"Folder", &file.Folder},
+		{"FileName", &file.FileName},
+		{"OriginalName", &file.OriginalName},
+		{"Size", &file.Size},
+		{"ContentType", &file.ContentType},
+		{"Checksum", &file.Checksum},
+		{"UploaderID", &file.UploaderID},
+		{"CreatedAt", &file.CreatedAt},
+	}
+}
+
+var fileColumns = columnNames(fileFields(&types.FileRecord{}))
+
+func (s *SQLFileStore) CreateFile(file types.FileRecord) error {
+	_, err := s.db.Exec(rebind(s.postgres, "INSERT INTO purpurbase.files ("+fileColumns+") VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)"), scanTargets(fileFields(&file))...)
+	return err
+}
+
+func (s *SQLFileStore) findOne(where string, args ...any) (types.FileRecord, error) {
+	var file types.FileRecord
+	err := s.db.QueryRow(rebind(s.postgres, "SELECT "+fileColumns+" FROM purpurbase.files WHERE "+where), args...).
+		Scan(scanTargets(fileFields(&file))...)
+	if err == sql.ErrNoRows {
+		return types.FileRecord{}, ErrFileNotFound
+	}
+	return file, err
+}
+
+func (s *SQLFileStore) FindFile(id string) (types.FileRecord, error) {
+	return s.findOne("ID = ?", id)
+}
+
+func (s *SQLFileStore) FindFileByName(folder, fileName string) (types.FileRecord, error) {
+	return s.findOne("Folder = ? AND FileName = ?", folder, fileName)
+}
+
+func (s *SQLFileStore) ListFiles(query FileQuery) ([]types.FileRecord, int, error) {
+	var conditions []string
+	var args []any
+
+	if query.UploaderID != "" {
+		conditions = append(conditions, "UploaderID = ?")
+		args = append(args, query.UploaderID)
+	}
+	if query.Folder != "" {
+		conditions = append(conditions, "Folder = ?")
+		args = append(args, query.Folder)
+	}
+
+	where := ""
+	if len(conditions) > 0 {
+		where = " WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	var total int
+	if err := s.db.QueryRow(rebind(s.postgres, "SELECT COUNT(*) FROM purpurbase.files"+where), args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	order := " ORDER BY CreatedAt DESC, ID"
+	if query.Limit > 0 {
+		order += " LIMIT ? OFFSET ?"
+		args = append(args, query.Limit, query.Offset)
+	}
+
+	rows, err := s.db.Query(rebind(s.postgres, "SELECT "+fileColumns+" FROM purpurbase.files"+where+order), args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	files := []types.FileRecord{}
+	for rows.Next() {
+		var file types.FileRecord
+		if err := rows.Scan(scanTargets(fileFields(&file))...); err != nil {
+			return nil, 0, err
+		}
+		files = append(files, file)
+	}
+	return files, total, rows.Err()
+}
+
+func (s *SQLFileStore) DeleteFile(id string) error {
+	res, err := s.db.Exec(rebind(s.postgres, "DELETE FROM purpurbase.files WHERE ID = ?"), id)
+	return checkAffected(res, err, ErrFileNotFound)
+}
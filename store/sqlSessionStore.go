@@ -0,0 +1,92 @@
+package store
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/froggy-12/purpurbase/types"
+)
+
+// SQLSessionStore uses purpurbase.sessions, expired rows are skipped and removed whenever a session is created
+type SQLSessionStore struct {
+	db       *sql.DB
+	postgres bool
+}
+
+func NewMySQLSessionStore(db *sql.DB) *SQLSessionStore {
+	return &SQLSessionStore{db: db}
+}
+
+func NewPostgresSessionStore(db *sql.DB) *SQLSessionStore {
+	return &SQLSessionStore{db: db, postgres: true}
+}
+
+func sessionFields(session *types.Session) []sqlField {
+	return []sqlField{
+		{"ID", &session.ID},
+		{"UserID", &session.UserID},
+		{"RefreshTokenHash", &session.RefreshTokenHash},
+		{"PreviousRefreshTokenHash", &session.PreviousRefreshTokenHash},
+		{"UserAgent", &session.UserAgent},
+		{"IP", &session.IP},
+		{"CreatedAt", &session.CreatedAt},
+		{"LastUsedAt", &session.LastUsedAt},
+		{"ExpiresAt", &session.ExpiresAt},
+	}
+}
+
+var sessionColumns = columnNames(sessionFields(&types.Session{}))
+
+func (s *SQLSessionStore) CreateSession(session types.Session) error {
+	if _, err := s.db.Exec(rebind(s.postgres, "DELETE FROM purpurbase.sessions WHERE ExpiresAt < ?"), time.Now()); err != nil {
+		return err
+	}
+
+	fields := sessionFields(&session)
+	_, err := s.db.Exec(rebind(s.postgres, "INSERT INTO purpurbase.sessions ("+sessionColumns+") VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)"), scanTargets(fields)...)
+	return err
+}
+
+func (s *SQLSessionStore) FindSession(id string) (types.Session, error) {
+	var session types.Session
+	err := s.db.QueryRow(rebind(s.postgres, "SELECT "+sessionColumns+" FROM purpurbase.sessions WHERE ID = ? AND ExpiresAt > ?"), id, time.Now()).
+		Scan(scanTargets(sessionFields(&session))...)
+	if err == sql.ErrNoRows {
+		return session, ErrSessionNotFound
+	}
+	return session, err
+}
+
+func (s *SQLSessionStore) ListUserSessions(userID string) ([]types.Session, error) {
+	rows, err := s.db.Query(rebind(s.postgres, "SELECT "+sessionColumns+" FROM purpurbase.sessions WHERE UserID = ? AND ExpiresAt > ? ORDER BY LastUsedAt DESC"), userID, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sessions := []types.Session{}
+	for rows.Next() {
+		var session types.Session
+		if err := rows.Scan(scanTargets(sessionFields(&session))...); err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, session)
+	}
+	return sessions, rows.Err()
+}
+
+func (s *SQLSessionStore) RotateRefreshToken(id, oldHash, newHash string, expiresAt time.Time) error {
+	res, err := s.db.Exec(rebind(s.postgres, "UPDATE purpurbase.sessions SET RefreshTokenHash = ?, PreviousRefreshTokenHash = ?, LastUsedAt = ?, ExpiresAt = ? WHERE ID = ? AND RefreshTokenHash = ? AND ExpiresAt > ?"),
+		newHash, oldHash, time.Now(), expiresAt, id, oldHash, time.Now())
+	return checkAffected(res, err, ErrSessionNotFound)
+}
+
+func (s *SQLSessionStore) DeleteSession(id string) error {
+	res, err := s.db.Exec(rebind(s.postgres, "DELETE FROM purpurbase.sessions WHERE ID = ?"), id)
+	return checkAffected(res, err, ErrSessionNotFound)
+}
+
+func (s *SQLSessionStore) DeleteUserSessions(userID string) error {
+	_, err := s.db.Exec(rebind(s.postgres, "DELETE FROM purpurbase.sessions WHERE UserID = ?"), userID)
+	return err
+}
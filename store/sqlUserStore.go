@@ -0,0 +1,370 @@
+package store
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/froggy-12/purpurbase/types"
+	"github.com/go-sql-driver/mysql"
+	"github.com/lib/pq"
+)
+
+// SQLUserStore works for mysql and postgresql, the only difference between them is the placeholder style
+type SQLUserStore struct {
+	db       *sql.DB
+	postgres bool
+}
+
+func NewMySQLUserStore(db *sql.DB) *SQLUserStore {
+	return &SQLUserStore{db: db}
+}
+
+func NewPostgresUserStore(db *sql.DB) *SQLUserStore {
+	return &SQLUserStore{db: db, postgres: true}
+}
+
+func userFields(user *types.UserRecord) []sqlField {
+	return []sqlField{
+		{"ID", &user.ID},
+		{"UserName", &user.UserName},
+		{"FirstName", &user.FirstName},
+		{"LastName", &user.LastName},
+		{"Email", &user.Email},
+		{"Password", &user.Password},
+		{"BirthDay", &user.BirthDay},
+		{"ProfilePicture", &user.ProfilePicture},
+		{"CreatedAt", &user.CreatedAt},
+		{"UpdatedAt", &user.UpdatedAt},
+		{"Verified", &user.Verified},
+		{"VerificationToken", &user.VerificationToken},
+		{"LastLoggedIn", &user.LastLoggedIn},
+		{"RawData", &user.RawData},
+		{"Roles", &user.Roles},
+		{"Permissions", &user.Permissions},
+		{"Disabled", &user.Disabled},
+		{"Anonymous", &user.Anonymous},
+		{"Identities", &user.Identities},
+		{"PasswordResetToken", &user.PasswordResetToken},
+		{"PasswordResetExpiresAt", &user.PasswordResetExpiresAt},
+		{"PasswordHistory", &user.PasswordHistory},
+		{"PendingEmail", &user.PendingEmail},
+		{"EmailChangeToken", &user.EmailChangeToken},
+		{"EmailChangeExpiresAt", &user.EmailChangeExpiresAt},
+		{"EmailRevertToken", &user.EmailRevertToken},
+		{"EmailRevertAddress", &user.EmailRevertAddress},
+		{"EmailRevertExpiresAt", &user.EmailRevertExpiresAt},
+		{"TOTPEnabled", &user.TOTPEnabled},
+		{"TOTPSecret", &user.TOTPSecret},
+		{"TOTPRecoveryCodes", &user.TOTPRecoveryCodes},
+		{"TOTPLastStep", &user.TOTPLastStep},
+		{"MagicLinkToken", &user.MagicLinkToken},
+		{"MagicLinkCode", &user.MagicLinkCode},
+		{"MagicLinkExpiresAt", &user.MagicLinkExpiresAt},
+		{"MagicLinkAttempts", &user.MagicLinkAttempts},
+	}
+}
+
+var userColumns = columnNames(userFields(&types.UserRecord{}))
+
+// rebind turns ? placeholders into $1, $2... for postgresql
+func rebind(postgres bool, query string) string {
+	if !postgres {
+		return query
+	}
+	var b strings.Builder
+	n := 0
+	for _, r := range query {
+		if r == '?' {
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+func isDuplicateError(err error) bool {
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) {
+		return mysqlErr.Number == 1062
+	}
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		return pqErr.Code == "23505"
+	}
+	return false
+}
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanUser(row rowScanner) (types.UserRecord, error) {
+	var user types.UserRecord
+	if err := row.Scan(scanTargets(userFields(&user))...); err != nil {
+		return types.UserRecord{}, err
+	}
+	if user.RawData == nil {
+		user.RawData = map[string]any{}
+	}
+	return user, nil
+}
+
+func (s *SQLUserStore) findOne(column, value string) (types.UserRecord, error) {
+	query := rebind(s.postgres, "SELECT "+userColumns+" FROM purpurbase.users WHERE "+column+" = ?;")
+	user, err := scanUser(s.db.QueryRow(query, value))
+	if err == sql.ErrNoRows {
+		return user, ErrUserNotFound
+	}
+	return user, err
+}
+
+func (s *SQLUserStore) FindUserByID(id string) (types.UserRecord, error) {
+	return s.findOne("ID", id)
+}
+
+func (s *SQLUserStore) FindUserByEmail(email string) (types.UserRecord, error) {
+	return s.findOne("Email", email)
+}
+
+func (s *SQLUserStore) FindUserByUsername(username string) (types.UserRecord, error) {
+	return s.findOne("UserName", username)
+}
+
+// FindUserByIdentity matches the json of the identities column, the match is checked again on the decoded user
+func (s *SQLUserStore) FindUserByIdentity(provider, subject string) (types.UserRecord, error) {
+	pair, err := json.Marshal(struct {
+		Provider string `json:"provider"`
+		Subject  string `json:"subject"`
+	}{provider, subject})
+	if err != nil {
+		return types.UserRecord{}, err
+	}
+	pattern := "%" + escapeLike(strings.Trim(string(pair), "{}")) + "%"
+
+	rows, err := s.db.Query(rebind(s.postgres, "SELECT "+userColumns+" FROM purpurbase.users WHERE Identities LIKE ?"), pattern)
+	if err != nil {
+		return types.UserRecord{}, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			return types.UserRecord{}, err
+		}
+		if hasIdentity(user, provider, subject) {
+			return user, nil
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return types.UserRecord{}, err
+	}
+	return types.UserRecord{}, ErrUserNotFound
+}
+
+func (s *SQLUserStore) CreateUser(user types.UserRecord) error {
+	if user.CreatedAt.IsZero() {
+		user.CreatedAt = time.Now()
+	}
+	if user.UpdatedAt.IsZero() {
+		user.UpdatedAt = user.CreatedAt
+	}
+
+	fields := userFields(&user)
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(fields)), ", ")
+
+	_, err := s.db.Exec(rebind(s.postgres, "INSERT INTO purpurbase.users ("+userColumns+") VALUES ("+placeholders+")"), scanTargets(fields)...)
+	if isDuplicateError(err) {
+		return ErrUserAlreadyExists
+	}
+	return err
+}
+
+func (s *SQLUserStore) UpdateUser(user types.UserRecord) error {
+	user.UpdatedAt = time.Now()
+
+	var assignments []string
+	var args []any
+	for _, field := range userFields(&user) {
+		if field.column == "ID" || field.column == "CreatedAt" {
+			continue
+		}
+		assignments = append(assignments, field.column+" = ?")
+		args = append(args, field)
+	}
+	args = append(args, user.ID)
+
+	res, err := s.db.Exec(rebind(s.postgres, "UPDATE purpurbase.users SET "+strings.Join(assignments, ", ")+" WHERE ID = ?"), args...)
+	if isDuplicateError(err) {
+		return ErrUserAlreadyExists
+	}
+	return checkAffected(res, err, ErrUserNotFound)
+}
+
+func (s *SQLUserStore) DeleteUser(id string) error {
+	res, err := s.db.Exec(rebind(s.postgres, "DELETE FROM purpurbase.users WHERE ID = ?"), id)
+	return checkAffected(res, err, ErrUserNotFound)
+}
+
+func (s *SQLUserStore) SetVerified(id string, verified bool) error {
+	res, err := s.db.Exec(rebind(s.postgres, "UPDATE purpurbase.users SET Verified = ?, UpdatedAt = ? WHERE ID = ?"), verified, time.Now(), id)
+	return checkAffected(res, err, ErrUserNotFound)
+}
+
+func (s *SQLUserStore) TouchLastLogin(id string) error {
+	res, err := s.db.Exec(rebind(s.postgres, "UPDATE purpurbase.users SET LastLoggedIn = ? WHERE ID = ?"), time.Now(), id)
+	return checkAffected(res, err, ErrUserNotFound)
+}
+
+func (s *SQLUserStore) SetMagicLink(id, tokenHash, codeHash string, expiresAt time.Time) error {
+	res, err := s.db.Exec(rebind(s.postgres, "UPDATE purpurbase.users SET MagicLinkToken = ?, MagicLinkCode = ?, MagicLinkExpiresAt = ? WHERE ID = ?"), tokenHash, codeHash, expiresAt, id)
+	return checkAffected(res, err, ErrUserNotFound)
+}
+
+// CountMagicLinkAttempt only updates while attempts are left so parallel requests can't pass the limit together
+func (s *SQLUserStore) CountMagicLinkAttempt(id string, limit int) error {
+	res, err := s.db.Exec(rebind(s.postgres, "UPDATE purpurbase.users SET MagicLinkAttempts = MagicLinkAttempts + 1 WHERE ID = ? AND MagicLinkAttempts < ?"), id, limit)
+	if err := checkAffected(res, err, ErrTooManyAttempts); err != ErrTooManyAttempts {
+		return err
+	}
+	if _, err := s.FindUserByID(id); err != nil {
+		return err
+	}
+	return ErrTooManyAttempts
+}
+
+// UseTOTPStep only updates an older step so the same code can't be accepted twice in parallel
+func (s *SQLUserStore) UseTOTPStep(id string, step int) error {
+	res, err := s.db.Exec(rebind(s.postgres, "UPDATE purpurbase.users SET TOTPLastStep = ? WHERE ID = ? AND TOTPLastStep < ?"), step, id, step)
+	return s.codeUsed(id, checkAffected(res, err, ErrCodeAlreadyUsed))
+}
+
+// UseRecoveryCode only updates while the stored codes are unchanged so a code can't be used twice in parallel
+func (s *SQLUserStore) UseRecoveryCode(id, hash string) error {
+	user, err := s.FindUserByID(id)
+	if err != nil {
+		return err
+	}
+	index := slices.Index(user.TOTPRecoveryCodes, hash)
+	if index < 0 {
+		return ErrCodeAlreadyUsed
+	}
+	codes := slices.Delete(slices.Clone(user.TOTPRecoveryCodes), index, index+1)
+
+	res, err := s.db.Exec(rebind(s.postgres, "UPDATE purpurbase.users SET TOTPRecoveryCodes = ? WHERE ID = ? AND TOTPRecoveryCodes = ?"),
+		sqlField{"TOTPRecoveryCodes", &codes}, id, sqlField{"TOTPRecoveryCodes", &user.TOTPRecoveryCodes})
+	return s.codeUsed(id, checkAffected(res, err, ErrCodeAlreadyUsed))
+}
+
+// codeUsed tells a missing user apart from a code that was used already when a conditional update changed nothing
+func (s *SQLUserStore) codeUsed(id string, err error) error {
+	if err != ErrCodeAlreadyUsed {
+		return err
+	}
+	if _, err := s.FindUserByID(id); err != nil {
+		return err
+	}
+	return ErrCodeAlreadyUsed
+}
+
+func checkAffected(res sql.Result, err error, notFound error) error {
+	if err != nil {
+		return err
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return notFound
+	}
+	return nil
+}
+
+var userSortColumns = map[string]string{
+	"createdAt":    "CreatedAt",
+	"updatedAt":    "UpdatedAt",
+	"lastLoggedIn": "LastLoggedIn",
+	"email":        "Email",
+	"username":     "UserName",
+}
+
+// escapeLike makes % and _ in user input match literally, both mysql and postgresql use \ as the default escape
+func escapeLike(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(value)
+}
+
+func (s *SQLUserStore) ListUsers(query UserQuery) ([]types.UserRecord, int, error) {
+	var conditions []string
+	var args []any
+
+	if query.Search != "" {
+		pattern := "%" + escapeLike(strings.ToLower(query.Search)) + "%"
+		conditions = append(conditions, "(LOWER(Email) LIKE ? OR LOWER(UserName) LIKE ?)")
+		args = append(args, pattern, pattern)
+	}
+	if query.Role != "" {
+		// roles are stored as a json array of strings
+		role, _ := json.Marshal(query.Role)
+		conditions = append(conditions, "Roles LIKE ?")
+		args = append(args, "%"+escapeLike(string(role))+"%")
+	}
+	if query.Verified != nil {
+		conditions = append(conditions, "Verified = ?")
+		args = append(args, *query.Verified)
+	}
+	if query.Disabled != nil {
+		conditions = append(conditions, "Disabled = ?")
+		args = append(args, *query.Disabled)
+	}
+	if query.Anonymous != nil {
+		conditions = append(conditions, "Anonymous = ?")
+		args = append(args, *query.Anonymous)
+	}
+	if !query.LastLoggedInBefore.IsZero() {
+		conditions = append(conditions, "LastLoggedIn < ?")
+		args = append(args, query.LastLoggedInBefore)
+	}
+
+	where := ""
+	if len(conditions) > 0 {
+		where = " WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	var total int
+	if err := s.db.QueryRow(rebind(s.postgres, "SELECT COUNT(*) FROM purpurbase.users"+where), args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	order := " ORDER BY " + userSortColumns[query.sortField()]
+	if query.Desc {
+		order += " DESC"
+	}
+	if query.Limit > 0 {
+		order += " LIMIT ? OFFSET ?"
+		args = append(args, query.Limit, query.Offset)
+	}
+
+	rows, err := s.db.Query(rebind(s.postgres, "SELECT "+userColumns+" FROM purpurbase.users"+where+order), args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	users := []types.UserRecord{}
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		users = append(users, user)
+	}
+	return users, total, rows.Err()
+}
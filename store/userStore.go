@@ -0,0 +1,91 @@
+package store
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"slices"
+	"time"
+
+	"github.com/froggy-12/purpurbase/config"
+	"github.com/froggy-12/purpurbase/types"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var (
+	ErrUserNotFound      = errors.New("user not found")
+	ErrUserAlreadyExists = errors.New("user with the same email or username already exists")
+	ErrTooManyAttempts   = errors.New("too many attempts")
+	ErrCodeAlreadyUsed   = errors.New("code already used")
+)
+
+// UserStore hides the primary database from the auth handlers, every DatabaseName has one
+type UserStore interface {
+	FindUserByID(id string) (types.UserRecord, error)
+	FindUserByEmail(email string) (types.UserRecord, error)
+	FindUserByUsername(username string) (types.UserRecord, error)
+	// FindUserByIdentity finds the user the provider account is linked to
+	FindUserByIdentity(provider, subject string) (types.UserRecord, error)
+	CreateUser(user types.UserRecord) error
+	// UpdateUser overwrites every stored field of the user with the same ID and bumps UpdatedAt
+	UpdateUser(user types.UserRecord) error
+	DeleteUser(id string) error
+	SetVerified(id string, verified bool) error
+	TouchLastLogin(id string) error
+	// SetMagicLink stores a pending magic link log in, the wrong codes counted so far are kept
+	SetMagicLink(id, tokenHash, codeHash string, expiresAt time.Time) error
+	// CountMagicLinkAttempt atomically counts a code about to be checked, ErrTooManyAttempts once limit codes were tried
+	CountMagicLinkAttempt(id string, limit int) error
+	// UseTOTPStep atomically moves TOTPLastStep forward, ErrCodeAlreadyUsed when step was already accepted
+	UseTOTPStep(id string, step int) error
+	// UseRecoveryCode atomically removes the recovery code hash, ErrCodeAlreadyUsed when it is gone already
+	UseRecoveryCode(id, hash string) error
+	// ListUsers returns one page of the users matching the query and the number of all matching users
+	ListUsers(query UserQuery) ([]types.UserRecord, int, error)
+}
+
+// UserQuery filters and sorts ListUsers, zero values mean no filter
+type UserQuery struct {
+	Search             string // part of the email or the username, case insensitive
+	Role               string
+	Verified           *bool
+	Disabled           *bool
+	Anonymous          *bool     // only guests or only full accounts
+	LastLoggedInBefore time.Time // only users that haven't logged in since
+	SortBy             string    // one of UserSortFields, createdAt by default
+	Desc               bool
+	Limit              int
+	Offset             int
+}
+
+var UserSortFields = []string{"createdAt", "updatedAt", "lastLoggedIn", "email", "username"}
+
+func (q UserQuery) sortField() string {
+	if slices.Contains(UserSortFields, q.SortBy) {
+		return q.SortBy
+	}
+	return "createdAt"
+}
+
+func hasIdentity(user types.UserRecord, provider, subject string) bool {
+	for _, identity := range user.Identities {
+		if identity.Provider == provider && identity.Subject == subject {
+			return true
+		}
+	}
+	return false
+}
+
+func NewUserStore(mongoClient *mongo.Client, sqlClient *sql.DB) UserStore {
+	switch config.Configs.DatabaseConfigurations.DatabaseName {
+	case "mongodb":
+		return NewMongoUserStore(mongoClient)
+	case "mysql":
+		return NewMySQLUserStore(sqlClient)
+	case "postgresql":
+		return NewPostgresUserStore(sqlClient)
+	default:
+		log.Fatal("Unsupported database")
+		return nil
+	}
+}
@@ -0,0 +1,134 @@
+package store
+
+import (
+	"testing"
+	"time"
+
+	"github.com/froggy-12/purpurbase/types"
+)
+
+// testUserStore is the behaviour every UserStore shares, the handlers rely on it whatever the database is
+func testUserStore(t *testing.T, userStore UserStore) {
+	t.Helper()
+	now := time.Now().Truncate(time.Millisecond)
+	user := types.UserRecord{
+		ID: "user", Email: "user@example.com", UserName: "user", Password: "hash", CreatedAt: now,
+		RawData:    map[string]any{"plan": "free"},
+		Roles:      []string{"user"},
+		Identities: []types.Identity{{Provider: "github", Subject: "1", Email: "user@example.com"}},
+	}
+	if err := userStore.CreateUser(user); err != nil {
+		t.Fatal(err)
+	}
+	if err := userStore.CreateUser(types.UserRecord{ID: "other", Email: "other@example.com", UserName: "other", CreatedAt: now.Add(time.Second), Verified: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, duplicate := range map[string]types.UserRecord{
+		"id":       {ID: "user", Email: "new@example.com", UserName: "new"},
+		"email":    {ID: "new", Email: "user@example.com", UserName: "new"},
+		"username": {ID: "new", Email: "new@example.com", UserName: "user"},
+	} {
+		if err := userStore.CreateUser(duplicate); err != ErrUserAlreadyExists {
+			t.Errorf("creating a user with a taken %s returned %v, want ErrUserAlreadyExists", name, err)
+		}
+	}
+
+	for name, find := range map[string]func() (types.UserRecord, error){
+		"id":       func() (types.UserRecord, error) { return userStore.FindUserByID("user") },
+		"email":    func() (types.UserRecord, error) { return userStore.FindUserByEmail("user@example.com") },
+		"username": func() (types.UserRecord, error) { return userStore.FindUserByUsername("user") },
+		"identity": func() (types.UserRecord, error) { return userStore.FindUserByIdentity("github", "1") },
+	} {
+		found, err := find()
+		if err != nil || found.ID != "user" || found.Password != "hash" || found.RawData["plan"] != "free" || len(found.Identities) != 1 {
+			t.Errorf("finding the user by %s returned %+v, %v", name, found, err)
+		}
+	}
+	for name, find := range map[string]func() (types.UserRecord, error){
+		"id":       func() (types.UserRecord, error) { return userStore.FindUserByID("missing") },
+		"email":    func() (types.UserRecord, error) { return userStore.FindUserByEmail("missing@example.com") },
+		"username": func() (types.UserRecord, error) { return userStore.FindUserByUsername("missing") },
+		"identity": func() (types.UserRecord, error) { return userStore.FindUserByIdentity("github", "2") },
+	} {
+		if _, err := find(); err != ErrUserNotFound {
+			t.Errorf("finding a missing user by %s returned %v, want ErrUserNotFound", name, err)
+		}
+	}
+
+	user.FirstName = "Changed"
+	if err := userStore.UpdateUser(user); err != nil {
+		t.Fatal(err)
+	}
+	if found, _ := userStore.FindUserByID("user"); found.FirstName != "Changed" || found.UpdatedAt.IsZero() {
+		t.Errorf("the update stored %q and bumped UpdatedAt to %v", found.FirstName, found.UpdatedAt)
+	}
+	taken := user
+	taken.Email = "other@example.com"
+	if err := userStore.UpdateUser(taken); err != ErrUserAlreadyExists {
+		t.Errorf("updating to a taken email returned %v, want ErrUserAlreadyExists", err)
+	}
+	if err := userStore.UpdateUser(types.UserRecord{ID: "missing", Email: "missing@example.com", UserName: "missing"}); err != ErrUserNotFound {
+		t.Errorf("updating a missing user returned %v, want ErrUserNotFound", err)
+	}
+
+	if err := userStore.SetVerified("user", true); err != nil {
+		t.Fatal(err)
+	}
+	if err := userStore.SetVerified("missing", true); err != ErrUserNotFound {
+		t.Errorf("verifying a missing user returned %v, want ErrUserNotFound", err)
+	}
+	verified := true
+	if users, total, err := userStore.ListUsers(UserQuery{Verified: &verified, Limit: 1}); err != nil || total != 2 || len(users) != 1 || users[0].ID != "user" {
+		t.Errorf("listing verified users returned %d of %d, %v", len(users), total, err)
+	}
+
+	if err := userStore.UseTOTPStep("user", 10); err != nil {
+		t.Fatal(err)
+	}
+	for _, step := range []int{10, 9} {
+		if err := userStore.UseTOTPStep("user", step); err != ErrCodeAlreadyUsed {
+			t.Errorf("step %d after step 10 returned %v, want ErrCodeAlreadyUsed", step, err)
+		}
+	}
+
+	user.TOTPRecoveryCodes = []string{"a", "b"}
+	if err := userStore.UpdateUser(user); err != nil {
+		t.Fatal(err)
+	}
+	if err := userStore.UseRecoveryCode("user", "a"); err != nil {
+		t.Fatal(err)
+	}
+	if err := userStore.UseRecoveryCode("user", "a"); err != ErrCodeAlreadyUsed {
+		t.Errorf("a used recovery code returned %v, want ErrCodeAlreadyUsed", err)
+	}
+
+	if err := userStore.SetMagicLink("user", "token", "code", now.Add(time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		if err := userStore.CountMagicLinkAttempt("user", 2); err != nil {
+			t.Fatalf("attempt %d returned %v", i, err)
+		}
+	}
+	if err := userStore.CountMagicLinkAttempt("user", 2); err != ErrTooManyAttempts {
+		t.Errorf("the attempt over the limit returned %v, want ErrTooManyAttempts", err)
+	}
+
+	if err := userStore.DeleteUser("user"); err != nil {
+		t.Fatal(err)
+	}
+	if err := userStore.DeleteUser("user"); err != ErrUserNotFound {
+		t.Errorf("deleting a deleted user returned %v, want ErrUserNotFound", err)
+	}
+	if _, err := userStore.FindUserByEmail("user@example.com"); err != ErrUserNotFound {
+		t.Errorf("the deleted user was still found: %v", err)
+	}
+	if err := userStore.CreateUser(types.UserRecord{ID: "new", Email: "user@example.com", UserName: "user"}); err != nil {
+		t.Errorf("the email and username of a deleted user were still taken: %v", err)
+	}
+}
+
+func TestMemoryUserStore(t *testing.T) {
+	testUserStore(t, NewMemoryUserStore())
+}
@@ -1,16 +1,18 @@
 package types
 
 import (
-	"database/sql"
-	"encoding/json"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
 )
 
+// Handlers are extra routes registered by api.StartServer, setting Roles or Permissions makes the route
+// require a logged in user with any of the roles and all of the permissions
 type Handlers struct {
 	Route       string
 	HandlerFunc fiber.Handler
+	Roles       []string
+	Permissions []string
 }
 
 type ErrorResponse struct {
@@ -18,13 +20,15 @@ type ErrorResponse struct {
 }
 
 type SingleFileUploadedSuccessResponse struct {
-	FileName string `json:"fileName"`
-	Message  string `json:"message"`
+	FileName string      `json:"fileName"`
+	Message  string      `json:"message"`
+	File     *FileRecord `json:"file,omitempty"`
 }
 
 type MultipleFileUploadedSuccessResponse struct {
-	FileNames []string `json:"fileNames"`
-	Message   string   `json:"message"`
+	FileNames []string     `json:"fileNames"`
+	Message   string       `json:"message"`
+	Files     []FileRecord `json:"files,omitempty"`
 }
 
 type DeleteSuccessResponse struct {
@@ -44,45 +48,121 @@ type User struct {
 	LastName       string    `json:"lastName" validate:"required"`
 	Email          string    `json:"email" validate:"required,email"`
 	BirthDay       time.Time `json:"birthday"`
-	Password       string    `json:"password" validate:"required,min=8"`
+	Password       string    `json:"password" validate:"required"` // checked against the password policy
 	ProfilePicture string    `json:"profilePicture"`
 }
 
-type UserMongo struct {
+// UserRecord is the user as every store keeps it, the field names are also the json shape of /api/data/get-user
+type UserRecord struct {
 	ID                string         `bson:"id"`
 	UserName          string         `bson:"username, unique"`
 	FirstName         string         `bson:"firstName"`
 	LastName          string         `bson:"lastName"`
 	Email             string         `bson:"email, unique"`
-	Password          string         `bson:"password"`
+	Password          string         `bson:"password" json:"-"`
 	BirthDay          time.Time      `bson:"birthday"`
 	ProfilePicture    string         `bson:"profilePicture"`
 	CreatedAt         time.Time      `bson:"createdAt"`
 	UpdatedAt         time.Time      `bson:"updatedAt"`
 	Verified          bool           `bson:"verified"`
-	VerificationToken string         `bson:"verificationToken"`
+	VerificationToken string         `bson:"verificationToken" json:"-"`
 	LastLoggedIn      time.Time      `bson:"lastLoggedIn"`
 	RawData           map[string]any `bson:"rawData"`
+	Roles             []string       `bson:"roles"`
+	Permissions       []string       `bson:"permissions"` // granted on top of the permissions of the roles
+	Disabled          bool           `bson:"disabled"`    // disabled users can not log in
+	Anonymous         bool           `bson:"anonymous"`   // guest made by /api/auth/anonymous, purged when unused
+	Identities        []Identity     `bson:"identities"`  // oauth accounts the user can log in with, besides the password
+
+	PasswordResetToken     string    `bson:"passwordResetToken" json:"-"` // sha256 of the emailed token
+	PasswordResetExpiresAt time.Time `bson:"passwordResetExpiresAt" json:"-"`
+	PasswordHistory        []string  `bson:"passwordHistory" json:"-"` // hashes of the previous passwords, newest first
+
+	PendingEmail         string    `bson:"pendingEmail"`              // new email waiting for its confirmation
+	EmailChangeToken     string    `bson:"emailChangeToken" json:"-"` // sha256 of the token sent to the pending email
+	EmailChangeExpiresAt time.Time `bson:"emailChangeExpiresAt" json:"-"`
+	EmailRevertToken     string    `bson:"emailRevertToken" json:"-"`   // sha256 of the token sent to the previous email
+	EmailRevertAddress   string    `bson:"emailRevertAddress" json:"-"` // previous email the revert link restores
+	EmailRevertExpiresAt time.Time `bson:"emailRevertExpiresAt" json:"-"`
+
+	TOTPEnabled       bool     `bson:"totpEnabled"`
+	TOTPSecret        string   `bson:"totpSecret" json:"-"`        // set on enrollment, only used once TOTPEnabled is true
+	TOTPRecoveryCodes []string `bson:"totpRecoveryCodes" json:"-"` // sha256 of the unused recovery codes
+	TOTPLastStep      int      `bson:"totpLastStep" json:"-"`      // last accepted time step, stops a code from being replayed
+
+	MagicLinkToken     string    `bson:"magicLinkToken" json:"-"` // sha256 of the emailed link token
+	MagicLinkCode      string    `bson:"magicLinkCode" json:"-"`  // sha256 of the emailed 6 digit code
+	MagicLinkExpiresAt time.Time `bson:"magicLinkExpiresAt" json:"-"`
+	MagicLinkAttempts  int       `bson:"magicLinkAttempts" json:"-"` // wrong codes, the code is dropped after a few
 }
 
-type LogInDetails struct {
-	Email    string `json:"email" validate:"required,email"`
-	Password string `json:"password" validate:"required,min=8"`
+// Identity is a provider account linked to a user. Provider and Subject have to stay the first
+// fields, the sql stores find users by the json of the pair
+type Identity struct {
+	Provider string    `json:"provider" bson:"provider"` // google or github
+	Subject  string    `json:"subject" bson:"subject"`   // id of the account at the provider
+	Email    string    `json:"email" bson:"email"`
+	LinkedAt time.Time `json:"linkedAt" bson:"linkedAt"`
+}
+
+// Session is one logged in device, the refresh token is only stored as a sha256 hash
+type Session struct {
+	ID               string `bson:"id"`
+	UserID           string `bson:"userId"`
+	RefreshTokenHash string `bson:"refreshTokenHash" json:"-"`
+	// the token rotated away last, only that one coming back means the refresh token has been copied
+	PreviousRefreshTokenHash string    `bson:"previousRefreshTokenHash" json:"-"`
+	UserAgent                string    `bson:"userAgent"`
+	IP                       string    `bson:"ip"`
+	CreatedAt                time.Time `bson:"createdAt"`
+	LastUsedAt               time.Time `bson:"lastUsedAt"`
+	ExpiresAt                time.Time `bson:"expiresAt"`
 }
 
-type UserSQL struct {
-	ID                string
-	UserName          string
-	FirstName         string
-	LastName          string
-	Email             string
-	Password          string
-	BirthDay          time.Time
-	ProfilePicture    string
-	CreatedAt         sql.NullTime
-	UpdatedAt         sql.NullTime
-	Verified          bool
-	VerificationToken string
-	LastLoggedIn      sql.NullTime
-	RawData           json.RawMessage
+// APIKey lets a trusted backend call purpurbase as a service principal, Scopes are its permissions
+type APIKey struct {
+	ID         string    `bson:"id"`
+	Name       string    `bson:"name"`
+	KeyHash    string    `bson:"keyHash" json:"-"` // sha256 of the secret part of the key
+	Scopes     []string  `bson:"scopes"`
+	CreatedBy  string    `bson:"createdBy"`
+	CreatedAt  time.Time `bson:"createdAt"`
+	RotatedAt  time.Time `bson:"rotatedAt"`
+	LastUsedAt time.Time `bson:"lastUsedAt"`
+	ExpiresAt  time.Time `bson:"expiresAt"` // zero means the key never expires
+}
+
+// AuditEvent is one entry of the append-only audit log of the auth handlers
+type AuditEvent struct {
+	ID        string            `json:"id" bson:"id"`
+	Type      string            `json:"type" bson:"type"`       // like login.succeeded, see the Audit* constants of the authentication package
+	UserID    string            `json:"userId" bson:"userId"`   // the user the event is about, empty when no user was found
+	ActorID   string            `json:"actorId" bson:"actorId"` // the logged in user or api key that did it, empty for anonymous requests
+	Email     string            `json:"email" bson:"email"`
+	Success   bool              `json:"success" bson:"success"`
+	IP        string            `json:"ip" bson:"ip"`
+	UserAgent string            `json:"userAgent" bson:"userAgent"`
+	Details   map[string]string `json:"details,omitempty" bson:"details,omitempty"`
+	CreatedAt time.Time         `json:"createdAt" bson:"createdAt"`
+}
+
+// FileRecord is the metadata of an uploaded file, the file itself is kept by the storage driver under <folder>/<fileName>
+type FileRecord struct {
+	ID           string    `json:"id" bson:"id"`
+	Folder       string    `json:"folder" bson:"folder"`
+	FileName     string    `json:"fileName" bson:"fileName"`         // the generated name the file is stored and served under
+	OriginalName string    `json:"originalName" bson:"originalName"` // the name the file was uploaded with
+	Size         int64     `json:"size" bson:"size"`
+	ContentType  string    `json:"contentType" bson:"contentType"`
+	Checksum     string    `json:"checksum" bson:"checksum"`     // hex sha256 of the content
+	UploaderID   string    `json:"uploaderId" bson:"uploaderId"` // empty for anonymous uploads
+	CreatedAt    time.Time `json:"createdAt" bson:"createdAt"`
+}
+
+// UserMongo is kept for code written against the old mongodb handlers
+type UserMongo = UserRecord
+
+type LogInDetails struct {
+	Email    string `json:"email" validate:"required,email"`
+	Password string `json:"password" validate:"required"`
 }
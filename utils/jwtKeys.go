@@ -0,0 +1,217 @@
+package utils
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+
+	"github.com/froggy-12/purpurbase/config"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+type jwtKey struct {
+	kid     string
+	method  jwt.SigningMethod
+	private crypto.PrivateKey // nil for keys that only verify
+	public  crypto.PublicKey
+}
+
+// JWTKeySet signs and verifies access tokens, without keys it falls back to HS256 with the jwt secret
+type JWTKeySet struct {
+	Issuer   string
+	Audience string
+	signing  *jwtKey
+	keys     map[string]*jwtKey
+	order    []string
+}
+
+// JWTKeys is set up on startup by LoadJWTKeys
+var JWTKeys = &JWTKeySet{}
+
+// Asymmetric reports if tokens are signed with the configured keys instead of the jwt secret
+func (s *JWTKeySet) Asymmetric() bool {
+	return s.signing != nil
+}
+
+func (s *JWTKeySet) methods() []string {
+	if !s.Asymmetric() {
+		return []string{jwt.SigningMethodHS256.Alg()}
+	}
+	methods := []string{}
+	for _, kid := range s.order {
+		methods = append(methods, s.keys[kid].method.Alg())
+	}
+	return methods
+}
+
+// LoadJWTKeys reads the PEM files of the keys, the first key needs a private key because it signs the tokens
+func LoadJWTKeys(keys []config.JWTKey, issuer, audience string) (*JWTKeySet, error) {
+	set := &JWTKeySet{Issuer: issuer, Audience: audience, keys: map[string]*jwtKey{}}
+	if set.Issuer == "" {
+		set.Issuer = "purpurbase"
+	}
+	if set.Audience == "" {
+		set.Audience = "purpurbase"
+	}
+
+	for i, keyConfig := range keys {
+		if keyConfig.KID == "" {
+			return nil, fmt.Errorf("jwt key %d has no kid", i)
+		}
+		if _, ok := set.keys[keyConfig.KID]; ok {
+			return nil, errors.New("jwt key " + keyConfig.KID + " is listed twice")
+		}
+
+		key, err := loadJWTKey(keyConfig)
+		if err != nil {
+			return nil, errors.New("jwt key " + keyConfig.KID + ": " + err.Error())
+		}
+		if i == 0 {
+			if key.private == nil {
+				return nil, errors.New("jwt key " + keyConfig.KID + " signs the tokens so it needs a private key")
+			}
+			set.signing = key
+		}
+
+		set.keys[key.kid] = key
+		set.order = append(set.order, key.kid)
+	}
+
+	return set, nil
+}
+
+func loadJWTKey(keyConfig config.JWTKey) (*jwtKey, error) {
+	key := &jwtKey{kid: keyConfig.KID}
+
+	var private, public []byte
+	var err error
+	if keyConfig.PrivateKeyFile != "" {
+		if private, err = os.ReadFile(keyConfig.PrivateKeyFile); err != nil {
+			return nil, err
+		}
+	}
+	if keyConfig.PublicKeyFile != "" {
+		if public, err = os.ReadFile(keyConfig.PublicKeyFile); err != nil {
+			return nil, err
+		}
+	}
+	if private == nil && public == nil {
+		return nil, errors.New("no private or public key file")
+	}
+
+	switch keyConfig.Algorithm {
+	case "RS256":
+		key.method = jwt.SigningMethodRS256
+		if private != nil {
+			privateKey, err := jwt.ParseRSAPrivateKeyFromPEM(private)
+			if err != nil {
+				return nil, err
+			}
+			key.private, key.public = privateKey, &privateKey.PublicKey
+		}
+		if public != nil {
+			if key.public, err = jwt.ParseRSAPublicKeyFromPEM(public); err != nil {
+				return nil, err
+			}
+		}
+	case "ES256":
+		key.method = jwt.SigningMethodES256
+		if private != nil {
+			privateKey, err := jwt.ParseECPrivateKeyFromPEM(private)
+			if err != nil {
+				return nil, err
+			}
+			key.private, key.public = privateKey, &privateKey.PublicKey
+		}
+		if public != nil {
+			if key.public, err = jwt.ParseECPublicKeyFromPEM(public); err != nil {
+				return nil, err
+			}
+		}
+		if public, ok := key.public.(*ecdsa.PublicKey); !ok || public.Curve != elliptic.P256() {
+			return nil, errors.New("ES256 needs a P-256 key")
+		}
+	case "EdDSA":
+		key.method = jwt.SigningMethodEdDSA
+		if private != nil {
+			privateKey, err := jwt.ParseEdPrivateKeyFromPEM(private)
+			if err != nil {
+				return nil, err
+			}
+			key.private, key.public = privateKey, privateKey.(ed25519.PrivateKey).Public()
+		}
+		if public != nil {
+			if key.public, err = jwt.ParseEdPublicKeyFromPEM(public); err != nil {
+				return nil, err
+			}
+		}
+	default:
+		return nil, errors.New("unsupported algorithm " + keyConfig.Algorithm + ", use RS256, ES256 or EdDSA")
+	}
+
+	// with both files the public one replaced the one of the private key, tokens would be signed with a key nobody
+	// can verify them with
+	if private != nil && public != nil && !publicKeyOf(key.private).Equal(key.public) {
+		return nil, errors.New("the public key does not belong to the private key")
+	}
+
+	return key, nil
+}
+
+// publicKeyOf returns the public half of a parsed private key
+func publicKeyOf(private crypto.PrivateKey) interface{ Equal(crypto.PublicKey) bool } {
+	switch private := private.(type) {
+	case *rsa.PrivateKey:
+		return &private.PublicKey
+	case *ecdsa.PrivateKey:
+		return &private.PublicKey
+	case ed25519.PrivateKey:
+		return private.Public().(ed25519.PublicKey)
+	}
+	return nil
+}
+
+func base64URL(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// JWKS returns the public keys as a json web key set, it is empty while tokens are signed with the jwt secret
+func (s *JWTKeySet) JWKS() map[string]any {
+	keys := []map[string]any{}
+	for _, kid := range s.order {
+		key := s.keys[kid]
+		jwk := map[string]any{"kid": key.kid, "use": "sig", "alg": key.method.Alg()}
+
+		switch public := key.public.(type) {
+		case *rsa.PublicKey:
+			jwk["kty"] = "RSA"
+			jwk["n"] = base64URL(public.N.Bytes())
+			jwk["e"] = base64URL(big.NewInt(int64(public.E)).Bytes())
+		case *ecdsa.PublicKey:
+			ecdhKey, err := public.ECDH()
+			if err != nil {
+				continue
+			}
+			// uncompressed point, 0x04 || x || y
+			point := ecdhKey.Bytes()
+			jwk["kty"] = "EC"
+			jwk["crv"] = "P-256"
+			jwk["x"] = base64URL(point[1:33])
+			jwk["y"] = base64URL(point[33:])
+		case ed25519.PublicKey:
+			jwk["kty"] = "OKP"
+			jwk["crv"] = "Ed25519"
+			jwk["x"] = base64URL(public)
+		}
+
+		keys = append(keys, jwk)
+	}
+	return map[string]any{"keys": keys}
+}
@@ -0,0 +1,112 @@
+package utils
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/froggy-12/purpurbase/config"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// writeKeyPair writes the PEM files of the key and returns their paths
+func writeKeyPair(t *testing.T, name string, private crypto.Signer) (string, string) {
+	t.Helper()
+	privateDER, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		t.Fatal(err)
+	}
+	publicDER, err := x509.MarshalPKIXPublicKey(private.Public())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	privatePath, publicPath := filepath.Join(dir, name+".pem"), filepath.Join(dir, name+".pub.pem")
+	if err := os.WriteFile(privatePath, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: privateDER}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(publicPath, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicDER}), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return privatePath, publicPath
+}
+
+func TestLoadJWTKeyChecksThePair(t *testing.T) {
+	newRSA := func() crypto.Signer { key, _ := rsa.GenerateKey(rand.Reader, 2048); return key }
+	newEC := func(curve elliptic.Curve) func() crypto.Signer {
+		return func() crypto.Signer { key, _ := ecdsa.GenerateKey(curve, rand.Reader); return key }
+	}
+	newEd := func() crypto.Signer { _, key, _ := ed25519.GenerateKey(rand.Reader); return key }
+
+	for _, test := range []struct {
+		algorithm string
+		generate  func() crypto.Signer
+	}{
+		{"RS256", newRSA},
+		{"ES256", newEC(elliptic.P256())},
+		{"EdDSA", newEd},
+	} {
+		private, public := writeKeyPair(t, "key", test.generate())
+		_, otherPublic := writeKeyPair(t, "other", test.generate())
+
+		if _, err := loadJWTKey(config.JWTKey{KID: "a", Algorithm: test.algorithm, PrivateKeyFile: private, PublicKeyFile: public}); err != nil {
+			t.Errorf("%s: matching pair was rejected: %v", test.algorithm, err)
+		}
+		if _, err := loadJWTKey(config.JWTKey{KID: "a", Algorithm: test.algorithm, PrivateKeyFile: private, PublicKeyFile: otherPublic}); err == nil {
+			t.Errorf("%s: public key of another pair was accepted", test.algorithm)
+		}
+		if _, err := loadJWTKey(config.JWTKey{KID: "a", Algorithm: test.algorithm, PublicKeyFile: public}); err != nil {
+			t.Errorf("%s: verify only key was rejected: %v", test.algorithm, err)
+		}
+	}
+
+	private, public := writeKeyPair(t, "p384", newEC(elliptic.P384())())
+	if _, err := loadJWTKey(config.JWTKey{KID: "a", Algorithm: "ES256", PrivateKeyFile: private}); err == nil {
+		t.Error("ES256 accepted a P-384 private key")
+	}
+	if _, err := loadJWTKey(config.JWTKey{KID: "a", Algorithm: "ES256", PublicKeyFile: public}); err == nil {
+		t.Error("ES256 accepted a P-384 public key")
+	}
+}
+
+func TestMFAChallengeTokensUseTheirOwnKey(t *testing.T) {
+	const secret = "a-secret-that-is-long-enough-for-hs256"
+
+	token, err := GenerateMFAChallengeToken("user", time.Minute, secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if userID, err := ReadMFAChallengeToken(token, secret); err != nil || userID != "user" {
+		t.Errorf("ReadMFAChallengeToken returned %q, %v", userID, err)
+	}
+	if _, err := ReadMFAChallengeToken(token, "another-secret-that-is-long-enough"); err == nil {
+		t.Error("a token of another secret was accepted")
+	}
+
+	// a token signed with the secret itself, like an HS256 access token, is no challenge
+	signedWithSecret, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{"sub": "user", "exp": time.Now().Add(time.Minute).Unix()}).SignedString([]byte(secret))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ReadMFAChallengeToken(signedWithSecret, secret); err == nil {
+		t.Error("a token signed with the plain secret passed as an mfa challenge")
+	}
+
+	expired, err := GenerateMFAChallengeToken("user", -time.Minute, secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ReadMFAChallengeToken(expired, secret); err == nil {
+		t.Error("an expired challenge was accepted")
+	}
+}
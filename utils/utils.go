@@ -1,25 +1,20 @@
 package utils
 
 import (
-	"context"
-	"database/sql"
+	"crypto/hmac"
+	"crypto/sha256"
 	"errors"
 	"fmt"
-	"io"
 	"mime/multipart"
-	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 	"time"
 
-	"github.com/froggy-12/purpurbase/types"
-	"github.com/go-playground/validator/v10"
+	"github.com/froggy-12/purpurbase/services/storage"
 	"github.com/gofiber/fiber/v2"
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
-	"go.mongodb.org/mongo-driver/bson"
-	"go.mongodb.org/mongo-driver/mongo"
-	"golang.org/x/crypto/bcrypt"
 )
 
 var DebugLogging bool = true
@@ -30,184 +25,195 @@ func DebugLogger(area, message string) {
 	}
 }
 
-func GenerateJWTToken(id string, jwtExpirationTime int, jwtSecret string) (string, error) {
-	claims := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"sub":  id,
-		"expr": time.Now().Add(time.Hour * 24 * time.Duration(jwtExpirationTime)).Unix(),
-		"iat":  time.Now().Unix(),
-	})
-
-	token, err := claims.SignedString([]byte(jwtSecret))
-
-	if err != nil {
-		return "", err
-	}
-
-	return token, nil
+type JWTClaims struct {
+	UserID      string
+	SessionID   string
+	Roles       []string
+	Permissions []string
+	ExpiresAt   time.Time
 }
 
-func RefreshJWTToken(token, jwtSecret string, jwtExpirationTime int) (string, error) {
-	claims := jwt.MapClaims{}
-	_, err := jwt.ParseWithClaims(token, claims, func(t *jwt.Token) (interface{}, error) {
-		return []byte(jwtSecret), nil
-	})
+func (c JWTClaims) Expired() bool {
+	return time.Now().After(c.ExpiresAt)
+}
 
-	if err != nil {
-		return "", err
+// GenerateJWTToken signs a short lived access token for the user bound to one session, ExpiresAt is set from age.
+// With JWTKeys loaded the first key signs it and its kid is set, otherwise it is HS256 with the jwt secret
+func GenerateJWTToken(claims JWTClaims, age time.Duration, jwtSecret string) (string, error) {
+	now := time.Now()
+	mapClaims := jwt.MapClaims{
+		"sub":   claims.UserID,
+		"sid":   claims.SessionID,
+		"roles": claims.Roles,
+		"perms": claims.Permissions,
+		"exp":   now.Add(age).Unix(),
+		"iat":   now.Unix(),
 	}
-
-	userId, ok := claims["sub"].(string)
-	if !ok {
-		return "", errors.New("invalid token claims")
+	if JWTKeys.Issuer != "" {
+		mapClaims["iss"] = JWTKeys.Issuer
+	}
+	if JWTKeys.Audience != "" {
+		mapClaims["aud"] = []string{JWTKeys.Audience}
 	}
 
-	// Generate a new token with the same user ID and a new expiration time
-	newToken, err := GenerateJWTToken(userId, jwtExpirationTime, jwtSecret)
-	if err != nil {
-		return "", err
+	if !JWTKeys.Asymmetric() {
+		return jwt.NewWithClaims(jwt.SigningMethodHS256, mapClaims).SignedString([]byte(jwtSecret))
 	}
 
-	return newToken, nil
+	token := jwt.NewWithClaims(JWTKeys.signing.method, mapClaims)
+	token.Header["kid"] = JWTKeys.signing.kid
+	return token.SignedString(JWTKeys.signing.private)
 }
 
-func ExtractJWTToken(token, jwtSecret string) (string, error) {
+func stringsClaim(claims jwt.MapClaims, name string) []string {
+	values, _ := claims[name].([]any)
+	result := make([]string, 0, len(values))
+	for _, value := range values {
+		if s, ok := value.(string); ok {
+			result = append(result, s)
+		}
+	}
+	return result
+}
+
+// ReadJWTClaims checks the signature, iss and aud of an access token, expired tokens are still returned so callers
+// can tell them apart from invalid ones with Expired
+func ReadJWTClaims(token, jwtSecret string) (JWTClaims, error) {
 	claims := jwt.MapClaims{}
 	_, err := jwt.ParseWithClaims(token, claims, func(t *jwt.Token) (interface{}, error) {
-		return []byte(jwtSecret), nil
-	})
+		if !JWTKeys.Asymmetric() {
+			return []byte(jwtSecret), nil
+		}
+		kid, _ := t.Header["kid"].(string)
+		key, ok := JWTKeys.keys[kid]
+		if !ok || key.method.Alg() != t.Method.Alg() {
+			return nil, errors.New("unknown kid")
+		}
+		return key.public, nil
+	}, jwt.WithValidMethods(JWTKeys.methods()), jwt.WithoutClaimsValidation())
 
 	if err != nil {
-		return "", err
+		return JWTClaims{}, err
 	}
 
-	userID, ok := claims["sub"].(string)
+	if JWTKeys.Issuer != "" {
+		if issuer, _ := claims.GetIssuer(); issuer != JWTKeys.Issuer {
+			return JWTClaims{}, errors.New("invalid token issuer")
+		}
+	}
+	if JWTKeys.Audience != "" {
+		audience, _ := claims.GetAudience()
+		if !slices.Contains(audience, JWTKeys.Audience) {
+			return JWTClaims{}, errors.New("invalid token audience")
+		}
+	}
+
+	userId, ok := claims["sub"].(string)
 	if !ok {
-		return "", errors.New("invalid token claims")
+		return JWTClaims{}, errors.New("invalid token claims")
+	}
+	expiresAt, err := claims.GetExpirationTime()
+	if err != nil || expiresAt == nil {
+		return JWTClaims{}, errors.New("invalid token claims")
 	}
+	sessionID, _ := claims["sid"].(string)
 
-	return userID, nil
+	return JWTClaims{
+		UserID:      userId,
+		SessionID:   sessionID,
+		Roles:       stringsClaim(claims, "roles"),
+		Permissions: stringsClaim(claims, "perms"),
+		ExpiresAt:   expiresAt.Time,
+	}, nil
 }
 
 func ReadJWTToken(token, jwtSecret string) (string, bool, error) {
-	claims := jwt.MapClaims{}
-	_, err := jwt.ParseWithClaims(token, claims, func(t *jwt.Token) (interface{}, error) {
-		return []byte(jwtSecret), nil
-	})
-
+	claims, err := ReadJWTClaims(token, jwtSecret)
 	if err != nil {
 		return "", false, err
 	}
 
-	userId, ok := claims["sub"].(string)
-	if !ok {
-		return "", false, errors.New("invalid token claims")
-	}
-	expr, ok := claims["expr"].(float64)
-	if !ok {
-		return "", false, errors.New("invalid token claims")
-	}
-
-	expirationTime := time.Unix(int64(expr), 0)
-	if time.Now().After(expirationTime) {
+	if claims.Expired() {
 		return "", true, nil
 	}
 
-	return userId, false, nil
+	return claims.UserID, false, nil
 }
 
-func SetJwtHttpCookies(c *fiber.Ctx, token string, cookieAge int) {
-	expires := time.Now().Add(time.Hour * 24 * time.Duration(cookieAge))
-	maxAge := int(expires.Sub(time.Now()).Seconds())
-	cookie := &fiber.Cookie{
-		Name:     "jwtToken",
-		Value:    token,
-		Path:     "/",
-		HTTPOnly: true,
-		Secure:   true,
-		MaxAge:   maxAge,
-	}
-
-	c.Cookie(cookie)
+// DeriveKey gives every kind of internal token its own key from the jwt secret, so a token of one kind can never
+// pass as another one like an access token
+func DeriveKey(jwtSecret, purpose string) []byte {
+	mac := hmac.New(sha256.New, []byte(jwtSecret))
+	mac.Write([]byte("purpurbase:" + purpose))
+	return mac.Sum(nil)
 }
 
-func FindUserFromMongoDBUsingEmail(email string, mongoCollection *mongo.Collection) (types.UserMongo, error) {
-	filter := bson.M{"email": email}
-	user := types.UserMongo{}
-	err := mongoCollection.FindOne(context.Background(), filter).Decode(&user)
-	return user, err
+func mfaChallengeKey(jwtSecret string) []byte {
+	return DeriveKey(jwtSecret, "mfa-challenge")
 }
 
-func FindUserFromMongoDBUsingUsername(username string, mongoCollection *mongo.Collection) (types.UserMongo, error) {
-	filter := bson.M{"username": username}
-	user := types.UserMongo{}
-	err := mongoCollection.FindOne(context.Background(), filter).Decode(&user)
-	return user, err
-}
+// GenerateMFAChallengeToken is handed out after the password check when the second factor is still missing
+func GenerateMFAChallengeToken(id string, age time.Duration, jwtSecret string) (string, error) {
+	claims := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"sub": id,
+		"exp": time.Now().Add(age).Unix(),
+		"iat": time.Now().Unix(),
+	})
 
-func FindUserFromMongoDBUsingID(id string, mongoCollection *mongo.Collection) (types.UserMongo, error) {
-	filter := bson.M{"id": id}
-	user := types.UserMongo{}
-	err := mongoCollection.FindOne(context.Background(), filter).Decode(&user)
-	return user, err
+	return claims.SignedString(mfaChallengeKey(jwtSecret))
 }
 
-func LogInMongoDB(c *fiber.Ctx, coll *mongo.Collection, validator validator.Validate, jwtTokenTime int, jwtSecret string) error {
-	var details types.LogInDetails
-	if err := c.BodyParser(&details); err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(types.ErrorResponse{Error: "Invalid Request body"})
-	}
-
-	if err := validator.Struct(&details); err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(types.ErrorResponse{Error: err.Error()})
-	}
-
-	user, err := FindUserFromMongoDBUsingEmail(details.Email, coll)
-
-	if err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(types.ErrorResponse{Error: "User Doesnt Exist"})
-	}
-
-	err = bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(details.Password))
-
-	if err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(types.ErrorResponse{Error: "Wrong Password"})
-	}
-
-	token, err := GenerateJWTToken(user.ID, jwtTokenTime, jwtSecret)
+func ReadMFAChallengeToken(token, jwtSecret string) (string, error) {
+	claims := jwt.MapClaims{}
+	_, err := jwt.ParseWithClaims(token, claims, func(t *jwt.Token) (interface{}, error) {
+		return mfaChallengeKey(jwtSecret), nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}), jwt.WithExpirationRequired())
 
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(types.ErrorResponse{Error: "Failed to generate JWT token"})
+		return "", err
 	}
 
-	lastLoggedIn := time.Now()
-
-	_, err = coll.UpdateOne(context.Background(), bson.M{"id": user.ID}, bson.M{"$set": bson.M{"lastLoggedIn": lastLoggedIn}})
-
-	if err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(types.ErrorResponse{Error: "Something Went Wrong while updating last log in informations: " + err.Error()})
+	userId, ok := claims["sub"].(string)
+	if !ok {
+		return "", errors.New("invalid token claims")
 	}
 
-	SetJwtHttpCookies(c, token, jwtTokenTime)
-	return c.Status(fiber.StatusAccepted).JSON(types.HTTPSuccessResponse{
-		Message: "User has been logged in successfully",
-		Data:    map[string]any{"userID": user.ID},
-	})
+	return userId, nil
 }
 
-func LogOut(c *fiber.Ctx) error {
-	cookie := &fiber.Cookie{
+// SetJwtHttpCookies sets the access token for every path and the refresh token only for /api/auth
+func SetJwtHttpCookies(c *fiber.Ctx, accessToken string, accessAge time.Duration, refreshToken string, refreshAge time.Duration) {
+	c.Cookie(&fiber.Cookie{
 		Name:     "jwtToken",
+		Value:    accessToken,
 		Path:     "/",
-		Value:    "",
 		HTTPOnly: true,
 		Secure:   true,
-		MaxAge:   0,
-	}
+		MaxAge:   int(accessAge.Seconds()),
+	})
 
-	c.Cookie(cookie)
+	c.Cookie(&fiber.Cookie{
+		Name:     "refreshToken",
+		Value:    refreshToken,
+		Path:     "/api/auth",
+		HTTPOnly: true,
+		Secure:   true,
+		SameSite: fiber.CookieSameSiteStrictMode,
+		MaxAge:   int(refreshAge.Seconds()),
+	})
+}
+
+func ClearJwtHttpCookies(c *fiber.Ctx) {
+	c.Cookie(&fiber.Cookie{Name: "jwtToken", Path: "/", HTTPOnly: true, Secure: true, Expires: time.Unix(0, 0)})
+	c.Cookie(&fiber.Cookie{Name: "refreshToken", Path: "/api/auth", HTTPOnly: true, Secure: true, Expires: time.Unix(0, 0)})
+}
+
+func LogOut(c *fiber.Ctx) error {
+	ClearJwtHttpCookies(c)
 	return c.Status(fiber.StatusOK).SendString("User Has been logged out")
 }
 
+// Deprecated: only looks at the extension, the upload routes detect the type from the content now
 func IsImage(filename string) bool {
 	extensions := []string{".jpg", ".jpeg", ".png", ".gif", ".bmp", ".tiff", ".webp", ".avif", ".jpig"}
 	for _, ext := range extensions {
@@ -218,6 +224,7 @@ func IsImage(filename string) bool {
 	return false
 }
 
+// Deprecated: only looks at the extension, the upload routes detect the type from the content now
 func IsMusic(filename string) bool {
 	extensions := []string{".mp3", ".wav", ".ogg", ".flac", ".m4a"}
 	for _, ext := range extensions {
@@ -228,194 +235,47 @@ func IsMusic(filename string) bool {
 	return false
 }
 
+// Deprecated: only looks at the extension, the upload routes detect the type from the content now
 func IsVideo(filename string) bool {
 	ext := filepath.Ext(filename)
 	ext = strings.ToLower(ext)
 	return ext == ".mp4" || ext == ".avi" || ext == ".mov" || ext == ".wmv"
 }
 
-func UploadFile(_ *fiber.Ctx, file *multipart.FileHeader, folder string) (string, error) {
+func UploadFile(c *fiber.Ctx, file *multipart.FileHeader, folder string) (string, error) {
 	// Generate a unique filename
 	uuid := uuid.New()
 	filename := fmt.Sprintf("%s%s", uuid, filepath.Ext(file.Filename))
 
-	// Save the file to the uploads folder
-	uploadsDir := filepath.Join(".", "uploads", folder)
-	err := os.MkdirAll(uploadsDir, os.ModePerm)
-	if err != nil {
-		return "", err
-	}
-
-	filepath := filepath.Join(uploadsDir, filename)
-	fileStream, err := file.Open()
-	if err != nil {
-		return "", err
-	}
-	defer fileStream.Close()
-
-	// Create the file
-	f, err := os.Create(filepath)
-	if err != nil {
-		return "", err
-	}
-	defer f.Close()
-
-	// Copy the file contents
-	_, err = io.Copy(f, fileStream)
-	if err != nil {
+	if err := UploadAnyFile(c, file, folder, filename); err != nil {
 		return "", err
 	}
 
 	return filename, nil
 }
 
+// DeleteFile removes the file out of the bucket named folder
 func DeleteFile(filename, folder string) error {
-	filepath := filepath.Join(".", "uploads", folder, filename)
-	return os.Remove(filepath)
+	key, err := storage.Resolve(folder, filename)
+	if err != nil {
+		return err
+	}
+	return storage.Files.Delete(key)
 }
 
+// UploadAnyFile puts the file into the storage driver of storageConfigs under <folder>/<filename>,
+// the folder is the name of one of the buckets
 func UploadAnyFile(_ *fiber.Ctx, file *multipart.FileHeader, folder, filename string) error {
-	// Save the file to the uploads folder
-	uploadsDir := filepath.Join(".", "uploads", folder)
-	err := os.MkdirAll(uploadsDir, os.ModePerm)
+	key, err := storage.Resolve(folder, filename)
 	if err != nil {
 		return err
 	}
 
-	filepath := filepath.Join(uploadsDir, filename)
 	fileStream, err := file.Open()
 	if err != nil {
 		return err
 	}
 	defer fileStream.Close()
 
-	// Create the file
-	f, err := os.Create(filepath)
-	if err != nil {
-		return err
-	}
-	defer f.Close()
-
-	// Copy the file contents
-	_, err = io.Copy(f, fileStream)
-	if err != nil {
-		return err
-	}
-
-	return nil
-}
-
-func FindUserFromSQLDBUsingEmail(email string, db *sql.DB) (types.UserSQL, error) {
-	var user types.UserSQL
-	query := "SELECT ID, UserName, FirstName, LastName, Email, Password, BirthDay, ProfilePicture, CreatedAt, UpdatedAt, Verified, VerificationToken, LastLoggedIn, RawData FROM purpurbase.users WHERE Email = ?;"
-	err := db.QueryRow(query, email).Scan(
-		&user.ID,
-		&user.UserName,
-		&user.FirstName,
-		&user.LastName,
-		&user.Email,
-		&user.Password,
-		&user.BirthDay,
-		&user.ProfilePicture,
-		&user.CreatedAt,
-		&user.UpdatedAt,
-		&user.Verified,
-		&user.VerificationToken,
-		&user.LastLoggedIn,
-		&user.RawData,
-	)
-
-	return user, err
-}
-
-func FindUserFromSQLDBUsingID(ID string, db *sql.DB) (types.UserSQL, error) {
-	var user types.UserSQL
-	query := "SELECT ID, UserName, FirstName, LastName, Email, Password, BirthDay, ProfilePicture, CreatedAt, UpdatedAt, Verified, VerificationToken, LastLoggedIn, RawData FROM purpurbase.users WHERE ID = ?;"
-	err := db.QueryRow(query, ID).Scan(
-		&user.ID,
-		&user.UserName,
-		&user.FirstName,
-		&user.LastName,
-		&user.Email,
-		&user.Password,
-		&user.BirthDay,
-		&user.ProfilePicture,
-		&user.CreatedAt,
-		&user.UpdatedAt,
-		&user.Verified,
-		&user.VerificationToken,
-		&user.LastLoggedIn,
-		&user.RawData,
-	)
-
-	return user, err
-}
-
-func FindUserFromSQLDBUsingUsername(username string, db *sql.DB) (types.UserSQL, error) {
-	var user types.UserSQL
-	query := "SELECT ID, UserName, FirstName, LastName, Email, Password, BirthDay, ProfilePicture, CreatedAt, UpdatedAt, Verified, VerificationToken, LastLoggedIn, RawData FROM purpurbase.users WHERE UserName = ?;"
-	err := db.QueryRow(query, username).Scan(
-		&user.ID,
-		&user.UserName,
-		&user.FirstName,
-		&user.LastName,
-		&user.Email,
-		&user.Password,
-		&user.BirthDay,
-		&user.ProfilePicture,
-		&user.CreatedAt,
-		&user.UpdatedAt,
-		&user.Verified,
-		&user.VerificationToken,
-		&user.LastLoggedIn,
-		&user.RawData,
-	)
-
-	return user, err
-}
-
-func LogInSQLDB(c *fiber.Ctx, db *sql.DB, validate validator.Validate, jwtExpirationTime int, jwtSecret string) error {
-	var details types.LogInDetails
-
-	if err := c.BodyParser(&details); err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(types.ErrorResponse{Error: "Invalid Request body"})
-	}
-
-	if err := validate.Struct(&details); err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(types.ErrorResponse{Error: err.Error()})
-	}
-
-	user, err := FindUserFromSQLDBUsingEmail(details.Email, db)
-
-	if err != nil {
-		if err == sql.ErrNoRows {
-			return c.Status(fiber.StatusBadRequest).JSON(types.ErrorResponse{Error: "User does not exist"})
-		} else {
-			return c.Status(fiber.StatusInternalServerError).JSON(types.ErrorResponse{Error: "Something went wrong: " + err.Error()})
-		}
-	}
-
-	err = bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(details.Password))
-
-	if err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(types.ErrorResponse{Error: "Wrong Password"})
-	}
-
-	token, err := GenerateJWTToken(user.ID, jwtExpirationTime, jwtSecret)
-
-	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(types.ErrorResponse{Error: "Failed to generate JWT token"})
-	}
-
-	_, err = db.Exec(`UPDATE purpurbase.users SET LastLoggedIn = CURRENT_TIMESTAMP() WHERE ID = ?`, user.ID)
-
-	if err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(types.ErrorResponse{Error: "Something Went Wrong: " + err.Error()})
-	}
-
-	SetJwtHttpCookies(c, token, jwtExpirationTime)
-	return c.Status(fiber.StatusAccepted).JSON(types.HTTPSuccessResponse{
-		Message: "User has been logged in successfully",
-		Data:    map[string]any{"userID": user.ID},
-	})
+	return storage.Files.Put(key, fileStream, file.Size, file.Header.Get("Content-Type"))
 }